@@ -1,9 +1,13 @@
 package ast
 
+import "monkey/token"
+
 // Node The base interface
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the first character belonging to the node
+	End() token.Position // position immediately after the node
 }
 
 // StatementNode All statement nodes implement this interface
@@ -17,3 +21,23 @@ type ExpressionNode interface {
 	Node
 	expressionNode()
 }
+
+// endOf returns the end position of an optional child node, falling back
+// to the end of the given token when the child is missing (after parse errors)
+func endOf(n Node, fallback token.Token) token.Position {
+	if n == nil {
+		return fallback.End
+	}
+
+	return n.End()
+}
+
+// posOf returns the start position of an optional child node, falling back
+// to the start of the given token when the child is missing
+func posOf(n Node, fallback token.Token) token.Position {
+	if n == nil {
+		return fallback.Start
+	}
+
+	return n.Pos()
+}
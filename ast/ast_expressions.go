@@ -3,7 +3,9 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"monkey/token"
+	"sort"
 	"strings"
 )
 
@@ -18,6 +20,8 @@ type IdentifierNode struct {
 func (i *IdentifierNode) expressionNode()      {}
 func (i *IdentifierNode) TokenLiteral() string { return i.Token.Literal }
 func (i *IdentifierNode) String() string       { return i.Value }
+func (i *IdentifierNode) Pos() token.Position  { return i.Token.Start }
+func (i *IdentifierNode) End() token.Position  { return i.Token.End }
 
 // BooleanNode boolean node
 type BooleanNode struct {
@@ -28,6 +32,8 @@ type BooleanNode struct {
 func (b *BooleanNode) expressionNode()      {}
 func (b *BooleanNode) TokenLiteral() string { return b.Token.Literal }
 func (b *BooleanNode) String() string       { return b.Token.Literal }
+func (b *BooleanNode) Pos() token.Position  { return b.Token.Start }
+func (b *BooleanNode) End() token.Position  { return b.Token.End }
 
 // IntegerLiteralNode Integer literal node
 type IntegerLiteralNode struct {
@@ -38,6 +44,32 @@ type IntegerLiteralNode struct {
 func (il *IntegerLiteralNode) expressionNode()      {}
 func (il *IntegerLiteralNode) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteralNode) String() string       { return il.Token.Literal }
+func (il *IntegerLiteralNode) Pos() token.Position  { return il.Token.Start }
+func (il *IntegerLiteralNode) End() token.Position  { return il.Token.End }
+
+// BigIntLiteralNode Big integer literal node, like 10n
+type BigIntLiteralNode struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BigIntLiteralNode) expressionNode()      {}
+func (bl *BigIntLiteralNode) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BigIntLiteralNode) String() string       { return bl.Token.Literal }
+func (bl *BigIntLiteralNode) Pos() token.Position  { return bl.Token.Start }
+func (bl *BigIntLiteralNode) End() token.Position  { return bl.Token.End }
+
+// FloatLiteralNode Float literal node
+type FloatLiteralNode struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteralNode) expressionNode()      {}
+func (fl *FloatLiteralNode) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteralNode) String() string       { return fl.Token.Literal }
+func (fl *FloatLiteralNode) Pos() token.Position  { return fl.Token.Start }
+func (fl *FloatLiteralNode) End() token.Position  { return fl.Token.End }
 
 // PrefixExpressionNode Predix expression ast node
 type PrefixExpressionNode struct {
@@ -48,6 +80,8 @@ type PrefixExpressionNode struct {
 
 func (pe *PrefixExpressionNode) expressionNode()      {}
 func (pe *PrefixExpressionNode) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpressionNode) Pos() token.Position  { return pe.Token.Start }
+func (pe *PrefixExpressionNode) End() token.Position  { return endOf(pe.RightNode, pe.Token) }
 func (pe *PrefixExpressionNode) String() string {
 	var out bytes.Buffer
 
@@ -69,6 +103,8 @@ type InfixExpressionNode struct {
 
 func (ie *InfixExpressionNode) expressionNode()      {}
 func (ie *InfixExpressionNode) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpressionNode) Pos() token.Position  { return posOf(ie.LeftNode, ie.Token) }
+func (ie *InfixExpressionNode) End() token.Position  { return endOf(ie.RightNode, ie.Token) }
 func (ie *InfixExpressionNode) String() string {
 	var out bytes.Buffer
 
@@ -81,6 +117,36 @@ func (ie *InfixExpressionNode) String() string {
 	return out.String()
 }
 
+// AssignExpressionNode Assignment ast node, like x = 1, x += 1 or a[i] = 1
+type AssignExpressionNode struct {
+	Token     token.Token    // the assignment operator token, e.g. +=
+	Target    ExpressionNode // an identifier or an index expression
+	Operator  string         // "=", or a compound assignment like "+="
+	ValueNode ExpressionNode
+}
+
+func (ae *AssignExpressionNode) expressionNode()      {}
+func (ae *AssignExpressionNode) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpressionNode) Pos() token.Position  { return posOf(ae.Target, ae.Token) }
+func (ae *AssignExpressionNode) End() token.Position  { return endOf(ae.ValueNode, ae.Token) }
+
+// BinaryOperator returns the operator a compound assignment applies, like
+// + for +=, or "" for a plain assignment
+func (ae *AssignExpressionNode) BinaryOperator() string {
+	return strings.TrimSuffix(ae.Operator, "=")
+}
+func (ae *AssignExpressionNode) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.ValueNode.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 // IfExpressionNode If expression ast node
 type IfExpressionNode struct {
 	Token           token.Token // The 'if' token
@@ -91,6 +157,16 @@ type IfExpressionNode struct {
 
 func (ie *IfExpressionNode) expressionNode()      {}
 func (ie *IfExpressionNode) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpressionNode) Pos() token.Position  { return ie.Token.Start }
+func (ie *IfExpressionNode) End() token.Position {
+	if ie.AlternativeNode != nil {
+		return ie.AlternativeNode.End()
+	}
+	if ie.ConsequenceNode != nil {
+		return ie.ConsequenceNode.End()
+	}
+	return endOf(ie.ConditionNode, ie.Token)
+}
 func (ie *IfExpressionNode) String() string {
 	var out bytes.Buffer
 
@@ -107,22 +183,194 @@ func (ie *IfExpressionNode) String() string {
 	return out.String()
 }
 
+// MatchExpressionNode Match expression ast node, it evaluates the first arm
+// with a pattern that matches the subject and a true guard, or to null if no
+// arm matches
+type MatchExpressionNode struct {
+	Token       token.Token // The 'match' token
+	SubjectNode ExpressionNode
+	Arms        []*MatchArmNode
+	EndToken    token.Token // The '}' token
+}
+
+func (me *MatchExpressionNode) expressionNode()      {}
+func (me *MatchExpressionNode) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpressionNode) Pos() token.Position  { return me.Token.Start }
+func (me *MatchExpressionNode) End() token.Position  { return me.EndToken.End }
+func (me *MatchExpressionNode) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+
+	return fmt.Sprintf("match %s { %s }", me.SubjectNode.String(), strings.Join(arms, ", "))
+}
+
+// MatchArmNode One arm of a match expression, like 1, 2 => "small" or
+// [x, y] if x > y => x
+type MatchArmNode struct {
+	Token     token.Token      // The '=>' token
+	Patterns  []ExpressionNode // the arm is taken if any of them matches
+	GuardNode ExpressionNode   // condition after 'if', or nil
+	BodyNode  *BlockStatementNode
+}
+
+func (ma *MatchArmNode) String() string {
+	patterns := []string{}
+	for _, p := range ma.Patterns {
+		patterns = append(patterns, p.String())
+	}
+
+	guard := ""
+	if ma.GuardNode != nil {
+		guard = " if " + ma.GuardNode.String()
+	}
+
+	return strings.Join(patterns, ", ") + guard + " => " + ma.BodyNode.String()
+}
+
+// A pattern is a literal, compared like ==, an identifier, which matches
+// any value and binds it to the name, _, which matches any value, or one of
+// the pattern nodes below.
+
+// ArrayPatternNode Array pattern ast node, like [a, b, ...rest]. Without a
+// rest it matches arrays of exactly as many elements as it has patterns.
+type ArrayPatternNode struct {
+	Token    token.Token      // The '[' token
+	Elements []ExpressionNode // patterns of the leading elements
+	Rest     *IdentifierNode  // bound to an array of the remaining elements, or nil
+	EndToken token.Token      // The ']' token
+}
+
+func (ap *ArrayPatternNode) expressionNode()      {}
+func (ap *ArrayPatternNode) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPatternNode) Pos() token.Position  { return ap.Token.Start }
+func (ap *ArrayPatternNode) End() token.Position  { return ap.EndToken.End }
+func (ap *ArrayPatternNode) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPatternNode Hash pattern ast node, like {"name": n}. It matches
+// hashes that have all of its keys, other keys are ignored.
+type HashPatternNode struct {
+	Token    token.Token      // The '{' token
+	Keys     []ExpressionNode // literals, in source order
+	Values   []ExpressionNode // patterns of the values of the keys
+	EndToken token.Token      // The '}' token
+}
+
+func (hp *HashPatternNode) expressionNode()      {}
+func (hp *HashPatternNode) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPatternNode) Pos() token.Position  { return hp.Token.Start }
+func (hp *HashPatternNode) End() token.Position  { return hp.EndToken.End }
+func (hp *HashPatternNode) String() string {
+	pairs := []string{}
+	for i, key := range hp.Keys {
+		pairs = append(pairs, key.String()+": "+hp.Values[i].String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// TypePatternNode Type check pattern ast node, like int(n). It matches
+// values of the type that also match the inner pattern.
+type TypePatternNode struct {
+	Token       token.Token // The type name token
+	TypeName    string      // int, float, string, bool, array, hash, function or null
+	PatternNode ExpressionNode
+	EndToken    token.Token // The ')' token
+}
+
+func (tp *TypePatternNode) expressionNode()      {}
+func (tp *TypePatternNode) TokenLiteral() string { return tp.Token.Literal }
+func (tp *TypePatternNode) Pos() token.Position  { return tp.Token.Start }
+func (tp *TypePatternNode) End() token.Position  { return tp.EndToken.End }
+func (tp *TypePatternNode) String() string {
+	return tp.TypeName + "(" + tp.PatternNode.String() + ")"
+}
+
+// IsWildcard reports whether a pattern is _, which matches any value
+func IsWildcard(pattern ExpressionNode) bool {
+	ident, ok := pattern.(*IdentifierNode)
+	return ok && ident.Value == "_"
+}
+
+// IsIrrefutable reports whether a pattern matches any value, like _ or a
+// name
+func IsIrrefutable(pattern ExpressionNode) bool {
+	_, ok := pattern.(*IdentifierNode)
+	return ok
+}
+
+// PatternBindings returns the names a pattern binds, in the order they are
+// bound
+func PatternBindings(pattern ExpressionNode) []string {
+	names := []string{}
+
+	switch pattern := pattern.(type) {
+	case *IdentifierNode:
+		if !IsWildcard(pattern) {
+			names = append(names, pattern.Value)
+		}
+	case *ArrayPatternNode:
+		for _, el := range pattern.Elements {
+			names = append(names, PatternBindings(el)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, PatternBindings(pattern.Rest)...)
+		}
+	case *HashPatternNode:
+		for _, value := range pattern.Values {
+			names = append(names, PatternBindings(value)...)
+		}
+	case *TypePatternNode:
+		names = append(names, PatternBindings(pattern.PatternNode)...)
+	}
+
+	return names
+}
+
 // FunctionLiteralNode Function literal ast node
 type FunctionLiteralNode struct {
-	Token      token.Token // The 'fn' token
-	ParamNodes []*IdentifierNode
-	BodyNode   *BlockStatementNode
-	Name       string
+	Token        token.Token // The 'fn' token
+	ParamNodes   []*IdentifierNode
+	DefaultNodes []ExpressionNode // default values of the last parameters, in order
+	RestNode     *IdentifierNode  // the ...rest parameter, if any
+	BodyNode     *BlockStatementNode
+	Name         string
 }
 
 func (fl *FunctionLiteralNode) expressionNode()      {}
 func (fl *FunctionLiteralNode) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteralNode) Pos() token.Position  { return fl.Token.Start }
+func (fl *FunctionLiteralNode) End() token.Position {
+	if fl.BodyNode != nil {
+		return fl.BodyNode.End()
+	}
+	return fl.Token.End
+}
 func (fl *FunctionLiteralNode) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.ParamNodes {
-		params = append(params, p.String())
+	required := fl.NumRequired()
+	for i, p := range fl.ParamNodes {
+		if i < required {
+			params = append(params, p.String())
+		} else {
+			params = append(params, p.String()+" = "+fl.DefaultNodes[i-required].String())
+		}
+	}
+	if fl.RestNode != nil {
+		params = append(params, "..."+fl.RestNode.String())
 	}
 
 	out.WriteString(fl.TokenLiteral())
@@ -137,15 +385,51 @@ func (fl *FunctionLiteralNode) String() string {
 	return out.String()
 }
 
+// NumRequired returns the number of parameters without a default value,
+// they come before the ones with a default
+func (fl *FunctionLiteralNode) NumRequired() int {
+	return len(fl.ParamNodes) - len(fl.DefaultNodes)
+}
+
+// SpreadNode A ...value call argument, passes the elements of an array as
+// separate arguments
+type SpreadNode struct {
+	Token     token.Token // The '...' token
+	ValueNode ExpressionNode
+}
+
+func (s *SpreadNode) expressionNode()      {}
+func (s *SpreadNode) TokenLiteral() string { return s.Token.Literal }
+func (s *SpreadNode) Pos() token.Position  { return s.Token.Start }
+func (s *SpreadNode) End() token.Position  { return endOf(s.ValueNode, s.Token) }
+func (s *SpreadNode) String() string       { return "..." + s.ValueNode.String() }
+
+// NamedArgumentNode A name: value call argument, passes the value to the
+// parameter with the name
+type NamedArgumentNode struct {
+	Token     token.Token // The name token
+	Name      *IdentifierNode
+	ValueNode ExpressionNode
+}
+
+func (na *NamedArgumentNode) expressionNode()      {}
+func (na *NamedArgumentNode) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgumentNode) Pos() token.Position  { return na.Token.Start }
+func (na *NamedArgumentNode) End() token.Position  { return endOf(na.ValueNode, na.Token) }
+func (na *NamedArgumentNode) String() string       { return na.Name.String() + ": " + na.ValueNode.String() }
+
 // CallExpressionNode Function call expression ast node
 type CallExpressionNode struct {
 	Token    token.Token    // The '(' token
 	FnNode   ExpressionNode // Identifier or FunctionLiteral
 	ArgNodes []ExpressionNode
+	EndToken token.Token // The ')' token
 }
 
 func (ce *CallExpressionNode) expressionNode()      {}
 func (ce *CallExpressionNode) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpressionNode) Pos() token.Position  { return posOf(ce.FnNode, ce.Token) }
+func (ce *CallExpressionNode) End() token.Position  { return ce.EndToken.End }
 func (ce *CallExpressionNode) String() string {
 	var out bytes.Buffer
 
@@ -171,15 +455,51 @@ type StringLiteralNode struct {
 func (sl *StringLiteralNode) expressionNode()      {}
 func (sl *StringLiteralNode) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteralNode) String() string       { return sl.Value }
+func (sl *StringLiteralNode) Pos() token.Position  { return sl.Token.Start }
+func (sl *StringLiteralNode) End() token.Position  { return sl.Token.End }
+
+// InterpolatedStringNode Interpolated string expression ast node, like
+// "hello ${name}". The text between the interpolations is kept as
+// StringLiteralNode parts.
+type InterpolatedStringNode struct {
+	Token    token.Token // The token.TEMPLATE_HEAD token
+	Parts    []ExpressionNode
+	EndToken token.Token // The token.TEMPLATE_TAIL token
+}
+
+func (is *InterpolatedStringNode) expressionNode()      {}
+func (is *InterpolatedStringNode) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedStringNode) Pos() token.Position  { return is.Token.Start }
+func (is *InterpolatedStringNode) End() token.Position  { return is.EndToken.End }
+func (is *InterpolatedStringNode) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for _, part := range is.Parts {
+		if str, ok := part.(*StringLiteralNode); ok {
+			out.WriteString(str.Value)
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
 
 // ArrayLiteralNode Array literal expression ast node
 type ArrayLiteralNode struct {
 	Token    token.Token // The '[' token
 	Elements []ExpressionNode
+	EndToken token.Token // The ']' token
 }
 
 func (al *ArrayLiteralNode) expressionNode()      {}
 func (al *ArrayLiteralNode) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteralNode) Pos() token.Position  { return al.Token.Start }
+func (al *ArrayLiteralNode) End() token.Position  { return al.EndToken.End }
 func (al *ArrayLiteralNode) String() string {
 	var out bytes.Buffer
 
@@ -197,13 +517,16 @@ func (al *ArrayLiteralNode) String() string {
 
 // IndexExpressionNode Index expression ast node
 type IndexExpressionNode struct {
-	Token token.Token // The '[' token
-	Left  ExpressionNode
-	Index ExpressionNode
+	Token    token.Token // The '[' token
+	Left     ExpressionNode
+	Index    ExpressionNode
+	EndToken token.Token // The ']' token
 }
 
 func (ie *IndexExpressionNode) expressionNode()      {}
 func (ie *IndexExpressionNode) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpressionNode) Pos() token.Position  { return posOf(ie.Left, ie.Token) }
+func (ie *IndexExpressionNode) End() token.Position  { return ie.EndToken.End }
 func (ie *IndexExpressionNode) String() string {
 	var out bytes.Buffer
 
@@ -218,18 +541,21 @@ func (ie *IndexExpressionNode) String() string {
 
 // HashLiteralNode Hash literal ast node
 type HashLiteralNode struct {
-	Token token.Token // The '{' token
-	Pairs map[ExpressionNode]ExpressionNode
+	Token    token.Token // The '{' token
+	Pairs    map[ExpressionNode]ExpressionNode
+	EndToken token.Token // The '}' token
 }
 
 func (hl *HashLiteralNode) expressionNode()      {}
 func (hl *HashLiteralNode) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteralNode) Pos() token.Position  { return hl.Token.Start }
+func (hl *HashLiteralNode) End() token.Position  { return hl.EndToken.End }
 func (hl *HashLiteralNode) String() string {
 	var out bytes.Buffer
 
 	strPairs := []string{}
-	for key, val := range hl.Pairs {
-		strPairs = append(strPairs, key.String()+" : "+val.String())
+	for _, key := range hl.Keys() {
+		strPairs = append(strPairs, key.String()+" : "+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -238,3 +564,17 @@ func (hl *HashLiteralNode) String() string {
 
 	return out.String()
 }
+
+// Keys returns the keys sorted by their source text. Both engines evaluate
+// the pairs in this order, so that they fail on the same pair.
+func (hl *HashLiteralNode) Keys() []ExpressionNode {
+	keys := make([]ExpressionNode, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	return keys
+}
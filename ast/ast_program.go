@@ -1,6 +1,9 @@
 package ast
 
-import "bytes"
+import (
+	"bytes"
+	"monkey/token"
+)
 
 // ProgramNode Top level program node
 type ProgramNode struct {
@@ -15,6 +18,20 @@ func (p *ProgramNode) TokenLiteral() string {
 	}
 }
 
+func (p *ProgramNode) Pos() token.Position {
+	if len(p.StatementNodes) > 0 {
+		return p.StatementNodes[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *ProgramNode) End() token.Position {
+	if len(p.StatementNodes) > 0 {
+		return p.StatementNodes[len(p.StatementNodes)-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *ProgramNode) String() string {
 	var out bytes.Buffer
 
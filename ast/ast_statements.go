@@ -3,24 +3,32 @@ package ast
 import (
 	"bytes"
 	"monkey/token"
+	"strings"
 )
 
 // Statements
 
 // LetStatementNode Let statement ast node
 type LetStatementNode struct {
-	Token     token.Token // the 'let' token
-	NameNode  IdentifierNode
-	ValueNode ExpressionNode
+	Token       token.Token // the 'let' token
+	NameNode    IdentifierNode
+	PatternNode ExpressionNode // array or hash pattern instead of the name, or nil
+	ValueNode   ExpressionNode
 }
 
 func (ls *LetStatementNode) statementNode()       {}
 func (ls *LetStatementNode) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatementNode) Pos() token.Position  { return ls.Token.Start }
+func (ls *LetStatementNode) End() token.Position  { return endOf(ls.ValueNode, ls.Token) }
 func (ls *LetStatementNode) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.NameNode.String())
+	if ls.PatternNode != nil {
+		out.WriteString(ls.PatternNode.String())
+	} else {
+		out.WriteString(ls.NameNode.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.ValueNode != nil {
@@ -40,6 +48,8 @@ type ReturnStatementNode struct {
 
 func (rs *ReturnStatementNode) statementNode()       {}
 func (rs *ReturnStatementNode) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatementNode) Pos() token.Position  { return rs.Token.Start }
+func (rs *ReturnStatementNode) End() token.Position  { return endOf(rs.ReturnValueNode, rs.Token) }
 func (rs *ReturnStatementNode) String() string {
 	var out bytes.Buffer
 
@@ -62,6 +72,8 @@ type ExpressionStatementNode struct {
 
 func (es *ExpressionStatementNode) statementNode()       {}
 func (es *ExpressionStatementNode) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatementNode) Pos() token.Position  { return posOf(es.ExpressionNode, es.Token) }
+func (es *ExpressionStatementNode) End() token.Position  { return endOf(es.ExpressionNode, es.Token) }
 func (es *ExpressionStatementNode) String() string {
 	if es.ExpressionNode != nil {
 		return es.ExpressionNode.String()
@@ -74,10 +86,13 @@ func (es *ExpressionStatementNode) String() string {
 type BlockStatementNode struct {
 	Token          token.Token // the { token
 	StatementNodes []StatementNode
+	EndToken       token.Token // the } token
 }
 
 func (bs *BlockStatementNode) statementNode()       {}
 func (bs *BlockStatementNode) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatementNode) Pos() token.Position  { return bs.Token.Start }
+func (bs *BlockStatementNode) End() token.Position  { return bs.EndToken.End }
 func (bs *BlockStatementNode) String() string {
 	var out bytes.Buffer
 
@@ -87,3 +102,94 @@ func (bs *BlockStatementNode) String() string {
 
 	return out.String()
 }
+
+// WhileStatementNode While loop ast node
+type WhileStatementNode struct {
+	Token         token.Token // the 'while' token
+	ConditionNode ExpressionNode
+	BodyNode      *BlockStatementNode
+}
+
+func (ws *WhileStatementNode) statementNode()       {}
+func (ws *WhileStatementNode) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatementNode) Pos() token.Position  { return ws.Token.Start }
+func (ws *WhileStatementNode) End() token.Position {
+	if ws.BodyNode != nil {
+		return ws.BodyNode.End()
+	}
+	return endOf(ws.ConditionNode, ws.Token)
+}
+func (ws *WhileStatementNode) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while ")
+	out.WriteString(ws.ConditionNode.String())
+	out.WriteString(" ")
+	out.WriteString(ws.BodyNode.String())
+
+	return out.String()
+}
+
+// ForStatementNode C-style for loop ast node, all three clauses are optional
+type ForStatementNode struct {
+	Token         token.Token    // the 'for' token
+	InitNode      StatementNode  // runs once before the loop
+	ConditionNode ExpressionNode // checked before each iteration, nil loops forever
+	UpdateNode    StatementNode  // runs after each iteration
+	BodyNode      *BlockStatementNode
+}
+
+func (fs *ForStatementNode) statementNode()       {}
+func (fs *ForStatementNode) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatementNode) Pos() token.Position  { return fs.Token.Start }
+func (fs *ForStatementNode) End() token.Position {
+	if fs.BodyNode != nil {
+		return fs.BodyNode.End()
+	}
+	return fs.Token.End
+}
+func (fs *ForStatementNode) String() string {
+	var out bytes.Buffer
+
+	clause := func(n Node) string {
+		if n == nil {
+			return ""
+		}
+		return strings.TrimSuffix(n.String(), ";")
+	}
+
+	out.WriteString("for (")
+	out.WriteString(clause(fs.InitNode))
+	out.WriteString("; ")
+	if fs.ConditionNode != nil {
+		out.WriteString(fs.ConditionNode.String())
+	}
+	out.WriteString("; ")
+	out.WriteString(clause(fs.UpdateNode))
+	out.WriteString(") ")
+	out.WriteString(fs.BodyNode.String())
+
+	return out.String()
+}
+
+// BreakStatementNode Break statement ast node
+type BreakStatementNode struct {
+	Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatementNode) statementNode()       {}
+func (bs *BreakStatementNode) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatementNode) Pos() token.Position  { return bs.Token.Start }
+func (bs *BreakStatementNode) End() token.Position  { return bs.Token.End }
+func (bs *BreakStatementNode) String() string       { return "break;" }
+
+// ContinueStatementNode Continue statement ast node
+type ContinueStatementNode struct {
+	Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatementNode) statementNode()       {}
+func (cs *ContinueStatementNode) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatementNode) Pos() token.Position  { return cs.Token.Start }
+func (cs *ContinueStatementNode) End() token.Position  { return cs.Token.End }
+func (cs *ContinueStatementNode) String() string       { return "continue;" }
@@ -36,6 +36,35 @@ func (inst Instructions) String() string {
 	return out.String()
 }
 
+// Annotated disassembles the instructions like String, adding the source
+// position of each instruction from the source map
+func (inst Instructions) Annotated(sourceMap *SourceMap) string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(inst) {
+		def, err := Lookup(inst[i])
+		if err != nil {
+			_, _ = fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, inst[i+1:])
+		line := fmt.Sprintf("%04d %s", i, inst.fmtInstruction(def, operands))
+
+		if span, ok := sourceMap.Lookup(i); ok {
+			_, _ = fmt.Fprintf(&out, "%-28s ; %s\n", line, span.Start)
+		} else {
+			_, _ = fmt.Fprintf(&out, "%s\n", line)
+		}
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
 func (inst Instructions) fmtInstruction(def *Definition, operands []int) string {
 	operandCount := len(def.OperandWidths)
 
@@ -48,6 +48,44 @@ const (
 	OpGetFree
 
 	OpCurrentClosure
+
+	OpConcat
+
+	OpMod
+	OpLessThan
+	OpLessThanOrEqual
+	OpGreaterThanOrEqual
+
+	OpJumpNotTruthyOrPop
+	OpJumpTruthyOrPop
+
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpBitNot
+	OpShiftLeft
+	OpShiftRight
+
+	OpSetIndex
+	OpDupPair
+
+	OpSetFree
+	OpCaptureLocal
+	OpCaptureFree
+
+	OpDup
+	OpJumpTable
+
+	OpMatchType
+	OpMatchArray
+	OpHasKey
+	OpArrayRest
+	OpMatchFailed
+
+	OpJumpIfPassed
+	OpSpread
+	OpCallSpread
+	OpCallNamed
 )
 
 var definitions = map[Opcode]*Definition{
@@ -97,4 +135,68 @@ var definitions = map[Opcode]*Definition{
 	OpGetFree: {"OpGetFree", []int{1}},
 
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+
+	OpConcat: {"OpConcat", []int{2}},
+
+	OpMod:                {"OpMod", []int{}},
+	OpLessThan:           {"OpLessThan", []int{}},
+	OpLessThanOrEqual:    {"OpLessThanOrEqual", []int{}},
+	OpGreaterThanOrEqual: {"OpGreaterThanOrEqual", []int{}},
+
+	// Jump and keep the condition on the stack, or pop it and go on, for
+	// the short-circuiting && and ||
+	OpJumpNotTruthyOrPop: {"OpJumpNotTruthyOrPop", []int{2}},
+	OpJumpTruthyOrPop:    {"OpJumpTruthyOrPop", []int{2}},
+
+	OpBitAnd:     {"OpBitAnd", []int{}},
+	OpBitOr:      {"OpBitOr", []int{}},
+	OpBitXor:     {"OpBitXor", []int{}},
+	OpBitNot:     {"OpBitNot", []int{}},
+	OpShiftLeft:  {"OpShiftLeft", []int{}},
+	OpShiftRight: {"OpShiftRight", []int{}},
+
+	// Pop a value, an index and the indexed object, store the value at the
+	// index and push it again
+	OpSetIndex: {"OpSetIndex", []int{}},
+	// Push the two topmost elements again, for compound index assignments
+	OpDupPair: {"OpDupPair", []int{}},
+
+	OpSetFree: {"OpSetFree", []int{1}},
+	// Push the upvalue of a local or a free variable, for OpClosure
+	OpCaptureLocal: {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:  {"OpCaptureFree", []int{1}},
+
+	OpDup: {"OpDup", []int{}},
+	// Pop an integer and jump to the OpJump at that offset from the minimum
+	// in the constants, of the given number of OpJumps that follow. Other
+	// values take the extra OpJump after them.
+	OpJumpTable: {"OpJumpTable", []int{2, 2}},
+
+	// Pop a value and push whether it has the type named by the string
+	// constant, for type check patterns
+	OpMatchType: {"OpMatchType", []int{2}},
+	// Pop a value and push whether it is an array of the given length, or
+	// of at least that length if the second operand is 1
+	OpMatchArray: {"OpMatchArray", []int{2, 1}},
+	// Pop a key and a hash and push whether the hash has the key
+	OpHasKey: {"OpHasKey", []int{}},
+	// Pop an array and push a new array of its elements from the index on
+	OpArrayRest: {"OpArrayRest", []int{2}},
+	// Pop the value a let pattern did not match and fail
+	OpMatchFailed: {"OpMatchFailed", []int{}},
+
+	// Jump if the call passed an argument for the parameter with the given
+	// local index, to skip the code of its default value
+	OpJumpIfPassed: {"OpJumpIfPassed", []int{1, 2}},
+	// Pop a value and an array of arguments and push a new array of the
+	// arguments followed by the elements of the value, for ...value
+	OpSpread: {"OpSpread", []int{}},
+	// Pop the given number of arguments passed by name and an array of the
+	// other arguments, and call the function below them. The names are the
+	// string constants from the given index on.
+	OpCallSpread: {"OpCallSpread", []int{1, 2}},
+	// Call with the given number of arguments, of which the given number of
+	// last ones are passed by the names in the string constants from the
+	// given index on
+	OpCallNamed: {"OpCallNamed", []int{1, 1, 2}},
 }
@@ -1,6 +1,9 @@
 package code
 
-import "testing"
+import (
+	"monkey/token"
+	"testing"
+)
 
 func TestMake(t *testing.T) {
 	tests := []struct {
@@ -12,6 +15,10 @@ func TestMake(t *testing.T) {
 		{OpAdd, []int{}, []byte{byte(OpAdd)}},
 		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
 		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpJumpTable, []int{3, 258}, []byte{byte(OpJumpTable), 0, 3, 1, 2}},
+		{OpMatchArray, []int{2, 1}, []byte{byte(OpMatchArray), 0, 2, 1}},
+		{OpJumpIfPassed, []int{2, 260}, []byte{byte(OpJumpIfPassed), 2, 1, 4}},
+		{OpCallNamed, []int{3, 2, 258}, []byte{byte(OpCallNamed), 3, 2, 1, 2}},
 	}
 
 	for _, tt := range tests {
@@ -89,3 +96,58 @@ func TestReadOperands(t *testing.T) {
 		}
 	}
 }
+
+func TestSourceMap(t *testing.T) {
+	span := func(line, column int) token.Span {
+		return token.Span{
+			Start: token.Position{Line: line, Column: column},
+			End:   token.Position{Line: line, Column: column + 1},
+		}
+	}
+
+	sm := NewSourceMap()
+	sm.Add(0, span(1, 1))
+	sm.Add(3, span(1, 1)) // same span, merged into the previous entry
+	sm.Add(4, span(1, 5))
+	sm.Add(7, span(2, 1))
+	sm.Add(7, span(2, 3)) // same offset, replaces the previous entry
+	sm.Add(9, span(3, 1))
+
+	if len(sm.Entries) != 4 {
+		t.Fatalf("wrong number of entries. want=4, got=%d (%+v)", len(sm.Entries), sm.Entries)
+	}
+
+	tests := []struct {
+		offset   int
+		expected string
+		found    bool
+	}{
+		{0, "1:1", true},
+		{3, "1:1", true},
+		{4, "1:5", true},
+		{6, "1:5", true},
+		{7, "2:3", true},
+		{100, "3:1", true},
+	}
+
+	for _, tt := range tests {
+		actual, ok := sm.Lookup(tt.offset)
+		if ok != tt.found {
+			t.Fatalf("lookup of %d found=%t, want=%t", tt.offset, ok, tt.found)
+		}
+		if actual.Start.String() != tt.expected {
+			t.Errorf("wrong span for offset %d. want=%s, got=%s",
+				tt.offset, tt.expected, actual.Start)
+		}
+	}
+
+	sm.Truncate(7)
+	if actual, _ := sm.Lookup(8); actual.Start.String() != "1:5" {
+		t.Errorf("wrong span after truncate. want=1:5, got=%s", actual.Start)
+	}
+
+	var missing *SourceMap
+	if _, ok := missing.Lookup(0); ok {
+		t.Errorf("lookup in nil source map succeeded")
+	}
+}
@@ -0,0 +1,65 @@
+package code
+
+import (
+	"monkey/token"
+	"sort"
+)
+
+// SourceMap maps instruction offsets back to the source they were compiled
+// from. Like a DWARF line program or Python's co_lnotab only changes are
+// recorded: an entry covers every instruction up to the next entry.
+type SourceMap struct {
+	Entries []SourceMapEntry // sorted by Offset
+}
+
+type SourceMapEntry struct {
+	Offset int
+	Span   token.Span
+}
+
+func NewSourceMap() *SourceMap {
+	return &SourceMap{Entries: []SourceMapEntry{}}
+}
+
+// Add records that the instruction at offset was compiled from span
+func (sm *SourceMap) Add(offset int, span token.Span) {
+	if n := len(sm.Entries); n > 0 {
+		last := &sm.Entries[n-1]
+
+		if last.Span == span {
+			return
+		}
+
+		if last.Offset == offset {
+			last.Span = span
+			return
+		}
+	}
+
+	sm.Entries = append(sm.Entries, SourceMapEntry{Offset: offset, Span: span})
+}
+
+// Truncate drops all entries for instructions at or after offset
+func (sm *SourceMap) Truncate(offset int) {
+	i := sort.Search(len(sm.Entries), func(i int) bool {
+		return sm.Entries[i].Offset >= offset
+	})
+
+	sm.Entries = sm.Entries[:i]
+}
+
+// Lookup returns the source span of the instruction at offset
+func (sm *SourceMap) Lookup(offset int) (token.Span, bool) {
+	if sm == nil {
+		return token.Span{}, false
+	}
+
+	i := sort.Search(len(sm.Entries), func(i int) bool {
+		return sm.Entries[i].Offset > offset
+	})
+	if i == 0 {
+		return token.Span{}, false
+	}
+
+	return sm.Entries[i-1].Span, true
+}
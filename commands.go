@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/repl"
+	"monkey/token"
+	"monkey/vm"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const compiledExt = ".mkc"
+
+// argsName is the global holding the script arguments. It is defined
+// before anything else, so compiled files always find it in global slot 0.
+const argsName = "args"
+
+const (
+	engineVM        = "vm"
+	engineEvaluator = "evaluator"
+)
+
+func replCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	engineName := flags.String("engine", engineVM, "execution engine, vm, evaluator or both")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintf(stderr, "repl takes no arguments\n\n%s", usage)
+		return exitUsage
+	}
+
+	engine, ok := repl.ParseEngine(*engineName)
+	if !ok {
+		fmt.Fprintf(stderr, "unknown engine %q, want %s, %s or both\n", *engineName, engineVM, engineEvaluator)
+		return exitUsage
+	}
+
+	startRepl(stdout, engine)
+	return exitOK
+}
+
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	engine := flags.String("engine", engineVM, "execution engine, vm or evaluator")
+	overflowName := flags.String("overflow", string(object.OverflowWrap),
+		"what integer overflow does, wrap, error or promote to a big integer")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() < 1 {
+		fmt.Fprintf(stderr, "run expects an input file\n\n%s", usage)
+		return exitUsage
+	}
+
+	overflow, ok := object.ParseOverflowPolicy(*overflowName)
+	if !ok {
+		fmt.Fprintf(stderr, "unknown overflow policy %q, want wrap, error or promote\n", *overflowName)
+		return exitUsage
+	}
+
+	filename := flags.Arg(0)
+	scriptArgs := newArgsArray(flags.Args()[1:])
+	object.Stdout = stdout
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitIOError
+	}
+
+	switch *engine {
+	case engineVM:
+		bytecode, code := loadBytecode(stderr, filename, content)
+		if code != exitOK {
+			return code
+		}
+
+		globals := make([]object.Object, vm.GlobalsSize)
+		globals[0] = scriptArgs
+
+		machine := vm.NewWithConfig(bytecode, vm.Config{Globals: globals, Overflow: overflow})
+		if err := machine.Run(); err != nil {
+			printRuntimeError(stderr, err)
+			return exitRuntimeError
+		}
+
+	case engineEvaluator:
+		if isCompiled(content) {
+			fmt.Fprintf(stderr, "%s: compiled files can only be run by the vm engine\n", filename)
+			return exitUsage
+		}
+
+		program, code := parseSource(stderr, filename, string(content))
+		if code != exitOK {
+			return code
+		}
+
+		env := object.NewEnvironment()
+		env.Set(argsName, scriptArgs)
+
+		result := evaluator.New(evaluator.Config{Overflow: overflow}).Eval(program, env)
+		if errObj, ok := result.(*object.ErrorObject); ok {
+			fmt.Fprintf(stderr, "runtime error: %s\n", errObj.Message)
+			return exitRuntimeError
+		}
+
+	default:
+		fmt.Fprintf(stderr, "unknown engine %q, want %s or %s\n", *engine, engineVM, engineEvaluator)
+		return exitUsage
+	}
+
+	return exitOK
+}
+
+func buildCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "output file, defaults to the input file with a "+compiledExt+" extension")
+	strip := flags.Bool("strip", false, "omit debug info (source maps) from the output")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(stderr, "build expects exactly one input file\n\n%s", usage)
+		return exitUsage
+	}
+
+	filename := flags.Arg(0)
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitIOError
+	}
+
+	bytecode, code := compileSource(stderr, filename, string(content))
+	if code != exitOK {
+		return code
+	}
+
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(filename, filepath.Ext(filename)) + compiledExt
+	}
+
+	var buf bytes.Buffer
+	if err := bytecode.Encode(&buf, !*strip); err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", filename, err)
+		return exitCompileError
+	}
+
+	if err := os.WriteFile(out, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitIOError
+	}
+
+	return exitOK
+}
+
+func tokensCommand(args []string, stdout, stderr io.Writer) int {
+	filename, content, code := readSingleFile(stderr, "tokens", args)
+	if code != exitOK {
+		return code
+	}
+
+	l := lexer.NewWithFilename(filename, content)
+	for {
+		tk := l.NextToken()
+		for _, trivia := range tk.LeadingTrivia {
+			fmt.Fprintf(stdout, "%-16s %-10s %q\n", trivia.Start, trivia.Kind, trivia.Text)
+		}
+		if tk.Type == token.EOF {
+			break
+		}
+		fmt.Fprintf(stdout, "%-16s %-10s %q\n", tk.Start, tk.Type, tk.Literal)
+	}
+
+	return exitOK
+}
+
+func astCommand(args []string, stdout, stderr io.Writer) int {
+	filename, content, code := readSingleFile(stderr, "ast", args)
+	if code != exitOK {
+		return code
+	}
+
+	program, code := parseSource(stderr, filename, content)
+	if code != exitOK {
+		return code
+	}
+
+	for _, s := range program.StatementNodes {
+		fmt.Fprintf(stdout, "%-16s %s\n", s.Pos(), s.String())
+	}
+
+	return exitOK
+}
+
+func disasmCommand(args []string, stdout, stderr io.Writer) int {
+	filename, content, code := readSingleFile(stderr, "disasm", args)
+	if code != exitOK {
+		return code
+	}
+
+	bytecode, code := loadBytecode(stderr, filename, []byte(content))
+	if code != exitOK {
+		return code
+	}
+
+	fmt.Fprintf(stdout, "%s:\n%s", vm.MainFunctionName, bytecode.Instructions.Annotated(bytecode.SourceMap))
+
+	for i, constant := range bytecode.Constants {
+		switch constant := constant.(type) {
+		case *object.CompiledFnObject:
+			name := constant.Name
+			if name == "" {
+				name = "<anonymous>"
+			}
+			fmt.Fprintf(stdout, "\nconstant %d, fn %s (params=%d, locals=%d):\n%s", i, name,
+				constant.NumParameters, constant.NumLocals,
+				constant.Instructions.Annotated(constant.SourceMap))
+		case *object.StringObject:
+			fmt.Fprintf(stdout, "\nconstant %d, %s %q\n", i, constant.Type(), constant.Value)
+		default:
+			fmt.Fprintf(stdout, "\nconstant %d, %s %s\n", i, constant.Type(), constant.Inspect())
+		}
+	}
+
+	return exitOK
+}
+
+func readSingleFile(stderr io.Writer, command string, args []string) (string, string, int) {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "%s expects exactly one input file\n\n%s", command, usage)
+		return "", "", exitUsage
+	}
+
+	content, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return "", "", exitIOError
+	}
+
+	return args[0], string(content), exitOK
+}
+
+func isCompiled(content []byte) bool {
+	return bytes.HasPrefix(content, compiler.Magic[:])
+}
+
+// loadBytecode decodes compiled files and compiles everything else
+func loadBytecode(stderr io.Writer, filename string, content []byte) (*compiler.Bytecode, int) {
+	if isCompiled(content) {
+		bytecode, err := compiler.Decode(bytes.NewReader(content))
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", filename, err)
+			return nil, exitIOError
+		}
+
+		return bytecode, exitOK
+	}
+
+	return compileSource(stderr, filename, string(content))
+}
+
+func parseSource(stderr io.Writer, filename, source string) (*ast.ProgramNode, int) {
+	p := parser.New(lexer.NewWithFilename(filename, source))
+	program := p.ParseProgram()
+
+	for _, d := range p.Diagnostics() {
+		fmt.Fprintln(stderr, d)
+	}
+	if len(p.Errors()) != 0 {
+		return nil, exitParseError
+	}
+
+	return program, exitOK
+}
+
+func compileSource(stderr io.Writer, filename, source string) (*compiler.Bytecode, int) {
+	program, code := parseSource(stderr, filename, source)
+	if code != exitOK {
+		return nil, code
+	}
+
+	symbolTable := compiler.NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	symbolTable.Define(argsName)
+
+	comp := compiler.NewWithState([]object.Object{}, symbolTable)
+	err := comp.Compile(program)
+	for _, w := range comp.Warnings() {
+		fmt.Fprintf(stderr, "%s: warning: %s\n", w.Pos, w.Message)
+	}
+	if err != nil {
+		for _, e := range comp.Errors() {
+			fmt.Fprintln(stderr, e)
+		}
+		return nil, exitCompileError
+	}
+
+	return comp.Bytecode(), exitOK
+}
+
+func newArgsArray(args []string) *object.ArrayObject {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.StringObject{Value: arg}
+	}
+
+	return &object.ArrayObject{Elements: elements}
+}
+
+func printRuntimeError(stderr io.Writer, err error) {
+	if runtimeErr, ok := err.(*vm.RuntimeError); ok {
+		fmt.Fprintf(stderr, "runtime error: %s\n", runtimeErr.Trace())
+		return
+	}
+
+	fmt.Fprintf(stderr, "runtime error: %s\n", err)
+}
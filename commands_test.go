@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"monkey/compiler"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the tokens and ast commands")
+
+type command func(args []string, stdout, stderr io.Writer) int
+
+type commandTestCase struct {
+	name           string
+	command        command
+	args           []string
+	expectedCode   int
+	expectedStdout string // exact output, if set
+	expectedStderr string // substring of the error output, if set
+}
+
+// writeFile creates a file in dir and returns its path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func runCommandTests(t *testing.T, tests []commandTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := tt.command(tt.args, &stdout, &stderr)
+		object.Stdout = os.Stdout
+
+		if code != tt.expectedCode {
+			t.Errorf("%s: wrong exit code. want=%d, got=%d (stderr %q)",
+				tt.name, tt.expectedCode, code, stderr.String())
+		}
+		if tt.expectedStdout != "" && stdout.String() != tt.expectedStdout {
+			t.Errorf("%s: wrong output. want=%q, got=%q", tt.name, tt.expectedStdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), tt.expectedStderr) {
+			t.Errorf("%s: wrong error output. want it to contain %q, got=%q",
+				tt.name, tt.expectedStderr, stderr.String())
+		}
+		if tt.expectedStderr == "" && stderr.Len() != 0 {
+			t.Errorf("%s: unexpected error output %q", tt.name, stderr.String())
+		}
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	dir := t.TempDir()
+	script := writeFile(t, dir, "script.monkey", `puts(len(args), args);`)
+	syntaxError := writeFile(t, dir, "syntax.monkey", `let = 5;`)
+	undefined := writeFile(t, dir, "undefined.monkey", `puts(x);`)
+	division := writeFile(t, dir, "division.monkey", "let f = fn(a) { a / 0 };\nf(1);")
+	overflow := writeFile(t, dir, "overflow.monkey", `puts(9223372036854775807 + 1);`)
+	corrupted := writeFile(t, dir, "corrupted.mkc", string(compiler.Magic[:])+"\x00")
+
+	tests := []commandTestCase{
+		{"vm", runCommand, []string{script, "a", "b"}, exitOK, "2\n[a, b]\n", ""},
+		{"evaluator", runCommand, []string{"-engine", "evaluator", script, "a"}, exitOK, "1\n[a]\n", ""},
+		{"no arguments", runCommand, []string{script}, exitOK, "0\n[]\n", ""},
+		{"missing file", runCommand, []string{filepath.Join(dir, "missing.monkey")}, exitIOError, "", "no such file"},
+		{"no file", runCommand, []string{}, exitUsage, "", "run expects an input file"},
+		{"unknown flag", runCommand, []string{"-fast", script}, exitUsage, "", "flag provided but not defined: -fast"},
+		{"unknown engine", runCommand, []string{"-engine", "jit", script}, exitUsage, "", `unknown engine "jit"`},
+		{"unknown overflow", runCommand, []string{"-overflow", "saturate", script}, exitUsage, "", `unknown overflow policy "saturate"`},
+		{"syntax error", runCommand, []string{syntaxError}, exitParseError, "", "syntax.monkey:1:5: error[P001]"},
+		{"syntax error evaluator", runCommand, []string{"-engine", "evaluator", syntaxError}, exitParseError, "", "error[P001]"},
+		{"compile error", runCommand, []string{undefined}, exitCompileError, "", "undefined variable x"},
+		{"undefined evaluator", runCommand, []string{"-engine", "evaluator", undefined}, exitRuntimeError, "", "runtime error: Identifier not found: x"},
+		{"runtime error", runCommand, []string{division}, exitRuntimeError, "", "runtime error: division by zero\n\tat f (" + division + ":1:17"},
+		{"runtime error evaluator", runCommand, []string{"-engine", "evaluator", division}, exitRuntimeError, "", "runtime error: division by zero"},
+		{"overflow wrap", runCommand, []string{overflow}, exitOK, "-9223372036854775808\n", ""},
+		{"overflow promote", runCommand, []string{"-overflow", "promote", overflow}, exitOK, "9223372036854775808\n", ""},
+		{"overflow error", runCommand, []string{"-overflow", "error", overflow}, exitRuntimeError, "", "runtime error: integer overflow"},
+		{"overflow error evaluator", runCommand, []string{"-engine", "evaluator", "-overflow", "error", overflow}, exitRuntimeError, "", "runtime error: integer overflow"},
+		{"corrupted compiled file", runCommand, []string{corrupted}, exitIOError, "", corrupted + ": "},
+		{"compiled file evaluator", runCommand, []string{"-engine", "evaluator", corrupted}, exitUsage, "", "compiled files can only be run by the vm engine"},
+	}
+
+	runCommandTests(t, tests)
+}
+
+func TestBuildCommand(t *testing.T) {
+	dir := t.TempDir()
+	script := writeFile(t, dir, "script.monkey", "let f = fn(a) { 10 / a };\nputs(args);\nf(len(args));")
+	syntaxError := writeFile(t, dir, "syntax.monkey", `let = 5;`)
+	undefined := writeFile(t, dir, "undefined.monkey", `puts(x);`)
+	compiled := filepath.Join(dir, "script.mkc")
+	stripped := filepath.Join(dir, "stripped.mkc")
+
+	tests := []commandTestCase{
+		{"build", buildCommand, []string{script}, exitOK, "", ""},
+		// args is in global slot 0 of compiled files too
+		{"run compiled", runCommand, []string{compiled, "x"}, exitOK, "[x]\n", ""},
+		{"run compiled error", runCommand, []string{compiled}, exitRuntimeError, "[]\n", "division by zero\n\tat f (" + script + ":1:17"},
+		{"build stripped", buildCommand, []string{"-strip", "-o", stripped, script}, exitOK, "", ""},
+		{"run stripped", runCommand, []string{stripped, "x"}, exitOK, "[x]\n", ""},
+		{"run stripped error", runCommand, []string{stripped}, exitRuntimeError, "", "division by zero\n\tat f (offset"},
+		{"no file", buildCommand, []string{}, exitUsage, "", "build expects exactly one input file"},
+		{"two files", buildCommand, []string{script, script}, exitUsage, "", "build expects exactly one input file"},
+		{"missing file", buildCommand, []string{filepath.Join(dir, "missing.monkey")}, exitIOError, "", "no such file"},
+		{"unwritable output", buildCommand, []string{"-o", filepath.Join(dir, "missing", "out.mkc"), script}, exitIOError, "", "no such file"},
+		{"syntax error", buildCommand, []string{syntaxError}, exitParseError, "", "error[P001]"},
+		{"compile error", buildCommand, []string{undefined}, exitCompileError, "", "undefined variable x"},
+	}
+
+	runCommandTests(t, tests)
+}
+
+func TestDisasmCommand(t *testing.T) {
+	dir := t.TempDir()
+	script := writeFile(t, dir, "script.monkey", `let add = fn(a, b) { a + b }; add(1, "x");`)
+
+	var stdout, stderr bytes.Buffer
+	if code := buildCommand([]string{script}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("build failed with %d: %s", code, stderr.String())
+	}
+	compiled := filepath.Join(dir, "script.mkc")
+
+	for _, file := range []string{script, compiled} {
+		stdout.Reset()
+		stderr.Reset()
+		if code := disasmCommand([]string{file}, &stdout, &stderr); code != exitOK {
+			t.Fatalf("disasm of %s failed with %d: %s", file, code, stderr.String())
+		}
+
+		for _, expected := range []string{
+			"<main>:\n",
+			"OpClosure 0 0",
+			"constant 0, fn add (params=2, locals=2):\n",
+			"OpAdd",
+			"constant 2, STRING \"x\"\n",
+		} {
+			if !strings.Contains(stdout.String(), expected) {
+				t.Errorf("disasm of %s does not contain %q:\n%s", file, expected, stdout.String())
+			}
+		}
+	}
+
+	tests := []commandTestCase{
+		{"no file", disasmCommand, []string{}, exitUsage, "", "disasm expects exactly one input file"},
+		{"missing file", disasmCommand, []string{filepath.Join(dir, "missing.monkey")}, exitIOError, "", "no such file"},
+	}
+
+	runCommandTests(t, tests)
+}
+
+func TestTokensAndAstCommands(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		command command
+	}{
+		{"tokens", tokensCommand},
+		{"ast", astCommand},
+	} {
+		source := filepath.Join("testdata", "cli", "example.monkey")
+
+		var stdout, stderr bytes.Buffer
+		if code := c.command([]string{source}, &stdout, &stderr); code != exitOK {
+			t.Fatalf("%s failed with %d: %s", c.name, code, stderr.String())
+		}
+
+		golden := strings.TrimSuffix(source, ".monkey") + "." + c.name
+		if *update {
+			if err := os.WriteFile(golden, stdout.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s, run the tests with -update to create it", err)
+		}
+		if stdout.String() != string(expected) {
+			t.Errorf("%s output does not match %s.\nexpected:\n%s\ngot:\n%s", c.name, golden, expected, stdout.String())
+		}
+	}
+
+	invalid := filepath.Join("testdata", "cli", "invalid.monkey")
+	missing := filepath.Join("testdata", "cli", "missing.monkey")
+
+	tests := []commandTestCase{
+		{"tokens no file", tokensCommand, []string{}, exitUsage, "", "tokens expects exactly one input file"},
+		{"tokens two files", tokensCommand, []string{invalid, invalid}, exitUsage, "", "tokens expects exactly one input file"},
+		{"tokens missing file", tokensCommand, []string{missing}, exitIOError, "", "no such file"},
+		{"ast of invalid", astCommand, []string{invalid}, exitParseError, "", "invalid.monkey:1:5: error[P001]: expected identifier, got '='"},
+		{"ast no file", astCommand, []string{}, exitUsage, "", "ast expects exactly one input file"},
+		{"ast missing file", astCommand, []string{missing}, exitIOError, "", "no such file"},
+	}
+
+	runCommandTests(t, tests)
+}
@@ -0,0 +1,468 @@
+package compiler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"monkey/code"
+	"monkey/object"
+	"monkey/token"
+)
+
+// Compiled bytecode files (.mkc) have the following layout, all integers
+// are varints unless noted otherwise:
+//
+//	magic      "MKC\x00"
+//	version    uint16, big endian
+//	flags      byte
+//	files      file name table, only with flagDebugInfo
+//	constants  count, then a tag byte and the payload for each constant,
+//	           floats are stored as IEEE 754 bits in a big endian uint64,
+//	           big integers as their sign and the bytes of their magnitude
+//	main       instructions of the main program
+//	sourcemap  source map of the main program, only with flagDebugInfo
+//
+// Builtins are referenced by index, so files only run with a binary that
+// has the same builtin table as the one that compiled them.
+
+var Magic = [4]byte{'M', 'K', 'C', 0}
+
+const FormatVersion uint16 = 4
+
+const (
+	flagDebugInfo byte = 1 << iota
+)
+
+const (
+	tagInt byte = iota + 1
+	tagString
+	tagCompiledFn
+	tagFloat
+	tagBigInt
+)
+
+// maxLength guards allocations when reading corrupted files
+const maxLength = 1 << 28
+
+var ErrInvalidMagic = errors.New("not a compiled monkey file")
+
+// Encode writes the bytecode in the binary file format. The source maps are
+// only written when withDebugInfo is set.
+func (b *Bytecode) Encode(w io.Writer, withDebugInfo bool) error {
+	bw := bufio.NewWriter(w)
+	e := &encoder{w: bw, debugInfo: withDebugInfo, files: map[string]uint64{}}
+
+	e.writeBytes(Magic[:])
+	e.writeBytes([]byte{byte(FormatVersion >> 8), byte(FormatVersion)})
+
+	var flags byte
+	if withDebugInfo {
+		flags |= flagDebugInfo
+	}
+	e.writeBytes([]byte{flags})
+
+	if withDebugInfo {
+		e.writeFileTable(b)
+	}
+
+	e.writeUvarint(uint64(len(b.Constants)))
+	for _, c := range b.Constants {
+		e.writeConstant(c)
+	}
+
+	e.writeInstructions(b.Instructions)
+	if withDebugInfo {
+		e.writeSourceMap(b.SourceMap)
+	}
+
+	if e.err != nil {
+		return e.err
+	}
+
+	return bw.Flush()
+}
+
+// Decode reads bytecode written by Encode
+func Decode(r io.Reader) (*Bytecode, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+
+	var magic [4]byte
+	d.readFull(magic[:])
+	if d.err != nil {
+		return nil, d.err
+	}
+	if magic != Magic {
+		return nil, ErrInvalidMagic
+	}
+
+	var header [3]byte
+	d.readFull(header[:])
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	version := uint16(header[0])<<8 | uint16(header[1])
+	if version != FormatVersion {
+		return nil, fmt.Errorf("unsupported bytecode version %d, want %d", version, FormatVersion)
+	}
+	d.debugInfo = header[2]&flagDebugInfo != 0
+
+	if d.debugInfo {
+		d.readFileTable()
+	}
+
+	count := d.readLength()
+	constants := []object.Object{}
+	for i := 0; i < count && d.err == nil; i++ {
+		constants = append(constants, d.readConstant())
+	}
+
+	bytecode := &Bytecode{
+		Instructions: d.readInstructions(),
+		Constants:    constants,
+	}
+	if d.debugInfo {
+		bytecode.SourceMap = d.readSourceMap()
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	return bytecode, nil
+}
+
+type encoder struct {
+	w         *bufio.Writer
+	err       error
+	debugInfo bool
+	files     map[string]uint64
+}
+
+func (e *encoder) writeBytes(b []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(b)
+}
+
+func (e *encoder) writeUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	e.writeBytes(buf[:n])
+}
+
+func (e *encoder) writeVarint(v int64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	e.writeBytes(buf[:n])
+}
+
+func (e *encoder) writeFloat(v float64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
+	e.writeBytes(buf[:])
+}
+
+func (e *encoder) writeBigInt(v *big.Int) {
+	e.writeVarint(int64(v.Sign()))
+	e.writeString(string(v.Bytes()))
+}
+
+func (e *encoder) writeBool(v bool) {
+	if v {
+		e.writeBytes([]byte{1})
+	} else {
+		e.writeBytes([]byte{0})
+	}
+}
+
+func (e *encoder) writeString(s string) {
+	e.writeUvarint(uint64(len(s)))
+	e.writeBytes([]byte(s))
+}
+
+func (e *encoder) writeInstructions(ins code.Instructions) {
+	e.writeUvarint(uint64(len(ins)))
+	e.writeBytes(ins)
+}
+
+func (e *encoder) writeConstant(obj object.Object) {
+	switch obj := obj.(type) {
+	case *object.IntObject:
+		e.writeBytes([]byte{tagInt})
+		e.writeVarint(obj.Value)
+
+	case *object.BigIntObject:
+		e.writeBytes([]byte{tagBigInt})
+		e.writeBigInt(obj.Value)
+
+	case *object.FloatObject:
+		e.writeBytes([]byte{tagFloat})
+		e.writeFloat(obj.Value)
+
+	case *object.StringObject:
+		e.writeBytes([]byte{tagString})
+		e.writeString(obj.Value)
+
+	case *object.CompiledFnObject:
+		e.writeBytes([]byte{tagCompiledFn})
+		e.writeString(obj.Name)
+		e.writeUvarint(uint64(obj.NumLocals))
+		e.writeUvarint(uint64(obj.NumParameters))
+		for _, name := range obj.ParamNames {
+			e.writeString(name)
+		}
+		e.writeUvarint(uint64(obj.NumDefaults))
+		e.writeBool(obj.Variadic)
+		e.writeInstructions(obj.Instructions)
+		if e.debugInfo {
+			e.writeSourceMap(obj.SourceMap)
+		}
+
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("can not encode constant of type %s", obj.Type())
+		}
+	}
+}
+
+// writeFileTable writes the distinct file names of all source maps, so
+// entries can refer to them by index
+func (e *encoder) writeFileTable(b *Bytecode) {
+	names := []string{}
+
+	add := func(sm *code.SourceMap) {
+		if sm == nil {
+			return
+		}
+		for _, entry := range sm.Entries {
+			for _, name := range []string{entry.Span.Start.Filename, entry.Span.End.Filename} {
+				if _, ok := e.files[name]; !ok {
+					e.files[name] = uint64(len(names))
+					names = append(names, name)
+				}
+			}
+		}
+	}
+
+	add(b.SourceMap)
+	for _, c := range b.Constants {
+		if fn, ok := c.(*object.CompiledFnObject); ok {
+			add(fn.SourceMap)
+		}
+	}
+
+	e.writeUvarint(uint64(len(names)))
+	for _, name := range names {
+		e.writeString(name)
+	}
+}
+
+func (e *encoder) writeSourceMap(sm *code.SourceMap) {
+	if sm == nil {
+		e.writeUvarint(0)
+		return
+	}
+
+	e.writeUvarint(uint64(len(sm.Entries)))
+
+	previous := 0
+	for _, entry := range sm.Entries {
+		e.writeUvarint(uint64(entry.Offset - previous))
+		e.writePosition(entry.Span.Start)
+		e.writePosition(entry.Span.End)
+		previous = entry.Offset
+	}
+}
+
+func (e *encoder) writePosition(pos token.Position) {
+	e.writeUvarint(e.files[pos.Filename])
+	e.writeUvarint(uint64(pos.Offset))
+	e.writeUvarint(uint64(pos.Line))
+	e.writeUvarint(uint64(pos.Column))
+}
+
+type decoder struct {
+	r         *bufio.Reader
+	err       error
+	debugInfo bool
+	files     []string
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, a...)
+	}
+}
+
+func (d *decoder) readFull(b []byte) {
+	if d.err != nil {
+		return
+	}
+
+	_, err := io.ReadFull(d.r, b)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	d.err = err
+}
+
+func (d *decoder) readByte() byte {
+	var b [1]byte
+	d.readFull(b[:])
+	return b[0]
+}
+
+func (d *decoder) readBool() bool {
+	return d.readByte() != 0
+}
+
+func (d *decoder) readUvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.err = io.ErrUnexpectedEOF
+	}
+	return v
+}
+
+func (d *decoder) readVarint() int64 {
+	if d.err != nil {
+		return 0
+	}
+
+	v, err := binary.ReadVarint(d.r)
+	if err != nil {
+		d.err = io.ErrUnexpectedEOF
+	}
+	return v
+}
+
+func (d *decoder) readFloat() float64 {
+	var buf [8]byte
+	d.readFull(buf[:])
+	return math.Float64frombits(binary.BigEndian.Uint64(buf[:]))
+}
+
+func (d *decoder) readBigInt() *big.Int {
+	sign := d.readVarint()
+	value := new(big.Int).SetBytes([]byte(d.readString()))
+	if sign < 0 {
+		value.Neg(value)
+	}
+	return value
+}
+
+func (d *decoder) readLength() int {
+	n := d.readUvarint()
+	if n > maxLength {
+		d.fail("invalid length %d", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) readString() string {
+	b := make([]byte, d.readLength())
+	d.readFull(b)
+	return string(b)
+}
+
+func (d *decoder) readInstructions() code.Instructions {
+	ins := make(code.Instructions, d.readLength())
+	d.readFull(ins)
+	return ins
+}
+
+func (d *decoder) readConstant() object.Object {
+	tag := d.readByte()
+	if d.err != nil {
+		return nil
+	}
+
+	switch tag {
+	case tagInt:
+		return &object.IntObject{Value: d.readVarint()}
+
+	case tagBigInt:
+		return &object.BigIntObject{Value: d.readBigInt()}
+
+	case tagFloat:
+		return &object.FloatObject{Value: d.readFloat()}
+
+	case tagString:
+		return &object.StringObject{Value: d.readString()}
+
+	case tagCompiledFn:
+		fn := &object.CompiledFnObject{}
+		fn.Name = d.readString()
+		fn.NumLocals = d.readLength()
+		fn.NumParameters = d.readLength()
+		fn.ParamNames = []string{}
+		for i := 0; i < fn.NumParameters && d.err == nil; i++ {
+			fn.ParamNames = append(fn.ParamNames, d.readString())
+		}
+		fn.NumDefaults = d.readLength()
+		fn.Variadic = d.readBool()
+		fn.Instructions = d.readInstructions()
+		if d.debugInfo {
+			fn.SourceMap = d.readSourceMap()
+		}
+		return fn
+
+	default:
+		d.fail("unknown constant tag %d", tag)
+		return nil
+	}
+}
+
+func (d *decoder) readFileTable() {
+	count := d.readLength()
+	for i := 0; i < count && d.err == nil; i++ {
+		d.files = append(d.files, d.readString())
+	}
+}
+
+func (d *decoder) readSourceMap() *code.SourceMap {
+	sm := code.NewSourceMap()
+
+	count := d.readLength()
+	offset := 0
+	for i := 0; i < count && d.err == nil; i++ {
+		offset += d.readLength()
+		start := d.readPosition()
+		end := d.readPosition()
+
+		sm.Entries = append(sm.Entries, code.SourceMapEntry{
+			Offset: offset,
+			Span:   token.Span{Start: start, End: end},
+		})
+	}
+
+	return sm
+}
+
+func (d *decoder) readPosition() token.Position {
+	var pos token.Position
+
+	file := d.readLength()
+	if file < len(d.files) {
+		pos.Filename = d.files[file]
+	} else {
+		d.fail("invalid file index %d", file)
+	}
+
+	pos.Offset = d.readLength()
+	pos.Line = d.readLength()
+	pos.Column = d.readLength()
+
+	return pos
+}
@@ -0,0 +1,132 @@
+package compiler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+func TestBytecodeEncodeDecode(t *testing.T) {
+	input := `
+	let greeting = "hello";
+	let ratio = 1.5e-3;
+	let factorials = [0n, 1n, -15511210043330985984000000n];
+	let add = fn(a, b) { a + b };
+	let counter = fn(x) { fn() { x + -1 } };
+	let tally = fn() { let n = 0; fn() { n += 1; n } };
+	let sum = fn(a, b = a, ...rest) { a + b + len(rest) };
+	add(1, 2);
+	sum(b: 2, a: 1);
+	`
+
+	l := lexer.NewWithFilename("test.monkey", input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	compiler := New()
+	err := compiler.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	expected := compiler.Bytecode()
+
+	for _, withDebugInfo := range []bool{true, false} {
+		var buf bytes.Buffer
+
+		err = expected.Encode(&buf, withDebugInfo)
+		if err != nil {
+			t.Fatalf("encode error: %s", err)
+		}
+
+		actual, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("decode error: %s", err)
+		}
+
+		if !bytes.Equal(actual.Instructions, expected.Instructions) {
+			t.Errorf("wrong instructions.\nwant=%q\ngot =%q",
+				expected.Instructions, actual.Instructions)
+		}
+
+		if len(actual.Constants) != len(expected.Constants) {
+			t.Fatalf("wrong number of constants. want=%d, got=%d",
+				len(expected.Constants), len(actual.Constants))
+		}
+
+		for i, constant := range expected.Constants {
+			switch constant := constant.(type) {
+			case *object.CompiledFnObject:
+				fn, ok := actual.Constants[i].(*object.CompiledFnObject)
+				if !ok {
+					t.Fatalf("constant %d - not a function: %T", i, actual.Constants[i])
+				}
+				if fn.Name != constant.Name || fn.NumLocals != constant.NumLocals ||
+					fn.NumParameters != constant.NumParameters ||
+					fn.NumDefaults != constant.NumDefaults || fn.Variadic != constant.Variadic ||
+					!reflect.DeepEqual(fn.ParamNames, constant.ParamNames) ||
+					!bytes.Equal(fn.Instructions, constant.Instructions) {
+					t.Errorf("constant %d - wrong function. want=%+v, got=%+v", i, constant, fn)
+				}
+				if withDebugInfo && !reflect.DeepEqual(fn.SourceMap, constant.SourceMap) {
+					t.Errorf("constant %d - wrong source map. want=%+v, got=%+v",
+						i, constant.SourceMap, fn.SourceMap)
+				}
+				if !withDebugInfo && fn.SourceMap != nil {
+					t.Errorf("constant %d - unexpected source map without debug info", i)
+				}
+			case *object.BigIntObject:
+				integer, ok := actual.Constants[i].(*object.BigIntObject)
+				if !ok || integer.Value.Cmp(constant.Value) != 0 {
+					t.Errorf("constant %d - want=%s, got=%+v", i, constant.Value, actual.Constants[i])
+				}
+			default:
+				if !reflect.DeepEqual(actual.Constants[i], constant) {
+					t.Errorf("constant %d - want=%+v, got=%+v", i, constant, actual.Constants[i])
+				}
+			}
+		}
+
+		if withDebugInfo && !reflect.DeepEqual(actual.SourceMap, expected.SourceMap) {
+			t.Errorf("wrong source map.\nwant=%+v\ngot =%+v", expected.SourceMap, actual.SourceMap)
+		}
+	}
+}
+
+func TestBytecodeDecodeErrors(t *testing.T) {
+	var valid bytes.Buffer
+	err := (&Bytecode{Constants: []object.Object{&object.StringObject{Value: "foo"}}}).Encode(&valid, false)
+	if err != nil {
+		t.Fatalf("encode error: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected error
+	}{
+		{"empty", []byte{}, io.ErrUnexpectedEOF},
+		{"bad magic", []byte("#!/usr/bin/env monkey"), ErrInvalidMagic},
+		{"truncated", valid.Bytes()[:valid.Len()-2], io.ErrUnexpectedEOF},
+		{"truncated constants", []byte{'M', 'K', 'C', 0, byte(FormatVersion >> 8), byte(FormatVersion), 0, 0x80, 0x80, 0x80, 0x80, 0x01}, io.ErrUnexpectedEOF},
+		{"truncated parameters", []byte{'M', 'K', 'C', 0, byte(FormatVersion >> 8), byte(FormatVersion), 0, 1, tagCompiledFn, 0, 0, 0x80, 0x80, 0x80, 0x80, 0x01}, io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.input))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: wrong error. want=%v, got=%v", tt.name, tt.expected, err)
+		}
+	}
+
+	versioned := append([]byte{}, valid.Bytes()...)
+	versioned[5]++
+	_, err = Decode(bytes.NewReader(versioned))
+	if err == nil {
+		t.Errorf("expected error for unsupported version")
+	}
+}
@@ -1,14 +1,26 @@
 package compiler
 
 import (
+	"monkey/ast"
 	"monkey/code"
 	"monkey/object"
+	"monkey/token"
 )
 
 type CompilationScope struct {
 	instructions        code.Instructions
+	sourceMap           *code.SourceMap
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+
+	loops []*loopJumps // enclosing loops, the innermost is last
+}
+
+// loopJumps collects the positions of the jumps emitted for break and
+// continue, they are patched once the loop is compiled
+type loopJumps struct {
+	breaks    []int
+	continues []int
 }
 
 type Compiler struct {
@@ -17,11 +29,18 @@ type Compiler struct {
 
 	scopes     []CompilationScope
 	scopeIndex int
+
+	// node being compiled, emitted instructions are mapped to its source
+	currentNode ast.Node
+
+	errors   []CompileError
+	warnings []CompileError
 }
 
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	SourceMap    *code.SourceMap // source positions of Instructions
 }
 
 type EmittedInstruction struct {
@@ -32,6 +51,7 @@ type EmittedInstruction struct {
 func New() *Compiler {
 	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
+		sourceMap:           code.NewSourceMap(),
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
 	}
@@ -60,6 +80,7 @@ func NewWithState(constants []object.Object, s *SymbolTable) *Compiler {
 func (c *Compiler) enterScope() {
 	scope := CompilationScope{
 		instructions:        code.Instructions{},
+		sourceMap:           code.NewSourceMap(),
 		lastInstruction:     EmittedInstruction{},
 		previousInstruction: EmittedInstruction{},
 	}
@@ -81,14 +102,29 @@ func (c *Compiler) leaveScope() code.Instructions {
 	return instructions
 }
 
+// enterBlockScope starts a block of the current function, the names defined
+// until leaveBlockScope are only visible inside of it
+func (c *Compiler) enterBlockScope() {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveBlockScope() {
+	c.symbolTable = c.symbolTable.Outer
+}
+
 func (c *Compiler) currentInstructions() code.Instructions {
 	return c.scopes[c.scopeIndex].instructions
 }
 
+func (c *Compiler) currentSourceMap() *code.SourceMap {
+	return c.scopes[c.scopeIndex].sourceMap
+}
+
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		SourceMap:    c.currentSourceMap(),
 	}
 }
 
@@ -96,6 +132,11 @@ func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	inst := code.Make(op, operands...)
 	pos := c.addInstruction(inst)
 
+	if c.currentNode != nil {
+		span := token.Span{Start: c.currentNode.Pos(), End: c.currentNode.End()}
+		c.currentSourceMap().Add(pos, span)
+	}
+
 	c.setLastInstruction(op, pos)
 
 	return pos
@@ -140,6 +181,17 @@ func (c *Compiler) removeLastPop() {
 
 	c.scopes[c.scopeIndex].instructions = newInst
 	c.scopes[c.scopeIndex].lastInstruction = previous
+	c.currentSourceMap().Truncate(last.Position)
+}
+
+// keepBlockValue leaves the value of a compiled block on the stack: the
+// value of its last expression, or null when it ends in another statement
+func (c *Compiler) keepBlockValue() {
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
 }
 
 func (c *Compiler) replaceLastPopWithReturn() {
@@ -164,6 +216,33 @@ func (c *Compiler) changeOperand(opPos int, operand int) {
 	c.replaceInstruction(opPos, newInstruction)
 }
 
+func (c *Compiler) enterLoop() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loopJumps{})
+}
+
+// leaveLoop patches the break and continue jumps of the innermost loop
+func (c *Compiler) leaveLoop(breakPos, continuePos int) {
+	scope := &c.scopes[c.scopeIndex]
+	loop := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, breakPos)
+	}
+	for _, pos := range loop.continues {
+		c.changeOperand(pos, continuePos)
+	}
+}
+
+func (c *Compiler) currentLoop() *loopJumps {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops)-1]
+}
+
 func (c *Compiler) loadSymbol(s Symbol) {
 	switch s.Scope {
 	case GlobalScope:
@@ -178,3 +257,27 @@ func (c *Compiler) loadSymbol(s Symbol) {
 		c.emit(code.OpCurrentClosure)
 	}
 }
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+// captureSymbol pushes a variable for OpClosure: locals and free variables
+// as upvalues, so the closure shares them with the enclosing function
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
@@ -1,78 +1,61 @@
 package compiler
 
 import (
-	"fmt"
 	"monkey/ast"
 	"monkey/code"
 	"monkey/object"
-	"sort"
 )
 
+// Compile compiles the node and everything below it. Compilation does not
+// stop at the first semantic error, all of them are reported as an ErrorList.
 func (c *Compiler) Compile(node ast.Node) error {
+	c.compile(node)
+
+	if len(c.errors) > 0 {
+		return ErrorList(c.errors)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compile(node ast.Node) {
+	previousNode := c.currentNode
+	c.currentNode = node
+	defer func() { c.currentNode = previousNode }()
+
 	switch node := node.(type) {
 	case *ast.ProgramNode:
 		for _, s := range node.StatementNodes {
-			err := c.Compile(s)
-			if err != nil {
-				return err
-			}
+			c.compile(s)
 		}
 
 	case *ast.ExpressionStatementNode:
-		err := c.Compile(node.ExpressionNode)
-		if err != nil {
-			return err
-		}
+		c.compile(node.ExpressionNode)
 		c.emit(code.OpPop)
 
 	case *ast.InfixExpressionNode:
-		if node.Operator == "<" {
-			err := c.Compile(node.RightNode)
-			if err != nil {
-				return err
-			}
-
-			err = c.Compile(node.LeftNode)
-			if err != nil {
-				return err
-			}
-			c.emit(code.OpGreaterThan)
-			return nil
-		}
-
-		err := c.Compile(node.LeftNode)
-		if err != nil {
-			return err
+		if node.Operator == "&&" || node.Operator == "||" {
+			c.compileLogicalExpression(node)
+			return
 		}
 
-		err = c.Compile(node.RightNode)
-		if err != nil {
-			return err
-		}
+		c.compile(node.LeftNode)
+		c.compile(node.RightNode)
 
-		switch node.Operator {
-		case "+":
-			c.emit(code.OpAdd)
-		case "-":
-			c.emit(code.OpSub)
-		case "*":
-			c.emit(code.OpMul)
-		case "/":
-			c.emit(code.OpDiv)
-		case ">":
-			c.emit(code.OpGreaterThan)
-		case "==":
-			c.emit(code.OpEqual)
-		case "!=":
-			c.emit(code.OpNotEqual)
-		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
-		}
+		c.emitOperator(node, node.Operator)
 
 	case *ast.IntegerLiteralNode:
 		integer := &object.IntObject{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
 
+	case *ast.BigIntLiteralNode:
+		integer := &object.BigIntObject{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.FloatLiteralNode:
+		float := &object.FloatObject{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
 	case *ast.BooleanNode:
 		if node.Value {
 			c.emit(code.OpTrue)
@@ -81,37 +64,27 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.PrefixExpressionNode:
-		err := c.Compile(node.RightNode)
-		if err != nil {
-			return err
-		}
+		c.compile(node.RightNode)
 
 		switch node.Operator {
 		case "!":
 			c.emit(code.OpBang)
 		case "-":
 			c.emit(code.OpMinus)
+		case "~":
+			c.emit(code.OpBitNot)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			c.addError(UnknownOperator, node, "unknown operator %s", node.Operator)
 		}
 
 	case *ast.IfExpressionNode:
-		err := c.Compile(node.ConditionNode)
-		if err != nil {
-			return err
-		}
+		c.compile(node.ConditionNode)
 
 		// Emit an `OpJumpNotTruthy` with a bogus value
 		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
 
-		err = c.Compile(node.ConsequenceNode)
-		if err != nil {
-			return err
-		}
-
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
+		c.compile(node.ConsequenceNode)
+		c.keepBlockValue()
 
 		// Emit an `OpJump` with a bogus value
 		jumpPos := c.emit(code.OpJump, 9999)
@@ -122,45 +95,52 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if node.AlternativeNode == nil {
 			c.emit(code.OpNull)
 		} else {
-			err := c.Compile(node.AlternativeNode)
-			if err != nil {
-				return err
-			}
-
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
+			c.compile(node.AlternativeNode)
+			c.keepBlockValue()
 		}
 
 		afterAlternativePos := len(c.currentInstructions())
 		c.changeOperand(jumpPos, afterAlternativePos)
 
+	case *ast.MatchExpressionNode:
+		c.compileMatchExpression(node)
+
 	case *ast.BlockStatementNode:
 		for _, s := range node.StatementNodes {
-			err := c.Compile(s)
-			if err != nil {
-				return err
-			}
+			c.compile(s)
 		}
 
 	case *ast.LetStatementNode:
-		symbol := c.symbolTable.Define(node.NameNode.Value)
-
-		err := c.Compile(node.ValueNode)
-		if err != nil {
-			return err
+		if node.PatternNode != nil {
+			c.compileLetPattern(node)
+			return
 		}
 
-		if symbol.Scope == GlobalScope {
-			c.emit(code.OpSetGlobal, symbol.Index)
-		} else {
-			c.emit(code.OpSetLocal, symbol.Index)
+		// the value is compiled first, it may refer to a variable of the
+		// same name in an outer scope. A function can only refer to its
+		// own name, which is defined first so the function can assign to it.
+		if _, ok := node.ValueNode.(*ast.FunctionLiteralNode); ok {
+			symbol := c.symbolTable.Define(node.NameNode.Value)
+			c.compile(node.ValueNode)
+			c.storeSymbol(symbol)
+			return
 		}
 
+		c.compile(node.ValueNode)
+
+		symbol := c.symbolTable.Define(node.NameNode.Value)
+		c.storeSymbol(symbol)
+
+	case *ast.AssignExpressionNode:
+		c.compileAssignExpression(node)
+
 	case *ast.IdentifierNode:
 		symbol, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Value)
+			c.addError(UndefinedVariable, node, "undefined variable %s", node.Value)
+			// Keep the stack layout intact for the code that follows
+			c.emit(code.OpNull)
+			return
 		}
 
 		c.loadSymbol(symbol)
@@ -169,48 +149,31 @@ func (c *Compiler) Compile(node ast.Node) error {
 		str := &object.StringObject{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
 
+	case *ast.InterpolatedStringNode:
+		for _, part := range node.Parts {
+			c.compile(part)
+		}
+
+		c.emit(code.OpConcat, len(node.Parts))
+
 	case *ast.ArrayLiteralNode:
 		for _, el := range node.Elements {
-			err := c.Compile(el)
-			if err != nil {
-				return err
-			}
+			c.compile(el)
 		}
 
 		c.emit(code.OpArray, len(node.Elements))
 
 	case *ast.HashLiteralNode:
-		keys := []ast.ExpressionNode{}
-		for k := range node.Pairs {
-			keys = append(keys, k)
-		}
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
-
-		for _, k := range keys {
-			err := c.Compile(k)
-			if err != nil {
-				return err
-			}
-			err = c.Compile(node.Pairs[k])
-			if err != nil {
-				return err
-			}
+		for _, k := range node.Keys() {
+			c.compile(k)
+			c.compile(node.Pairs[k])
 		}
 
 		c.emit(code.OpHash, len(node.Pairs)*2)
 
 	case *ast.IndexExpressionNode:
-		err := c.Compile(node.Left)
-		if err != nil {
-			return err
-		}
-
-		err = c.Compile(node.Index)
-		if err != nil {
-			return err
-		}
+		c.compile(node.Left)
+		c.compile(node.Index)
 
 		c.emit(code.OpIndex)
 
@@ -221,14 +184,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.symbolTable.DefineFunctionName(node.Name)
 		}
 
-		for _, p := range node.ParamNodes {
-			c.symbolTable.Define(p.Value)
-		}
-
-		err := c.Compile(node.BodyNode)
-		if err != nil {
-			return err
-		}
+		c.compileParameters(node)
+		c.compile(node.BodyNode)
 
 		if c.lastInstructionIs(code.OpPop) {
 			c.replaceLastPopWithReturn()
@@ -239,45 +196,227 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.counter
+		sourceMap := c.currentSourceMap()
 		instructions := c.leaveScope()
 
 		for _, s := range freeSymbols {
-			c.loadSymbol(s)
+			c.captureSymbol(s)
 		}
 
 		compiledFunc := &object.CompiledFnObject{
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.ParamNodes),
+			ParamNames:    paramNames(node),
+			NumDefaults:   len(node.DefaultNodes),
+			Variadic:      node.RestNode != nil,
+			Name:          node.Name,
+			SourceMap:     sourceMap,
 		}
 
 		fnIndex := c.addConstant(compiledFunc)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
 
-	case *ast.ReturnStatementNode:
-		err := c.Compile(node.ReturnValueNode)
-		if err != nil {
-			return err
+	case *ast.WhileStatementNode:
+		startPos := len(c.currentInstructions())
+
+		c.compile(node.ConditionNode)
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		c.enterLoop()
+		c.compile(node.BodyNode)
+		c.emit(code.OpJump, startPos)
+
+		afterLoopPos := len(c.currentInstructions())
+		c.changeOperand(jumpNotTruthyPos, afterLoopPos)
+		c.leaveLoop(afterLoopPos, startPos)
+
+	case *ast.ForStatementNode:
+		if node.InitNode != nil {
+			c.compile(node.InitNode)
 		}
 
+		startPos := len(c.currentInstructions())
+
+		jumpNotTruthyPos := -1
+		if node.ConditionNode != nil {
+			c.compile(node.ConditionNode)
+			jumpNotTruthyPos = c.emit(code.OpJumpNotTruthy, 9999)
+		}
+
+		c.enterLoop()
+		c.compile(node.BodyNode)
+
+		updatePos := len(c.currentInstructions())
+		if node.UpdateNode != nil {
+			c.compile(node.UpdateNode)
+		}
+		c.emit(code.OpJump, startPos)
+
+		afterLoopPos := len(c.currentInstructions())
+		if jumpNotTruthyPos >= 0 {
+			c.changeOperand(jumpNotTruthyPos, afterLoopPos)
+		}
+		c.leaveLoop(afterLoopPos, updatePos)
+
+	case *ast.BreakStatementNode:
+		loop := c.currentLoop()
+		if loop == nil {
+			c.addError(MisplacedBranch, node, "break is not inside a loop")
+			return
+		}
+		loop.breaks = append(loop.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatementNode:
+		loop := c.currentLoop()
+		if loop == nil {
+			c.addError(MisplacedBranch, node, "continue is not inside a loop")
+			return
+		}
+		loop.continues = append(loop.continues, c.emit(code.OpJump, 9999))
+
+	case *ast.ReturnStatementNode:
+		c.compile(node.ReturnValueNode)
+
 		c.emit(code.OpReturnValue)
 
 	case *ast.CallExpressionNode:
-		err := c.Compile(node.FnNode)
-		if err != nil {
-			return err
+		c.compile(node.FnNode)
+
+		argNodes, namedNodes := splitNamedArguments(node.ArgNodes)
+
+		if hasSpread(argNodes) {
+			c.compileSpreadArguments(argNodes)
+			c.compileNamedArguments(namedNodes)
+			c.emit(code.OpCallSpread, len(namedNodes), c.addArgumentNames(namedNodes))
+			return
 		}
 
-		for _, a := range node.ArgNodes {
-			err := c.Compile(a)
-			if err != nil {
-				return err
-			}
+		for _, a := range argNodes {
+			c.compile(a)
+		}
+
+		if len(namedNodes) > 0 {
+			c.compileNamedArguments(namedNodes)
+			c.emit(code.OpCallNamed, len(node.ArgNodes), len(namedNodes), c.addArgumentNames(namedNodes))
+			return
 		}
 
 		c.emit(code.OpCall, len(node.ArgNodes))
 
 	}
+}
 
-	return nil
+// emitOperator emits the opcode of a binary operator, the operands are
+// already on the stack
+func (c *Compiler) emitOperator(node ast.Node, operator string) {
+	switch operator {
+	case "+":
+		c.emit(code.OpAdd)
+	case "-":
+		c.emit(code.OpSub)
+	case "*":
+		c.emit(code.OpMul)
+	case "/":
+		c.emit(code.OpDiv)
+	case "%":
+		c.emit(code.OpMod)
+	case "&":
+		c.emit(code.OpBitAnd)
+	case "|":
+		c.emit(code.OpBitOr)
+	case "^":
+		c.emit(code.OpBitXor)
+	case "<<":
+		c.emit(code.OpShiftLeft)
+	case ">>":
+		c.emit(code.OpShiftRight)
+	case "<":
+		c.emit(code.OpLessThan)
+	case ">":
+		c.emit(code.OpGreaterThan)
+	case "<=":
+		c.emit(code.OpLessThanOrEqual)
+	case ">=":
+		c.emit(code.OpGreaterThanOrEqual)
+	case "==":
+		c.emit(code.OpEqual)
+	case "!=":
+		c.emit(code.OpNotEqual)
+	default:
+		c.addError(UnknownOperator, node, "unknown operator %s", operator)
+	}
+}
+
+// compileAssignExpression compiles an assignment to a variable or to an
+// index expression, the assigned value is left on the stack
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpressionNode) {
+	operator := node.BinaryOperator()
+
+	switch target := node.Target.(type) {
+	case *ast.IdentifierNode:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if ok && symbol.Scope == FunctionScope {
+			symbol, ok = c.symbolTable.rebindFunctionName(target.Value)
+		}
+		if !ok {
+			c.addError(UndefinedVariable, target, "undefined variable %s", target.Value)
+			c.emit(code.OpNull)
+			return
+		}
+		if symbol.Scope == BuiltinScope {
+			c.addError(InvalidAssignment, target, "cannot assign to builtin %s", target.Value)
+			c.emit(code.OpNull)
+			return
+		}
+
+		if operator != "" {
+			c.loadSymbol(symbol)
+		}
+		c.compile(node.ValueNode)
+		if operator != "" {
+			c.emitOperator(node, operator)
+		}
+
+		c.storeSymbol(symbol)
+		c.loadSymbol(symbol)
+
+	case *ast.IndexExpressionNode:
+		c.compile(target.Left)
+		c.compile(target.Index)
+
+		if operator != "" {
+			c.emit(code.OpDupPair)
+			c.emit(code.OpIndex)
+		}
+		c.compile(node.ValueNode)
+		if operator != "" {
+			c.emitOperator(node, operator)
+		}
+
+		c.emit(code.OpSetIndex)
+
+	default:
+		c.addError(InvalidAssignment, node, "cannot assign to %s", node.Target.String())
+		c.emit(code.OpNull)
+	}
+}
+
+// compileLogicalExpression compiles && and || so the right operand is only
+// evaluated when the left one does not decide the result, which is then
+// left on the stack
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpressionNode) {
+	c.compile(node.LeftNode)
+
+	op := code.OpJumpNotTruthyOrPop
+	if node.Operator == "||" {
+		op = code.OpJumpTruthyOrPop
+	}
+
+	// Emit the jump with a bogus value, it is known after the right operand
+	jumpPos := c.emit(op, 9999)
+
+	c.compile(node.RightNode)
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
 }
@@ -0,0 +1,139 @@
+package compiler
+
+import (
+	"monkey/ast"
+	"monkey/code"
+	"monkey/object"
+)
+
+// compileParameters defines the parameters of a function and emits the
+// start of it that sets the parameters the call passed no argument for to
+// their default values. Each default is skipped with OpJumpIfPassed, so it
+// is only evaluated when needed. The slots of the parameters are taken up
+// front, but a parameter only gets its name after its default, so a default
+// can refer to the parameters before it and not to the ones after it.
+func (c *Compiler) compileParameters(node *ast.FunctionLiteralNode) {
+	params := []Symbol{}
+	for _, p := range node.ParamNodes {
+		params = append(params, c.symbolTable.allocate(p.Value))
+	}
+	var rest Symbol
+	if node.RestNode != nil {
+		rest = c.symbolTable.allocate(node.RestNode.Value)
+	}
+
+	required := node.NumRequired()
+	for _, param := range params[:required] {
+		c.symbolTable.bind(param)
+	}
+
+	for i, defaultNode := range node.DefaultNodes {
+		param := params[required+i]
+		jumpPos := c.emit(code.OpJumpIfPassed, param.Index, 9999)
+
+		c.compile(defaultNode)
+		c.storeSymbol(param)
+
+		afterDefaultPos := len(c.currentInstructions())
+		c.replaceInstruction(jumpPos, code.Make(code.OpJumpIfPassed, param.Index, afterDefaultPos))
+
+		c.symbolTable.bind(param)
+	}
+
+	if node.RestNode != nil {
+		c.symbolTable.bind(rest)
+	}
+}
+
+func hasSpread(argNodes []ast.ExpressionNode) bool {
+	for _, a := range argNodes {
+		if _, ok := a.(*ast.SpreadNode); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compileSpreadArguments pushes an array of all arguments of a call with
+// spread arguments, for OpCallSpread. The other arguments are collected
+// with OpArray and joined with the spread arrays by OpSpread.
+func (c *Compiler) compileSpreadArguments(argNodes []ast.ExpressionNode) {
+	numPlain := 0
+	first := true
+
+	for _, a := range argNodes {
+		spread, ok := a.(*ast.SpreadNode)
+		if !ok {
+			c.compile(a)
+			numPlain++
+			continue
+		}
+
+		if first || numPlain > 0 {
+			c.emit(code.OpArray, numPlain)
+			if !first {
+				c.emit(code.OpSpread)
+			}
+			numPlain = 0
+			first = false
+		}
+
+		c.compile(spread.ValueNode)
+
+		previousNode := c.currentNode
+		c.currentNode = spread
+		c.emit(code.OpSpread)
+		c.currentNode = previousNode
+	}
+
+	if numPlain > 0 {
+		c.emit(code.OpArray, numPlain)
+		c.emit(code.OpSpread)
+	}
+}
+
+// splitNamedArguments separates the arguments passed by name, which are the
+// last ones, from the others
+func splitNamedArguments(argNodes []ast.ExpressionNode) ([]ast.ExpressionNode, []*ast.NamedArgumentNode) {
+	var namedNodes []*ast.NamedArgumentNode
+
+	for i, a := range argNodes {
+		if named, ok := a.(*ast.NamedArgumentNode); ok {
+			if namedNodes == nil {
+				argNodes = argNodes[:i]
+			}
+			namedNodes = append(namedNodes, named)
+		}
+	}
+
+	return argNodes, namedNodes
+}
+
+func (c *Compiler) compileNamedArguments(namedNodes []*ast.NamedArgumentNode) {
+	for _, named := range namedNodes {
+		c.compile(named.ValueNode)
+	}
+}
+
+// addArgumentNames adds the names of the arguments as consecutive string
+// constants and returns the index of the first one, or 0 without names
+func (c *Compiler) addArgumentNames(namedNodes []*ast.NamedArgumentNode) int {
+	if len(namedNodes) == 0 {
+		return 0
+	}
+
+	first := len(c.constants)
+	for _, named := range namedNodes {
+		c.addConstant(&object.StringObject{Value: named.Name.Value})
+	}
+	return first
+}
+
+func paramNames(node *ast.FunctionLiteralNode) []string {
+	names := make([]string, len(node.ParamNodes))
+	for i, param := range node.ParamNodes {
+		names[i] = param.Value
+	}
+	return names
+}
@@ -0,0 +1,72 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+	"strings"
+)
+
+type ErrorKind string
+
+const (
+	UndefinedVariable ErrorKind = "UNDEFINED_VARIABLE"
+	UnknownOperator   ErrorKind = "UNKNOWN_OPERATOR"
+	MisplacedBranch   ErrorKind = "MISPLACED_BRANCH"   // break or continue outside of a loop
+	InvalidAssignment ErrorKind = "INVALID_ASSIGNMENT" // the target of an assignment can not be assigned to
+
+	// Warnings, they do not stop the compilation
+	NonExhaustiveMatch ErrorKind = "NON_EXHAUSTIVE_MATCH" // no arm of a match is taken for some values
+	UnreachableArm     ErrorKind = "UNREACHABLE_ARM"      // an arm of a match is never taken
+)
+
+// CompileError A semantic error found while compiling a node
+type CompileError struct {
+	Kind    ErrorKind
+	Pos     token.Position
+	Node    ast.Node
+	Message string
+}
+
+func (e CompileError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// ErrorList All errors found by a single Compile call
+type ErrorList []CompileError
+
+func (l ErrorList) Error() string {
+	messages := make([]string, len(l))
+	for i, e := range l {
+		messages[i] = e.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func (c *Compiler) Errors() []CompileError {
+	return c.errors
+}
+
+// Warnings returns the problems found that do not stop the compilation
+func (c *Compiler) Warnings() []CompileError {
+	return c.warnings
+}
+
+func (c *Compiler) addWarning(kind ErrorKind, node ast.Node, format string, a ...interface{}) {
+	c.warnings = append(c.warnings, CompileError{
+		Kind:    kind,
+		Pos:     node.Pos(),
+		Node:    node,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (c *Compiler) addError(kind ErrorKind, node ast.Node, format string, a ...interface{}) {
+	c.errors = append(c.errors, CompileError{
+		Kind:    kind,
+		Pos:     node.Pos(),
+		Node:    node,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
@@ -0,0 +1,477 @@
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/object"
+	"strings"
+)
+
+// minJumpTableCases is the least number of integer cases that a match is
+// compiled to a jump table for, fewer cases are compared one by one
+const minJumpTableCases = 4
+
+// maxJumpTableValue limits jump tables to the integers that floats hold
+// exactly, so that a float subject selects the same arm as == does
+const maxJumpTableValue = 1 << 53
+
+// compileMatchExpression compiles a match to tests of the subject against
+// the patterns of each arm in turn, or to a jump table when all patterns are
+// dense integers. The subject stays on the stack while the patterns and
+// guards are tested, and is popped before an arm body runs. Every arm is a
+// block scope, the names its patterns bind are not visible outside of it.
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpressionNode) {
+	c.compile(node.SubjectNode)
+	c.checkMatchArms(node)
+
+	if table, ok := newJumpTable(node); ok {
+		c.compileJumpTable(node, table)
+		return
+	}
+
+	var endJumps []int
+	for _, arm := range node.Arms {
+		c.enterBlockScope()
+		nextArmJumps := c.compilePatterns(arm.Patterns)
+
+		if arm.GuardNode != nil {
+			c.compile(arm.GuardNode)
+			nextArmJumps = append(nextArmJumps, c.emit(code.OpJumpNotTruthy, 9999))
+		}
+
+		c.emit(code.OpPop)
+		c.compileArmBody(arm)
+		c.leaveBlockScope()
+
+		if len(nextArmJumps) == 0 {
+			// the arm matches any value, the arms after it are never reached
+			c.patchJumps(endJumps)
+			return
+		}
+
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+		c.patchJumps(nextArmJumps)
+	}
+
+	// no arm matched
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+
+	c.patchJumps(endJumps)
+}
+
+// compileLetPattern compiles a let with a pattern, a value that does not
+// match the pattern is a runtime error. The pattern is matched in a block
+// scope, its names are only set once the whole value matched.
+func (c *Compiler) compileLetPattern(node *ast.LetStatementNode) {
+	c.compile(node.ValueNode)
+
+	c.enterBlockScope()
+	failedJumps := c.compilePatterns([]ast.ExpressionNode{node.PatternNode})
+	if len(failedJumps) > 0 {
+		matchedJump := c.emit(code.OpJump, 9999)
+		c.patchJumps(failedJumps)
+		c.emit(code.OpMatchFailed)
+		c.changeOperand(matchedJump, len(c.currentInstructions()))
+	}
+	bindings := c.symbolTable
+	c.leaveBlockScope()
+
+	for _, name := range ast.PatternBindings(node.PatternNode) {
+		symbol, _ := bindings.Resolve(name)
+		c.loadSymbol(symbol)
+		c.storeSymbol(c.symbolTable.Define(name))
+	}
+
+	c.emit(code.OpPop)
+}
+
+// compilePatterns tests the value on top of the stack against the patterns
+// until one of them matches, the value stays on the stack. It returns the
+// jumps taken when none matches, there are none if a pattern matches any
+// value.
+func (c *Compiler) compilePatterns(patterns []ast.ExpressionNode) []int {
+	var matchedJumps []int
+
+	for i, pattern := range patterns {
+		failed := &patternFailure{}
+		c.compilePattern(pattern, failed, 0)
+
+		if len(failed.jumps) == 0 {
+			c.patchJumps(matchedJumps)
+			return nil
+		}
+
+		last := i == len(patterns)-1
+		if last && len(failed.jumps) == 1 {
+			// the failed tests leave just the value on the stack, they can
+			// jump out directly
+			c.patchJumps(matchedJumps)
+			return failed.jumps[0]
+		}
+
+		matchedJumps = append(matchedJumps, c.emit(code.OpJump, 9999))
+		c.emitFailurePads(failed)
+
+		if last {
+			noMatchJump := c.emit(code.OpJump, 9999)
+			c.patchJumps(matchedJumps)
+			return []int{noMatchJump}
+		}
+	}
+
+	return nil
+}
+
+// patternFailure collects the jumps of the tests of a pattern that did not
+// match, by the number of values the test pushed on top of the tested value
+type patternFailure struct {
+	jumps [][]int
+}
+
+func (f *patternFailure) add(depth, pos int) {
+	for len(f.jumps) <= depth {
+		f.jumps = append(f.jumps, nil)
+	}
+	f.jumps[depth] = append(f.jumps[depth], pos)
+}
+
+// emitFailurePads emits the code the failed tests jump to, it pops what
+// they pushed so that only the tested value is left on the stack
+func (c *Compiler) emitFailurePads(failed *patternFailure) {
+	for depth := len(failed.jumps) - 1; depth > 0; depth-- {
+		c.patchJumps(failed.jumps[depth])
+		c.emit(code.OpPop)
+	}
+	c.patchJumps(failed.jumps[0])
+}
+
+// compilePattern emits the test of the value on top of the stack against a
+// pattern, the value stays on the stack. Names are bound as their part of
+// the value is reached. Depth is the number of values the enclosing
+// patterns pushed, a failed test pops them before it leaves the pattern.
+func (c *Compiler) compilePattern(pattern ast.ExpressionNode, failed *patternFailure, depth int) {
+	previousNode := c.currentNode
+	c.currentNode = pattern
+	defer func() { c.currentNode = previousNode }()
+
+	testFailed := func() {
+		failed.add(depth, c.emit(code.OpJumpNotTruthy, 9999))
+	}
+
+	switch pattern := pattern.(type) {
+	case *ast.IdentifierNode:
+		if !ast.IsWildcard(pattern) {
+			c.emit(code.OpDup)
+			c.storeSymbol(c.symbolTable.Define(pattern.Value))
+		}
+
+	case *ast.ArrayPatternNode:
+		hasRest := 0
+		if pattern.Rest != nil {
+			hasRest = 1
+		}
+
+		c.emit(code.OpDup)
+		c.emit(code.OpMatchArray, len(pattern.Elements), hasRest)
+		testFailed()
+
+		for i, element := range pattern.Elements {
+			if ast.IsWildcard(element) {
+				continue
+			}
+
+			c.emit(code.OpDup)
+			c.emit(code.OpConstant, c.addConstant(&object.IntObject{Value: int64(i)}))
+			c.emit(code.OpIndex)
+			c.compileElementPattern(element, failed, depth+1)
+		}
+
+		if pattern.Rest != nil && !ast.IsWildcard(pattern.Rest) {
+			c.emit(code.OpDup)
+			c.emit(code.OpArrayRest, len(pattern.Elements))
+			c.storeSymbol(c.symbolTable.Define(pattern.Rest.Value))
+		}
+
+	case *ast.HashPatternNode:
+		c.emit(code.OpDup)
+		c.emit(code.OpMatchType, c.addConstant(&object.StringObject{Value: "hash"}))
+		testFailed()
+
+		for i, key := range pattern.Keys {
+			c.emit(code.OpDup)
+			c.compile(key)
+			c.emit(code.OpHasKey)
+			testFailed()
+
+			if ast.IsWildcard(pattern.Values[i]) {
+				continue
+			}
+
+			c.emit(code.OpDup)
+			c.compile(key)
+			c.emit(code.OpIndex)
+			c.compileElementPattern(pattern.Values[i], failed, depth+1)
+		}
+
+	case *ast.TypePatternNode:
+		c.emit(code.OpDup)
+		c.emit(code.OpMatchType, c.addConstant(&object.StringObject{Value: pattern.TypeName}))
+		testFailed()
+
+		c.compilePattern(pattern.PatternNode, failed, depth)
+
+	default:
+		// literals are compared like ==
+		c.emit(code.OpDup)
+		c.compile(pattern)
+		c.emit(code.OpEqual)
+		testFailed()
+	}
+}
+
+// compileElementPattern tests the element of an array or hash that was
+// pushed on top of it against a pattern, and pops the element
+func (c *Compiler) compileElementPattern(pattern ast.ExpressionNode, failed *patternFailure, depth int) {
+	if ident, ok := pattern.(*ast.IdentifierNode); ok && !ast.IsWildcard(ident) {
+		// the element is bound as it is, without a copy
+		c.storeSymbol(c.symbolTable.Define(ident.Value))
+		return
+	}
+
+	c.compilePattern(pattern, failed, depth)
+	c.emit(code.OpPop)
+}
+
+// checkMatchArms warns about arms that are never taken, and about a match
+// without an arm for any value, which is null for the values no arm matches
+func (c *Compiler) checkMatchArms(node *ast.MatchExpressionNode) {
+	// literal patterns of the earlier arms without a guard
+	seen := map[string]bool{}
+
+	for i, arm := range node.Arms {
+		covered := true
+		for _, pattern := range arm.Patterns {
+			covered = covered && isLiteralPattern(pattern) && seen[literalKey(pattern)]
+		}
+		if covered {
+			c.addWarning(UnreachableArm, arm.Patterns[0],
+				"unreachable match arm, earlier arms match %s", patternsString(arm.Patterns))
+			continue
+		}
+
+		if arm.GuardNode != nil {
+			continue
+		}
+
+		if alwaysMatches(arm) {
+			if i+1 < len(node.Arms) {
+				c.addWarning(UnreachableArm, node.Arms[i+1].Patterns[0],
+					"unreachable match arm, %s before it matches any value", patternsString(arm.Patterns))
+			}
+			return
+		}
+
+		for _, pattern := range arm.Patterns {
+			if isLiteralPattern(pattern) {
+				seen[literalKey(pattern)] = true
+			}
+		}
+	}
+
+	c.addWarning(NonExhaustiveMatch, node, "match is not exhaustive, it is null for the values no arm matches")
+}
+
+// compileArmBody compiles the body of an arm, leaving its value on the stack
+func (c *Compiler) compileArmBody(arm *ast.MatchArmNode) {
+	if len(arm.BodyNode.StatementNodes) == 0 {
+		c.emit(code.OpNull)
+		return
+	}
+
+	c.compile(arm.BodyNode)
+	c.keepBlockValue()
+}
+
+// patchJumps points the jumps at the current position
+func (c *Compiler) patchJumps(jumps []int) {
+	for _, pos := range jumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+}
+
+// jumpTable maps the integers from min up to the arms they select
+type jumpTable struct {
+	min      int64
+	arms     []int // index of the selected arm for each integer, or -1
+	fallback int   // index of the arm with _, or -1
+}
+
+// newJumpTable builds a jump table for a match whose patterns are integer
+// literals, if there are enough of them and they are close together
+func newJumpTable(node *ast.MatchExpressionNode) (*jumpTable, bool) {
+	table := &jumpTable{fallback: -1}
+	values := map[int64]int{}
+
+	for i, arm := range node.Arms {
+		if isWildcardArm(arm) {
+			table.fallback = i
+			break
+		}
+		if arm.GuardNode != nil {
+			return nil, false
+		}
+
+		for _, pattern := range arm.Patterns {
+			value, ok := integerPattern(pattern)
+			if !ok || value < -maxJumpTableValue || value > maxJumpTableValue {
+				return nil, false
+			}
+			// the first arm with the value wins
+			if _, ok := values[value]; !ok {
+				values[value] = i
+			}
+		}
+	}
+
+	if len(values) < minJumpTableCases {
+		return nil, false
+	}
+
+	min, max := int64(math.MaxInt64), int64(math.MinInt64)
+	for value := range values {
+		if value < min {
+			min = value
+		}
+		if value > max {
+			max = value
+		}
+	}
+
+	size := max - min + 1
+	if size > int64(2*len(values)) || size > math.MaxUint16 {
+		return nil, false
+	}
+
+	table.min = min
+	table.arms = make([]int, size)
+	for i := range table.arms {
+		table.arms[i] = -1
+	}
+	for value, arm := range values {
+		table.arms[value-min] = arm
+	}
+
+	return table, true
+}
+
+// compileJumpTable emits an OpJumpTable, followed by a jump to an arm for
+// every integer of the table and a jump for the other values
+func (c *Compiler) compileJumpTable(node *ast.MatchExpressionNode, table *jumpTable) {
+	minIndex := c.addConstant(&object.IntObject{Value: table.min})
+	c.emit(code.OpJumpTable, minIndex, len(table.arms))
+
+	entries := make([]int, len(table.arms))
+	for i := range table.arms {
+		entries[i] = c.emit(code.OpJump, 9999)
+	}
+	fallbackJump := c.emit(code.OpJump, 9999)
+
+	used := map[int]bool{}
+	for _, arm := range table.arms {
+		used[arm] = true
+	}
+
+	armPositions := map[int]int{}
+	var endJumps []int
+	for i, arm := range node.Arms {
+		if i == table.fallback {
+			break
+		}
+		if !used[i] {
+			continue
+		}
+
+		armPositions[i] = len(c.currentInstructions())
+		c.enterBlockScope()
+		c.compileArmBody(arm)
+		c.leaveBlockScope()
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+	}
+
+	fallbackPos := len(c.currentInstructions())
+	if table.fallback >= 0 {
+		c.enterBlockScope()
+		c.compileArmBody(node.Arms[table.fallback])
+		c.leaveBlockScope()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	for i, arm := range table.arms {
+		if arm < 0 {
+			c.changeOperand(entries[i], fallbackPos)
+		} else {
+			c.changeOperand(entries[i], armPositions[arm])
+		}
+	}
+	c.changeOperand(fallbackJump, fallbackPos)
+	c.patchJumps(endJumps)
+}
+
+func isWildcardArm(arm *ast.MatchArmNode) bool {
+	for _, pattern := range arm.Patterns {
+		if ast.IsWildcard(pattern) {
+			return arm.GuardNode == nil
+		}
+	}
+	return false
+}
+
+// alwaysMatches reports whether an arm is taken for any value
+func alwaysMatches(arm *ast.MatchArmNode) bool {
+	for _, pattern := range arm.Patterns {
+		if ast.IsIrrefutable(pattern) {
+			return arm.GuardNode == nil
+		}
+	}
+	return false
+}
+
+func isLiteralPattern(pattern ast.ExpressionNode) bool {
+	switch pattern.(type) {
+	case *ast.IdentifierNode, *ast.ArrayPatternNode, *ast.HashPatternNode, *ast.TypePatternNode:
+		return false
+	}
+	return true
+}
+
+// literalKey identifies a literal pattern, the string of a literal alone
+// does not tell 1 and "1" apart
+func literalKey(pattern ast.ExpressionNode) string {
+	return fmt.Sprintf("%T %s", pattern, pattern.String())
+}
+
+func patternsString(patterns []ast.ExpressionNode) string {
+	strs := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		strs[i] = pattern.String()
+	}
+	return strings.Join(strs, ", ")
+}
+
+// integerPattern returns the value of an integer literal pattern, like 3
+// or -3
+func integerPattern(pattern ast.ExpressionNode) (int64, bool) {
+	switch pattern := pattern.(type) {
+	case *ast.IntegerLiteralNode:
+		return pattern.Value, true
+	case *ast.PrefixExpressionNode:
+		if literal, ok := pattern.RightNode.(*ast.IntegerLiteralNode); ok && pattern.Operator == "-" {
+			return -literal.Value, true
+		}
+	}
+	return 0, false
+}
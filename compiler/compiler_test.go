@@ -86,6 +86,16 @@ func TestIntegerArithmetic(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "1 + 2.5",
+			expectedConstants: []interface{}{1, 2.5},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "1; 2",
 			expectedConstants: []interface{}{1, 2},
@@ -126,6 +136,15 @@ func TestIntegerArithmetic(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "~1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpBitNot),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "-1",
 			expectedConstants: []interface{}{1},
@@ -135,6 +154,56 @@ func TestIntegerArithmetic(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "1 & 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpBitAnd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 | 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpBitOr),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 ^ 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpBitXor),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 << 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpShiftLeft),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 >> 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpShiftRight),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -170,11 +239,63 @@ func TestBooleanExpressions(t *testing.T) {
 		},
 		{
 			input:             "1 < 2",
-			expectedConstants: []interface{}{2, 1},
+			expectedConstants: []interface{}{1, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpGreaterThan),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 <= 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThanOrEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 >= 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterThanOrEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "true && false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthyOrPop, 5),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "false || 1 == 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpFalse),
+				// 0001
+				code.Make(code.OpJumpTruthyOrPop, 11),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpEqual),
+				// 0011
 				code.Make(code.OpPop),
 			},
 		},
@@ -287,6 +408,221 @@ func TestConditionals(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestMatchExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "match (1) { 1, 2 => 10, _ => 20 }; 3333;",
+			expectedConstants: []interface{}{1, 1, 2, 10, 20, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpDup),
+				// 0004
+				code.Make(code.OpConstant, 1),
+				// 0007
+				code.Make(code.OpEqual),
+				// 0008
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0011
+				code.Make(code.OpJump, 22),
+				// 0014
+				code.Make(code.OpDup),
+				// 0015
+				code.Make(code.OpConstant, 2),
+				// 0018
+				code.Make(code.OpEqual),
+				// 0019
+				code.Make(code.OpJumpNotTruthy, 29),
+				// 0022
+				code.Make(code.OpPop),
+				// 0023
+				code.Make(code.OpConstant, 3),
+				// 0026
+				code.Make(code.OpJump, 33),
+				// 0029
+				code.Make(code.OpPop),
+				// 0030
+				code.Make(code.OpConstant, 4),
+				// 0033
+				code.Make(code.OpPop),
+				// 0034
+				code.Make(code.OpConstant, 5),
+				// 0037
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "match (2) { 0 => 10, 1, 3 => 11, 4 => 12 }",
+			expectedConstants: []interface{}{2, 0, 10, 11, 12},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpJumpTable, 1, 5),
+				// 0008
+				code.Make(code.OpJump, 26),
+				// 0011
+				code.Make(code.OpJump, 32),
+				// 0014
+				code.Make(code.OpJump, 44),
+				// 0017
+				code.Make(code.OpJump, 32),
+				// 0020
+				code.Make(code.OpJump, 38),
+				// 0023
+				code.Make(code.OpJump, 44),
+				// 0026
+				code.Make(code.OpConstant, 2),
+				// 0029
+				code.Make(code.OpJump, 45),
+				// 0032
+				code.Make(code.OpConstant, 3),
+				// 0035
+				code.Make(code.OpJump, 45),
+				// 0038
+				code.Make(code.OpConstant, 4),
+				// 0041
+				code.Make(code.OpJump, 45),
+				// 0044
+				code.Make(code.OpNull),
+				// 0045
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionalsEndingInStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			if (true) { let x = 1 };
+			`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpJump, 15),
+				// 0014
+				code.Make(code.OpNull),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			while (false) { 10 }; 3333;
+			`,
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpFalse),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 11),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpPop),
+				// 0008
+				code.Make(code.OpJump, 0),
+				// 0011
+				code.Make(code.OpConstant, 1),
+				// 0014
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			for (let i = 0; i < 2; let i = i + 1) { i }
+			`,
+			expectedConstants: []interface{}{0, 2, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpLessThan),
+				// 0013
+				code.Make(code.OpJumpNotTruthy, 33),
+				// 0016
+				code.Make(code.OpGetGlobal, 0),
+				// 0019
+				code.Make(code.OpPop),
+				// 0020
+				code.Make(code.OpGetGlobal, 0),
+				// 0023
+				code.Make(code.OpConstant, 2),
+				// 0026
+				code.Make(code.OpAdd),
+				// 0027
+				code.Make(code.OpSetGlobal, 0),
+				// 0030
+				code.Make(code.OpJump, 6),
+			},
+		},
+		{
+			input: `
+			for (;;) { break; continue; }
+			`,
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpJump, 9),
+				// 0003
+				code.Make(code.OpJump, 6),
+				// 0006
+				code.Make(code.OpJump, 0),
+			},
+		},
+		{
+			input: `
+			while (true) { for (;;) { break }; break }
+			`,
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 16),
+				// 0004
+				code.Make(code.OpJump, 10),
+				// 0007
+				code.Make(code.OpJump, 4),
+				// 0010
+				code.Make(code.OpJump, 16),
+				// 0013
+				code.Make(code.OpJump, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestGlobalLetStatements(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -446,6 +782,19 @@ func TestStringExpressions(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             `"a ${1} b ${[2]}"`,
+			expectedConstants: []interface{}{"a ", 1, " b ", 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConcat, 4),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -517,56 +866,125 @@ func TestHashLiterals(t *testing.T) {
 				code.Make(code.OpHash, 6),
 				code.Make(code.OpPop),
 			},
-		},
-		{
-			input:             "{1: 2 + 3, 4: 5 * 6}",
-			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+		},
+		{
+			input:             "{1: 2 + 3, 4: 5 * 6}",
+			expectedConstants: []interface{}{1, 2, 3, 4, 5, 6},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpConstant, 5),
+				code.Make(code.OpMul),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[1, 2, 3][1 + 1]",
+			expectedConstants: []interface{}{1, 2, 3, 1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 3),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpAdd),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{1: 2}[2 - 1]",
+			expectedConstants: []interface{}{1, 2, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSub),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let x = 1; x = 2;",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { let x = 1; x -= 2 }",
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSub),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpAdd),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 5),
-				code.Make(code.OpMul),
-				code.Make(code.OpHash, 4),
+				code.Make(code.OpClosure, 2, 0),
 				code.Make(code.OpPop),
 			},
 		},
-	}
-
-	runCompilerTests(t, tests)
-}
-
-func TestIndexExpressions(t *testing.T) {
-	tests := []compilerTestCase{
 		{
-			input:             "[1, 2, 3][1 + 1]",
-			expectedConstants: []interface{}{1, 2, 3, 1, 1},
+			input:             "let a = []; a[0] = 1;",
+			expectedConstants: []interface{}{0, 1},
 			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpArray, 3),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpAdd),
-				code.Make(code.OpIndex),
+				code.Make(code.OpSetIndex),
 				code.Make(code.OpPop),
 			},
 		},
 		{
-			input:             "{1: 2}[2 - 1]",
-			expectedConstants: []interface{}{1, 2, 2, 1},
+			input:             "let a = []; a[0] *= 2;",
+			expectedConstants: []interface{}{0, 2},
 			expectedInstructions: []code.Instructions{
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
 				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpHash, 2),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpSub),
+				code.Make(code.OpDupPair),
 				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMul),
+				code.Make(code.OpSetIndex),
 				code.Make(code.OpPop),
 			},
 		},
@@ -818,7 +1236,7 @@ func TestClosures(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 0, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -848,13 +1266,13 @@ func TestClosures(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureFree, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 0, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 1, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -902,15 +1320,15 @@ func TestClosures(t *testing.T) {
 				[]code.Instructions{
 					code.Make(code.OpConstant, 2),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureFree, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 4, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
 					code.Make(code.OpConstant, 1),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 5, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -922,6 +1340,37 @@ func TestClosures(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input: `
+			fn() {
+				let a = 1;
+				fn() { a += 2 }
+			}
+			`,
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSetFree, 0),
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 2, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpPop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -996,6 +1445,403 @@ func TestRecursiveFunctions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestFunctionParameters(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a, b = 1) { a + b }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpJumpIfPassed, 1, 9),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "len(1, ...[2], 3)",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSpread),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSpread),
+				code.Make(code.OpCallSpread, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "len(1, b: 2, a: 3)",
+			expectedConstants: []interface{}{1, 2, 3, "b", "a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCallNamed, 3, 2, 3),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "len(...[1], a: 2)",
+			expectedConstants: []interface{}{1, 2, "a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSpread),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCallSpread, 1, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestPatterns(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "match ([1]) { [x] if x > 0 => x, _ => 0 }",
+			expectedConstants: []interface{}{1, 0, 0, 0},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpDup),
+				// 0007
+				code.Make(code.OpMatchArray, 1, 0),
+				// 0011
+				code.Make(code.OpJumpNotTruthy, 39),
+				// 0014
+				code.Make(code.OpDup),
+				// 0015
+				code.Make(code.OpConstant, 1),
+				// 0018
+				code.Make(code.OpIndex),
+				// 0019
+				code.Make(code.OpSetGlobal, 0),
+				// 0022
+				code.Make(code.OpGetGlobal, 0),
+				// 0025
+				code.Make(code.OpConstant, 2),
+				// 0028
+				code.Make(code.OpGreaterThan),
+				// 0029
+				code.Make(code.OpJumpNotTruthy, 39),
+				// 0032
+				code.Make(code.OpPop),
+				// 0033
+				code.Make(code.OpGetGlobal, 0),
+				// 0036
+				code.Make(code.OpJump, 43),
+				// 0039
+				code.Make(code.OpPop),
+				// 0040
+				code.Make(code.OpConstant, 3),
+				// 0043
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// the failed test of the nested pattern pops the value
+			input:             `match ({}) { {"k": int(n)} => n, _ => 0 }`,
+			expectedConstants: []interface{}{"hash", "k", "k", "int", 0},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpHash, 0),
+				// 0003
+				code.Make(code.OpDup),
+				// 0004
+				code.Make(code.OpMatchType, 0),
+				// 0007
+				code.Make(code.OpJumpNotTruthy, 39),
+				// 0010
+				code.Make(code.OpDup),
+				// 0011
+				code.Make(code.OpConstant, 1),
+				// 0014
+				code.Make(code.OpHasKey),
+				// 0015
+				code.Make(code.OpJumpNotTruthy, 39),
+				// 0018
+				code.Make(code.OpDup),
+				// 0019
+				code.Make(code.OpConstant, 2),
+				// 0022
+				code.Make(code.OpIndex),
+				// 0023
+				code.Make(code.OpDup),
+				// 0024
+				code.Make(code.OpMatchType, 3),
+				// 0027
+				code.Make(code.OpJumpNotTruthy, 38),
+				// 0030
+				code.Make(code.OpDup),
+				// 0031
+				code.Make(code.OpSetGlobal, 0),
+				// 0034
+				code.Make(code.OpPop),
+				// 0035
+				code.Make(code.OpJump, 42),
+				// 0038
+				code.Make(code.OpPop),
+				// 0039
+				code.Make(code.OpJump, 49),
+				// 0042
+				code.Make(code.OpPop),
+				// 0043
+				code.Make(code.OpGetGlobal, 0),
+				// 0046
+				code.Make(code.OpJump, 53),
+				// 0049
+				code.Make(code.OpPop),
+				// 0050
+				code.Make(code.OpConstant, 4),
+				// 0053
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let [a, ...b] = [1];",
+			expectedConstants: []interface{}{1, 0},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpDup),
+				// 0007
+				code.Make(code.OpMatchArray, 1, 1),
+				// 0011
+				code.Make(code.OpJumpNotTruthy, 32),
+				// 0014
+				code.Make(code.OpDup),
+				// 0015
+				code.Make(code.OpConstant, 1),
+				// 0018
+				code.Make(code.OpIndex),
+				// 0019
+				code.Make(code.OpSetGlobal, 0),
+				// 0022
+				code.Make(code.OpDup),
+				// 0023
+				code.Make(code.OpArrayRest, 1),
+				// 0026
+				code.Make(code.OpSetGlobal, 1),
+				// 0029
+				code.Make(code.OpJump, 33),
+				// 0032
+				code.Make(code.OpMatchFailed),
+				// 0033, the names are set once the whole value matched
+				code.Make(code.OpGetGlobal, 0),
+				// 0036
+				code.Make(code.OpSetGlobal, 2),
+				// 0039
+				code.Make(code.OpGetGlobal, 1),
+				// 0042
+				code.Make(code.OpSetGlobal, 3),
+				// 0045
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestMatchWarnings(t *testing.T) {
+	tests := []struct {
+		input    string
+		kind     ErrorKind
+		position string
+	}{
+		{"match (1) { 1 => 1, 2 => 2 }", NonExhaustiveMatch, "1:1"},
+		{"match (1) { x if x > 0 => 1 }", NonExhaustiveMatch, "1:1"},
+		{"match (1) { x => 1, 2 => 2 }", UnreachableArm, "1:21"},
+		{"match (1) { 1, 2 => 1, 2 => 2, _ => 3 }", UnreachableArm, "1:24"},
+		{`match (1) { "1" => 1, 1 => 2, _ => 3 }`, "", ""},
+		{"match (1) { [a] => 1, [b] => 2, _ => 3 }", "", ""},
+		{"let [a] = [1];", "", ""},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		warnings := compiler.Warnings()
+		if tt.kind == "" {
+			if len(warnings) != 0 {
+				t.Errorf("unexpected warnings for %q: %v", tt.input, warnings)
+			}
+			continue
+		}
+
+		if len(warnings) != 1 {
+			t.Fatalf("wrong number of warnings for %q. want=1, got=%d (%v)", tt.input, len(warnings), warnings)
+		}
+		if warnings[0].Kind != tt.kind {
+			t.Errorf("wrong kind for %q. want=%s, got=%s", tt.input, tt.kind, warnings[0].Kind)
+		}
+		if warnings[0].Pos.String() != tt.position {
+			t.Errorf("wrong position for %q. want=%s, got=%s", tt.input, tt.position, warnings[0].Pos)
+		}
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+	input := `
+	let a = b + 1;
+	let f = fn(x) { x + y };
+	c;
+	d = 1;
+	len += 1;
+	fn(x) { fn() { x = z } };
+	fn(a = b, b = 1) { a };
+	fn(a = r, ...r) { a };
+	`
+
+	expected := []struct {
+		kind     ErrorKind
+		position string
+		node     string
+	}{
+		{UndefinedVariable, "2:10", "b"},
+		{UndefinedVariable, "3:22", "y"},
+		{UndefinedVariable, "4:2", "c"},
+		{UndefinedVariable, "5:2", "d"},
+		{InvalidAssignment, "6:2", "len"},
+		{UndefinedVariable, "7:21", "z"},
+		{UndefinedVariable, "8:9", "b"},
+		{UndefinedVariable, "9:9", "r"},
+	}
+
+	program := parse(input)
+
+	compiler := New()
+	err := compiler.Compile(program)
+	if err == nil {
+		t.Fatalf("expected compiler errors but resulted in none.")
+	}
+
+	errorList, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("error is not ErrorList. got=%T (%+v)", err, err)
+	}
+
+	if len(errorList) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d (%s)",
+			len(expected), len(errorList), errorList)
+	}
+
+	for i, e := range expected {
+		actual := errorList[i]
+		if actual.Kind != e.kind {
+			t.Errorf("errors[%d] - wrong kind. want=%s, got=%s", i, e.kind, actual.Kind)
+		}
+		if actual.Pos.String() != e.position {
+			t.Errorf("errors[%d] - wrong position. want=%s, got=%s", i, e.position, actual.Pos)
+		}
+		if actual.Node.String() != e.node {
+			t.Errorf("errors[%d] - wrong node. want=%s, got=%s", i, e.node, actual.Node)
+		}
+	}
+
+	if len(compiler.Errors()) != len(expected) {
+		t.Errorf("Errors() returned wrong number of errors. want=%d, got=%d",
+			len(expected), len(compiler.Errors()))
+	}
+}
+
+func TestSourceMaps(t *testing.T) {
+	input := `1 + 2;
+let f = fn(x) {
+  x * 3
+};`
+
+	program := parse(input)
+
+	compiler := New()
+	err := compiler.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := compiler.Bytecode()
+
+	mainTests := []struct {
+		offset   int
+		expected string
+	}{
+		{0, "1:1"},  // OpConstant 1
+		{3, "1:5"},  // OpConstant 2
+		{6, "1:1"},  // OpAdd
+		{7, "1:1"},  // OpPop
+		{8, "2:9"},  // OpClosure
+		{12, "2:1"}, // OpSetGlobal
+	}
+
+	for _, tt := range mainTests {
+		span, ok := bytecode.SourceMap.Lookup(tt.offset)
+		if !ok {
+			t.Fatalf("no source position for main offset %d", tt.offset)
+		}
+		if span.Start.String() != tt.expected {
+			t.Errorf("wrong position for main offset %d. want=%s, got=%s",
+				tt.offset, tt.expected, span.Start)
+		}
+	}
+
+	fn, ok := bytecode.Constants[3].(*object.CompiledFnObject)
+	if !ok {
+		t.Fatalf("constant 3 is not a function. got=%T", bytecode.Constants[3])
+	}
+
+	fnTests := []struct {
+		offset   int
+		expected string
+	}{
+		{0, "3:3"}, // OpGetLocal x
+		{2, "3:7"}, // OpConstant 3
+		{5, "3:3"}, // OpMul
+		{6, "3:3"}, // OpReturnValue
+	}
+
+	for _, tt := range fnTests {
+		span, ok := fn.SourceMap.Lookup(tt.offset)
+		if !ok {
+			t.Fatalf("no source position for function offset %d", tt.offset)
+		}
+		if span.Start.String() != tt.expected {
+			t.Errorf("wrong position for function offset %d. want=%s, got=%s",
+				tt.offset, tt.expected, span.Start)
+		}
+	}
+}
+
 func parse(input string) *ast.ProgramNode {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -1057,6 +1903,12 @@ func testConstants(
 				return fmt.Errorf("constant %d - testIntegerObject failed: %s",
 					i, err)
 			}
+		case float64:
+			float, ok := actual[i].(*object.FloatObject)
+			if !ok || float.Value != constant {
+				return fmt.Errorf("constant %d - not float %g: %T (%+v)",
+					i, constant, actual[i], actual[i])
+			}
 		case []code.Instructions:
 			fn, ok := actual[i].(*object.CompiledFnObject)
 			if !ok {
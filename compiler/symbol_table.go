@@ -22,6 +22,10 @@ type SymbolTable struct {
 	store   map[string]Symbol
 	counter int
 
+	// a block table holds the names that are only visible in a part of a
+	// function, its variables take slots of the function
+	block bool
+
 	FreeSymbols []Symbol
 }
 
@@ -31,13 +35,38 @@ func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	return s
 }
 
+// NewBlockSymbolTable creates a table for the names bound in a block of the
+// function of outer, like the bindings of a match arm
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
 func NewSymbolTable() *SymbolTable {
 	store := make(map[string]Symbol)
 	freeSymbols := []Symbol{}
 	return &SymbolTable{store: store, FreeSymbols: freeSymbols}
 }
 
+// Define binds a name in the table. A name that is already defined in the
+// same scope keeps its slot, so defining it again updates the variable.
 func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return symbol
+	}
+
+	symbol := s.allocate(name)
+	s.store[name] = symbol
+	return symbol
+}
+
+// allocate takes the next variable slot of the function the table is in
+func (s *SymbolTable) allocate(name string) Symbol {
+	if s.block {
+		return s.Outer.allocate(name)
+	}
+
 	symbol := Symbol{Name: name, Index: s.counter}
 
 	if s.Outer == nil {
@@ -46,17 +75,22 @@ func (s *SymbolTable) Define(name string) Symbol {
 		symbol.Scope = LocalScope
 	}
 
-	s.store[name] = symbol
 	s.counter++
 	return symbol
 }
 
+// bind makes a slot taken with allocate visible under its name
+func (s *SymbolTable) bind(symbol Symbol) {
+	s.store[symbol.Name] = symbol
+}
+
 func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	symbol, ok := s.store[name]
 
 	if !ok && s.Outer != nil {
 		symbol, ok = s.Outer.Resolve(name)
-		if !ok {
+		if !ok || s.block {
+			// the variables of an enclosing block are in the same function
 			return symbol, ok
 		}
 
@@ -83,6 +117,17 @@ func (s *SymbolTable) DefineFunctionName(name string) Symbol {
 	return symbol
 }
 
+// rebindFunctionName replaces the name a function refers to itself with by
+// the variable the function was defined as, for assignments to the name
+func (s *SymbolTable) rebindFunctionName(name string) (Symbol, bool) {
+	if s.block {
+		return s.Outer.rebindFunctionName(name)
+	}
+
+	delete(s.store, name)
+	return s.Resolve(name)
+}
+
 func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	s.FreeSymbols = append(s.FreeSymbols, original)
 
@@ -49,6 +49,29 @@ func TestDefine(t *testing.T) {
 	}
 }
 
+func TestRedefine(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Define("b")
+
+	expected := Symbol{Name: "a", Scope: GlobalScope, Index: 0}
+	if a := global.Define("a"); a != expected {
+		t.Errorf("expected a=%+v, got=%+v", expected, a)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("c")
+
+	// a local of the same name shadows the global
+	expected = Symbol{Name: "a", Scope: LocalScope, Index: 1}
+	if a := local.Define("a"); a != expected {
+		t.Errorf("expected a=%+v, got=%+v", expected, a)
+	}
+	if a := local.Define("a"); a != expected {
+		t.Errorf("expected a=%+v, got=%+v", expected, a)
+	}
+}
+
 func TestResolveGlobal(t *testing.T) {
 	global := NewSymbolTable()
 	global.Define("a")
@@ -335,3 +358,115 @@ func TestShadowingFunctionName(t *testing.T) {
 			expected.Name, expected, result)
 	}
 }
+
+func TestRebindFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	fnA := NewEnclosedSymbolTable(global)
+	fnA.DefineFunctionName("a")
+
+	fnB := NewEnclosedSymbolTable(firstLocal)
+	fnB.DefineFunctionName("b")
+
+	tests := []struct {
+		table    *SymbolTable
+		expected Symbol
+	}{
+		{fnA, Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{fnB, Symbol{Name: "b", Scope: FreeScope, Index: 0}},
+	}
+
+	for _, tt := range tests {
+		result, ok := tt.table.rebindFunctionName(tt.expected.Name)
+		if !ok {
+			t.Fatalf("name %s not resolvable", tt.expected.Name)
+		}
+		if result != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v",
+				tt.expected.Name, tt.expected, result)
+		}
+
+		// later references resolve to the variable too
+		result, _ = tt.table.Resolve(tt.expected.Name)
+		if result != tt.expected {
+			t.Errorf("expected %s to keep resolving to %+v, got=%+v",
+				tt.expected.Name, tt.expected, result)
+		}
+	}
+}
+
+func TestResolveBlock(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("b")
+
+	block := NewBlockSymbolTable(local)
+	block.Define("b")
+	block.Define("c")
+
+	nested := NewEnclosedSymbolTable(block)
+
+	// the block takes the slots after the locals of its function
+	local.Define("d")
+
+	tests := []struct {
+		table    *SymbolTable
+		expected []Symbol
+	}{
+		{block, []Symbol{
+			{Name: "a", Scope: GlobalScope, Index: 0},
+			{Name: "b", Scope: LocalScope, Index: 1},
+			{Name: "c", Scope: LocalScope, Index: 2},
+			{Name: "d", Scope: LocalScope, Index: 3},
+		}},
+		{local, []Symbol{
+			{Name: "b", Scope: LocalScope, Index: 0},
+			{Name: "d", Scope: LocalScope, Index: 3},
+		}},
+		{nested, []Symbol{
+			{Name: "c", Scope: FreeScope, Index: 0},
+			{Name: "b", Scope: FreeScope, Index: 1},
+		}},
+	}
+
+	for _, tt := range tests {
+		for _, sym := range tt.expected {
+			result, ok := tt.table.Resolve(sym.Name)
+			if !ok {
+				t.Errorf("name %s not resolvable", sym.Name)
+				continue
+			}
+			if result != sym {
+				t.Errorf("expected %s to resolve to %+v, got=%+v",
+					sym.Name, sym, result)
+			}
+		}
+	}
+
+	if _, ok := local.Resolve("c"); ok {
+		t.Errorf("name c of the block is resolvable outside of it")
+	}
+	if local.counter != 4 {
+		t.Errorf("wrong number of locals. want=4, got=%d", local.counter)
+	}
+
+	// the free variables of a function in the block are its locals
+	expectedFree := []Symbol{
+		{Name: "c", Scope: LocalScope, Index: 2},
+		{Name: "b", Scope: LocalScope, Index: 1},
+	}
+	if len(nested.FreeSymbols) != len(expectedFree) {
+		t.Fatalf("wrong number of free symbols. want=%d, got=%d", len(expectedFree), len(nested.FreeSymbols))
+	}
+	for i, sym := range expectedFree {
+		if nested.FreeSymbols[i] != sym {
+			t.Errorf("wrong free symbol. want=%+v, got=%+v", sym, nested.FreeSymbols[i])
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Package conformance runs Monkey programs with both the tree-walking
+// evaluator and the bytecode vm, so that the two engines can be checked
+// against each other and against golden files.
+package conformance
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// Result is the observable behaviour of a program
+type Result struct {
+	// Output is everything the program printed with puts
+	Output string
+	// Value is the value of the final expression statement, nil when the
+	// program ends with another kind of statement
+	Value object.Object
+	// Err is the parse, compile or runtime error that stopped the program
+	Err error
+}
+
+// Golden renders the result in the golden file format. Errors are written
+// as their kind, the engines word their messages differently.
+func (r Result) Golden() string {
+	var out bytes.Buffer
+
+	if r.Output != "" {
+		out.WriteString("-- output --\n")
+		out.WriteString(r.Output)
+	}
+	if r.Err != nil {
+		out.WriteString("-- error --\n")
+		out.WriteString(ErrorKind(r.Err))
+		out.WriteString("\n")
+	} else if r.Value != nil {
+		out.WriteString("-- value --\n")
+		out.WriteString(Inspect(r.Value))
+		out.WriteString("\n")
+	}
+
+	return out.String()
+}
+
+func (r Result) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("output=%q, error=%q", r.Output, r.Err)
+	}
+	return fmt.Sprintf("output=%q, value=%s", r.Output, Inspect(r.Value))
+}
+
+// Agree reports whether two results show the same behaviour, failing
+// programs agree when their errors are of the same kind
+func Agree(a, b Result) bool {
+	if a.Output != b.Output {
+		return false
+	}
+	if a.Err != nil || b.Err != nil {
+		return a.Err != nil && b.Err != nil && ErrorKind(a.Err) == ErrorKind(b.Err)
+	}
+
+	return Inspect(a.Value) == Inspect(b.Value)
+}
+
+// errorKinds maps the beginnings of the error messages of both engines to
+// the kind of the error, in lower case
+var errorKinds = []struct {
+	kind     string
+	prefixes []string
+}{
+	{"undefined variable", []string{"identifier not found", "undefined variable", "assignment to undefined variable"}},
+	{"invalid assignment", []string{"cannot assign to"}},
+	{"division by zero", []string{"division by zero"}},
+	{"integer overflow", []string{"integer overflow"}},
+	{"invalid shift count", []string{"negative shift count", "shift count too large"}},
+	{"index out of range", []string{"index out of range"}},
+	{"unusable hash key", []string{"unusable as hash key"}},
+	{"not a function", []string{"not a function", "calling non-function"}},
+	{"wrong number of arguments", []string{"wrong number of arguments"}},
+	{"pattern does not match", []string{"pattern does not match"}},
+	{"spread of a non-array", []string{"spread argument must be an array"}},
+	{"stack overflow", []string{"stack overflow"}},
+	{"unsupported operand types", []string{
+		"type mismatch", "unknown operator", "unknown integer operator",
+		"unknown float operator", "unknown string operator", "unsupported type",
+		"index operator not supported", "index assignment not supported",
+		"array index must be", "argument to `",
+	}},
+}
+
+// ErrorKind classifies an error of either engine, so that errors can be
+// compared although the engines word them differently. Compile errors of
+// the vm are classified like the runtime errors of the evaluator, and
+// unknown messages are their own kind.
+func ErrorKind(err error) string {
+	var compileErrors compiler.ErrorList
+	if errors.As(err, &compileErrors) && len(compileErrors) > 0 {
+		switch kind := compileErrors[0].Kind; kind {
+		case compiler.UndefinedVariable:
+			return "undefined variable"
+		case compiler.InvalidAssignment:
+			return "invalid assignment"
+		default:
+			return strings.ToLower(strings.ReplaceAll(string(kind), "_", " "))
+		}
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, k := range errorKinds {
+		for _, prefix := range k.prefixes {
+			if strings.HasPrefix(message, prefix) {
+				return k.kind
+			}
+		}
+	}
+
+	return message
+}
+
+// Inspect prints an object the same way for both engines. Functions are
+// printed as "fn" and hash pairs are sorted, so the output is stable.
+func Inspect(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return ""
+	case *object.FunctionObject, *object.CompiledFnObject, *object.ClosureObject:
+		return "fn"
+	case *object.BuiltinObject:
+		return "builtin"
+
+	case *object.ArrayObject:
+		elements := make([]string, len(obj.Elements))
+		for i, e := range obj.Elements {
+			elements[i] = Inspect(e)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+
+	case *object.HashObject:
+		pairs := make([]string, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, Inspect(pair.Key)+" : "+Inspect(pair.Value))
+		}
+		sort.Strings(pairs)
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+
+	return obj.Inspect()
+}
+
+// Parse parses a program, the error holds all parser diagnostics
+func Parse(filename, source string) (*ast.ProgramNode, error) {
+	p := parser.New(lexer.NewWithFilename(filename, source))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	return program, nil
+}
+
+// PanicError An engine crashed instead of reporting an error, which is a
+// bug in the engine rather than a result of the program
+type PanicError struct {
+	Value interface{}
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack)
+}
+
+// RunEvaluator runs a program with the tree-walking evaluator, the error is
+// a *PanicError when the evaluator crashed
+func RunEvaluator(program *ast.ProgramNode) (Result, error) {
+	return capture(program, func() (object.Object, error) {
+		result := evaluator.Eval(program, object.NewEnvironment())
+		if errObj, ok := result.(*object.ErrorObject); ok {
+			return nil, errors.New(errObj.Message)
+		}
+
+		return result, nil
+	})
+}
+
+// RunVM compiles a program and runs it with the vm, the error is a
+// *PanicError when the compiler or the vm crashed
+func RunVM(program *ast.ProgramNode) (Result, error) {
+	return capture(program, func() (object.Object, error) {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return nil, err
+		}
+
+		machine := vm.New(comp.Bytecode())
+		if err := machine.Run(); err != nil {
+			return nil, err
+		}
+
+		return machine.LastPoppedStackElem(), nil
+	})
+}
+
+// capture runs an engine with the puts output redirected. Panics are
+// returned as a *PanicError, so that a crash is never mistaken for an error
+// reported by the program.
+func capture(program *ast.ProgramNode, run func() (object.Object, error)) (result Result, err error) {
+	var out bytes.Buffer
+
+	stdout := object.Stdout
+	object.Stdout = &out
+
+	defer func() {
+		object.Stdout = stdout
+		result.Output = out.String()
+
+		if r := recover(); r != nil {
+			result = Result{}
+			err = &PanicError{Value: r, Stack: string(debug.Stack())}
+		}
+	}()
+
+	result.Value, result.Err = run()
+	if !endsWithExpression(program) {
+		result.Value = nil
+	}
+
+	return result, nil
+}
+
+func endsWithExpression(program *ast.ProgramNode) bool {
+	if len(program.StatementNodes) == 0 {
+		return false
+	}
+
+	_, ok := program.StatementNodes[len(program.StatementNodes)-1].(*ast.ExpressionStatementNode)
+	return ok
+}
@@ -0,0 +1,196 @@
+package conformance
+
+import (
+	"errors"
+	"flag"
+	"math/rand"
+	"monkey/ast"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files from the vm results")
+
+func TestConformance(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.monkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no conformance programs found in testdata")
+	}
+
+	for _, file := range files {
+		file := file
+		name := strings.TrimSuffix(filepath.Base(file), ".monkey")
+
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			program, err := Parse(file, string(source))
+			if err != nil {
+				t.Fatalf("parser errors:\n%s", err)
+			}
+
+			evaluated, err := RunEvaluator(program)
+			if err != nil {
+				t.Fatalf("evaluator crashed: %s", err)
+			}
+			executed, err := RunVM(program)
+			if err != nil {
+				t.Fatalf("vm crashed: %s", err)
+			}
+
+			results := []struct {
+				engine string
+				result Result
+			}{
+				{"evaluator", evaluated},
+				{"vm", executed},
+			}
+
+			golden := strings.TrimSuffix(file, ".monkey") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(results[1].result.Golden()), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%s, run the tests with -update to create it", err)
+			}
+
+			for _, r := range results {
+				if got := r.result.Golden(); got != string(expected) {
+					t.Errorf("%s result does not match %s.\nexpected:\n%s\ngot:\n%s\n(%s)",
+						r.engine, golden, expected, got, r.result)
+				}
+			}
+		})
+	}
+}
+
+func FuzzEngines(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte("monkey"))
+
+	// a fixed random corpus, so that plain go test covers a good range of
+	// generated programs
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		data := make([]byte, 16+r.Intn(112))
+		r.Read(data)
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		program := Generate(data)
+
+		evaluated, err := RunEvaluator(program)
+		if err != nil {
+			t.Fatalf("evaluator crashed on\n%s\n%s", program.String(), err)
+		}
+		executed, err := RunVM(program)
+		if err != nil {
+			t.Fatalf("vm crashed on\n%s\n%s", program.String(), err)
+		}
+
+		if !Agree(evaluated, executed) {
+			t.Fatalf("engines disagree on\n%s\nevaluator: %s\nvm: %s",
+				program.String(), evaluated, executed)
+		}
+	})
+}
+
+func TestAgree(t *testing.T) {
+	division := Result{Err: errors.New("division by zero")}
+	mismatch := Result{Err: errors.New("type mismatch: INT + BOOL")}
+	unsupported := Result{Err: errors.New("unsupported types for binary operation: INT BOOL")}
+	one := Result{Value: &object.IntObject{Value: 1}}
+
+	tests := []struct {
+		a, b     Result
+		expected bool
+	}{
+		{division, division, true},
+		{mismatch, unsupported, true},
+		{division, mismatch, false},
+		{division, one, false},
+		{one, one, true},
+		{Result{Output: "1\n", Err: division.Err}, division, false},
+	}
+
+	for _, tt := range tests {
+		if Agree(tt.a, tt.b) != tt.expected {
+			t.Errorf("Agree(%s, %s): want=%t", tt.a, tt.b, tt.expected)
+		}
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x", "undefined variable"},
+		{"x = 1", "undefined variable"},
+		{"len = 1", "invalid assignment"},
+		{"1 / 0", "division by zero"},
+		{"[1][5] = 1", "index out of range"},
+		{"1 + true", "unsupported operand types"},
+		{`"a" - "b"`, "unsupported operand types"},
+		{"1(2)", "not a function"},
+		{"fn(a) { a }()", "wrong number of arguments"},
+		{"let [a] = [];", "pattern does not match"},
+	}
+
+	for _, tt := range tests {
+		program, err := Parse("test.monkey", tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, run := range []func(*ast.ProgramNode) (Result, error){RunEvaluator, RunVM} {
+			result, err := run(program)
+			if err != nil {
+				t.Fatalf("engine crashed on %q: %s", tt.input, err)
+			}
+			if result.Err == nil {
+				t.Fatalf("no error for %q, got %s", tt.input, result)
+			}
+			if kind := ErrorKind(result.Err); kind != tt.expected {
+				t.Errorf("wrong error kind for %q. want=%q, got=%q (%s)", tt.input, tt.expected, kind, result.Err)
+			}
+		}
+	}
+}
+
+func TestCapturePanic(t *testing.T) {
+	program, err := Parse("test.monkey", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := object.Stdout
+	result, err := capture(program, func() (object.Object, error) {
+		panic("engine bug")
+	})
+
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "engine bug" {
+		t.Fatalf("panic not reported as *PanicError. got=%v", err)
+	}
+	if result.Err != nil || result.Value != nil {
+		t.Errorf("panic became a result: %s", result)
+	}
+	if object.Stdout != stdout {
+		t.Errorf("puts output not restored after a panic")
+	}
+}
@@ -0,0 +1,754 @@
+package conformance
+
+import (
+	"fmt"
+	"math/big"
+	"monkey/ast"
+	"monkey/token"
+	"strconv"
+	"strings"
+)
+
+// maxDepth bounds the nesting of generated expressions
+const maxDepth = 4
+
+type valueType int
+
+const (
+	intType valueType = iota
+	boolType
+	stringType
+	arrayType
+	hashType
+	floatType
+	numValueTypes
+)
+
+type variable struct {
+	name string
+	typ  valueType
+}
+
+// function is a variable holding a function, along with its signature
+type function struct {
+	name     string
+	params   []valueType
+	names    []string // names of the parameters
+	defaults int      // the last parameters that have a default value
+	variadic bool     // further arguments go to a rest parameter
+	returns  valueType
+}
+
+// generator builds programs from a byte string, where every byte is one
+// decision. Running out of bytes always picks the first choice, which is
+// a leaf, so every input gives a finite program.
+type generator struct {
+	data []byte
+	pos  int
+
+	vars   []variable
+	fns    []function
+	nextID int
+}
+
+// Generate builds a random, well-formed and terminating program from data.
+// Similar inputs give similar programs, which suits the go fuzzer.
+func Generate(data []byte) *ast.ProgramNode {
+	g := &generator{data: data}
+	program := &ast.ProgramNode{}
+
+	for n := g.choose(6); n > 0; n-- {
+		program.StatementNodes = append(program.StatementNodes, g.statement())
+	}
+
+	typ := valueType(g.choose(int(numValueTypes)))
+	program.StatementNodes = append(program.StatementNodes, exprStatement(g.expression(typ, 0)))
+
+	return program
+}
+
+func (g *generator) choose(n int) int {
+	if g.pos >= len(g.data) {
+		return 0
+	}
+
+	b := g.data[g.pos]
+	g.pos++
+
+	return int(b) % n
+}
+
+func (g *generator) newName(prefix string) string {
+	g.nextID++
+	return fmt.Sprintf("%s%d", prefix, g.nextID)
+}
+
+func (g *generator) statement() ast.StatementNode {
+	switch g.choose(6) {
+	case 1:
+		return g.functionDefinition()
+	case 3:
+		return g.loop()
+	case 2:
+		// puts only prints scalars, hashes are printed in random order
+		typ := valueType(g.choose(int(stringType) + 1))
+		return exprStatement(call(ident("puts"), g.expression(typ, 1)))
+	case 4:
+		if g.choose(4) == 0 {
+			return g.destructuring()
+		}
+		return g.assignment()
+	default:
+		typ := valueType(g.choose(int(numValueTypes)))
+		value := g.expression(typ, 0)
+
+		name := g.newName("v")
+		g.vars = append(g.vars, variable{name, typ})
+
+		return let(name, value)
+	}
+}
+
+// loop builds a counting for loop that prints a value in each iteration,
+// and may leave an iteration early with break or continue
+func (g *generator) loop() ast.StatementNode {
+	counter := g.newName("i")
+	g.vars = append(g.vars, variable{counter, intType})
+
+	body := block(exprStatement(call(ident("puts"), g.expression(intType, 1))))
+	switch g.choose(3) {
+	case 1:
+		branch := &ast.BreakStatementNode{Token: token.Token{Type: token.BREAK, Literal: "break"}}
+		body.StatementNodes = append(body.StatementNodes, g.branchIf(branch))
+	case 2:
+		branch := &ast.ContinueStatementNode{Token: token.Token{Type: token.CONTINUE, Literal: "continue"}}
+		body.StatementNodes = append([]ast.StatementNode{g.branchIf(branch)}, body.StatementNodes...)
+	}
+
+	return &ast.ForStatementNode{
+		Token:         token.Token{Type: token.FOR, Literal: "for"},
+		InitNode:      let(counter, intLiteral(0)),
+		ConditionNode: infix(ident(counter), "<", intLiteral(int64(g.choose(5)))),
+		UpdateNode:    exprStatement(assign(ident(counter), "+=", intLiteral(1))),
+		BodyNode:      body,
+	}
+}
+
+// assignment updates a variable of the program with a value of the same
+// type, integers may be updated with a compound assignment
+func (g *generator) assignment() ast.StatementNode {
+	typ := valueType(g.choose(int(numValueTypes)))
+	target := g.variable(typ)
+	if _, ok := target.(*ast.IdentifierNode); !ok {
+		// there is no variable of the type, define one instead
+		name := g.newName("v")
+		g.vars = append(g.vars, variable{name, typ})
+		return let(name, target)
+	}
+
+	operator := "="
+	if typ == intType {
+		operator = []string{"=", "+=", "-=", "*="}[g.choose(4)]
+	}
+
+	return exprStatement(assign(target, operator, g.expression(typ, 1)))
+}
+
+// destructuring builds a let with an array pattern, of a value that always
+// matches it
+func (g *generator) destructuring() ast.StatementNode {
+	value := call(ident("push"), g.expression(arrayType, 1), g.expression(intType, 1))
+
+	first, rest := g.newName("v"), g.newName("v")
+	g.vars = append(g.vars, variable{first, intType}, variable{rest, arrayType})
+
+	return &ast.LetStatementNode{
+		Token: token.Token{Type: token.LET, Literal: "let"},
+		PatternNode: &ast.ArrayPatternNode{
+			Token:    token.Token{Type: token.LBRACKET, Literal: "["},
+			Elements: []ast.ExpressionNode{ident(first)},
+			Rest:     ident(rest),
+		},
+		ValueNode: value,
+	}
+}
+
+// branchIf wraps a break or continue in an if with a random condition
+func (g *generator) branchIf(branch ast.StatementNode) ast.StatementNode {
+	return exprStatement(&ast.IfExpressionNode{
+		Token:           token.Token{Type: token.IF, Literal: "if"},
+		ConditionNode:   g.expression(boolType, 1),
+		ConsequenceNode: block(branch),
+	})
+}
+
+func (g *generator) functionDefinition() ast.StatementNode {
+	returns := valueType(g.choose(int(numValueTypes)))
+	sig := g.signature(g.newName("f"), returns)
+
+	fn := g.functionLiteral(sig, 1)
+	fn.Name = sig.name
+
+	g.fns = append(g.fns, sig)
+
+	return let(sig.name, fn)
+}
+
+// signature picks the parameters of a new function, some of them may have
+// default values and it may take further arguments
+func (g *generator) signature(name string, returns valueType) function {
+	sig := function{name: name, params: make([]valueType, g.choose(3)), returns: returns}
+	for i := range sig.params {
+		sig.params[i] = valueType(g.choose(int(numValueTypes)))
+		sig.names = append(sig.names, g.newName("p"))
+	}
+	if len(sig.params) > 0 && g.choose(3) == 0 {
+		sig.defaults = 1 + g.choose(len(sig.params))
+	}
+	sig.variadic = g.choose(4) == 0
+
+	return sig
+}
+
+// functionLiteral builds a function whose body can use the parameters and
+// every variable in scope, so nested functions capture free variables
+func (g *generator) functionLiteral(sig function, depth int) *ast.FunctionLiteralNode {
+	fn := &ast.FunctionLiteralNode{
+		Token:    token.Token{Type: token.FUNCTION, Literal: "fn"},
+		BodyNode: &ast.BlockStatementNode{},
+	}
+
+	scope := len(g.vars)
+	required := len(sig.params) - sig.defaults
+	for i, typ := range sig.params {
+		name := sig.names[i]
+		fn.ParamNodes = append(fn.ParamNodes, ident(name))
+		// a default value can use the parameters before it
+		if i >= required {
+			fn.DefaultNodes = append(fn.DefaultNodes, g.expression(typ, depth+1))
+		}
+		g.vars = append(g.vars, variable{name, typ})
+	}
+	if sig.variadic {
+		name := g.newName("p")
+		fn.RestNode = ident(name)
+		g.vars = append(g.vars, variable{name, arrayType})
+	}
+
+	body := fn.BodyNode
+	if g.choose(3) == 1 {
+		// an early return from inside a conditional
+		body.StatementNodes = append(body.StatementNodes, exprStatement(&ast.IfExpressionNode{
+			Token:           token.Token{Type: token.IF, Literal: "if"},
+			ConditionNode:   g.expression(boolType, depth+1),
+			ConsequenceNode: block(ret(g.expression(sig.returns, depth+1))),
+		}))
+	}
+	body.StatementNodes = append(body.StatementNodes, exprStatement(g.expression(sig.returns, depth+1)))
+
+	g.vars = g.vars[:scope]
+
+	return fn
+}
+
+func (g *generator) expression(typ valueType, depth int) ast.ExpressionNode {
+	if depth >= maxDepth {
+		return g.leaf(typ)
+	}
+
+	switch g.choose(6) {
+	case 1:
+		return g.variable(typ)
+	case 2:
+		if g.choose(2) == 0 {
+			return g.match(typ, depth)
+		}
+		return &ast.IfExpressionNode{
+			Token:           token.Token{Type: token.IF, Literal: "if"},
+			ConditionNode:   g.expression(boolType, depth+1),
+			ConsequenceNode: block(exprStatement(g.expression(typ, depth+1))),
+			AlternativeNode: block(exprStatement(g.expression(typ, depth+1))),
+		}
+	case 3:
+		return g.call(typ, depth)
+	case 4, 5:
+		return g.operation(typ, depth)
+	default:
+		return g.leaf(typ)
+	}
+}
+
+// match builds a match on a value of any type, with literal, name, array,
+// hash and type check patterns and guards. It always ends in a _ arm, a
+// match without a matching arm is null, which has no type.
+func (g *generator) match(typ valueType, depth int) ast.ExpressionNode {
+	subjectType := valueType(g.choose(int(numValueTypes)))
+	node := &ast.MatchExpressionNode{
+		Token:       token.Token{Type: token.MATCH, Literal: "match"},
+		SubjectNode: g.expression(subjectType, depth+1),
+	}
+
+	arms := 1 + g.choose(5)
+	for i := 0; i < arms; i++ {
+		// the names a pattern binds are only used in its arm, they are not
+		// set when the arm does not match
+		scope := len(g.vars)
+
+		arm := &ast.MatchArmNode{}
+		if subjectType <= stringType && g.choose(2) == 0 {
+			for j := 1 + g.choose(2); j > 0; j-- {
+				pattern := g.leaf(subjectType)
+				if subjectType == intType && g.choose(4) != 0 {
+					// small integers, which make jump tables
+					pattern = intLiteral(int64(g.choose(8)))
+				}
+				arm.Patterns = append(arm.Patterns, pattern)
+			}
+		} else {
+			arm.Patterns = []ast.ExpressionNode{g.pattern(subjectType)}
+		}
+
+		if g.choose(4) == 0 {
+			arm.GuardNode = g.expression(boolType, depth+1)
+		}
+		arm.BodyNode = block(exprStatement(g.expression(typ, depth+1)))
+
+		g.vars = g.vars[:scope]
+		node.Arms = append(node.Arms, arm)
+	}
+
+	node.Arms = append(node.Arms, &ast.MatchArmNode{
+		Patterns: []ast.ExpressionNode{ident("_")},
+		BodyNode: block(exprStatement(g.expression(typ, depth+1))),
+	})
+
+	return node
+}
+
+// typeNames are the names of the value types in type check patterns
+var typeNames = []string{"int", "bool", "string", "array", "hash", "float"}
+
+// pattern builds a pattern for values of typ, the names it binds are added
+// to the variables
+func (g *generator) pattern(typ valueType) ast.ExpressionNode {
+	switch g.choose(4) {
+	case 1:
+		return g.binding(typ)
+	case 2:
+		// mostly the type of the value, which matches
+		typeName := typeNames[typ]
+		if g.choose(4) == 0 {
+			typeName = typeNames[g.choose(len(typeNames))]
+		}
+		return &ast.TypePatternNode{
+			Token:       token.Token{Type: token.IDENT, Literal: typeName},
+			TypeName:    typeName,
+			PatternNode: g.binding(typ),
+		}
+	case 3:
+		if typ == arrayType {
+			return g.arrayPattern()
+		}
+		if typ == hashType {
+			return g.hashPattern()
+		}
+	}
+
+	if typ == arrayType || typ == hashType {
+		return ident("_")
+	}
+	return g.leaf(typ)
+}
+
+// binding binds a new variable of typ, or is _
+func (g *generator) binding(typ valueType) *ast.IdentifierNode {
+	if g.choose(4) == 0 {
+		return ident("_")
+	}
+
+	name := g.newName("p")
+	g.vars = append(g.vars, variable{name, typ})
+	return ident(name)
+}
+
+// elementPattern is a pattern for the integer elements of arrays and hashes
+func (g *generator) elementPattern() ast.ExpressionNode {
+	if g.choose(3) == 0 {
+		return intLiteral(int64(g.choose(4)))
+	}
+	return g.binding(intType)
+}
+
+func (g *generator) arrayPattern() ast.ExpressionNode {
+	pattern := &ast.ArrayPatternNode{Token: token.Token{Type: token.LBRACKET, Literal: "["}}
+
+	for i := g.choose(3); i > 0; i-- {
+		pattern.Elements = append(pattern.Elements, g.elementPattern())
+	}
+	if g.choose(2) == 0 {
+		pattern.Rest = g.binding(arrayType)
+	}
+
+	return pattern
+}
+
+func (g *generator) hashPattern() ast.ExpressionNode {
+	pattern := &ast.HashPatternNode{Token: token.Token{Type: token.LBRACE, Literal: "{"}}
+
+	// the generated hashes have keys from 1 to 3
+	for key := 1; key <= 3; key++ {
+		if g.choose(2) == 0 {
+			pattern.Keys = append(pattern.Keys, intLiteral(int64(key)))
+			pattern.Values = append(pattern.Values, g.elementPattern())
+		}
+	}
+
+	return pattern
+}
+
+func (g *generator) variable(typ valueType) ast.ExpressionNode {
+	var candidates []string
+	for _, v := range g.vars {
+		if v.typ == typ {
+			candidates = append(candidates, v.name)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return g.leaf(typ)
+	}
+
+	return ident(candidates[g.choose(len(candidates))])
+}
+
+// call calls a defined function, or an immediately invoked function
+// literal, that returns typ
+func (g *generator) call(typ valueType, depth int) ast.ExpressionNode {
+	var candidates []function
+	for _, fn := range g.fns {
+		if fn.returns == typ {
+			candidates = append(candidates, fn)
+		}
+	}
+
+	if len(candidates) > 0 && g.choose(2) == 0 {
+		fn := candidates[g.choose(len(candidates))]
+		return call(ident(fn.name), g.arguments(fn, depth)...)
+	}
+
+	sig := g.signature("", typ)
+
+	return call(g.functionLiteral(sig, depth+1), g.arguments(sig, depth)...)
+}
+
+// arguments builds the arguments of a call, leaving out some that have a
+// default value or passing extra ones to a rest parameter. The last ones
+// may be passed as a spread array or by name.
+func (g *generator) arguments(fn function, depth int) []ast.ExpressionNode {
+	if len(fn.params) > 0 && g.choose(4) == 0 {
+		return g.namedArguments(fn, depth)
+	}
+
+	numArgs := len(fn.params) - g.choose(fn.defaults+1)
+	args := make([]ast.ExpressionNode, numArgs)
+	for i, typ := range fn.params[:numArgs] {
+		args[i] = g.expression(typ, depth+1)
+	}
+
+	if numArgs > 0 && g.choose(4) == 0 {
+		spreadFrom := g.choose(numArgs)
+		spreadArgs := append([]ast.ExpressionNode{}, args[spreadFrom:]...)
+		args = append(args[:spreadFrom], spread(array(spreadArgs...)))
+	}
+
+	if fn.variadic && numArgs == len(fn.params) {
+		switch g.choose(3) {
+		case 1:
+			args = append(args, g.expression(valueType(g.choose(int(numValueTypes))), depth+1))
+		case 2:
+			args = append(args, spread(g.expression(arrayType, depth+1)))
+		}
+	}
+
+	return args
+}
+
+// namedArguments passes the parameters from some index on by name, in
+// reverse order and leaving out some that have a default value
+func (g *generator) namedArguments(fn function, depth int) []ast.ExpressionNode {
+	from := g.choose(len(fn.params))
+
+	var args []ast.ExpressionNode
+	for _, typ := range fn.params[:from] {
+		args = append(args, g.expression(typ, depth+1))
+	}
+
+	required := len(fn.params) - fn.defaults
+	for i := len(fn.params) - 1; i >= from; i-- {
+		if i >= required && g.choose(2) == 0 {
+			continue
+		}
+		args = append(args, namedArgument(fn.names[i], g.expression(fn.params[i], depth+1)))
+	}
+
+	return args
+}
+
+func (g *generator) operation(typ valueType, depth int) ast.ExpressionNode {
+	switch typ {
+	case intType:
+		switch g.choose(6) {
+		case 1:
+			return prefix([]string{"-", "~"}[g.choose(2)], g.expression(intType, depth+1))
+		case 2:
+			return call(ident("len"), g.expression(arrayType, depth+1))
+		case 5:
+			return call(ident("len"), g.expression(stringType, depth+1))
+		case 3:
+			return index(g.expression(arrayType, depth+1), g.expression(intType, depth+1))
+		case 4:
+			return index(g.expression(hashType, depth+1), g.expression(intType, depth+1))
+		default:
+			operator := []string{"+", "-", "*", "/", "%", "&", "|", "^", "<<", ">>"}[g.choose(10)]
+			return infix(g.expression(intType, depth+1), operator, g.expression(intType, depth+1))
+		}
+
+	case boolType:
+		switch g.choose(5) {
+		case 1:
+			return prefix("!", g.expression(valueType(g.choose(int(numValueTypes))), depth+1))
+		case 2:
+			operator := []string{"==", "!="}[g.choose(2)]
+			operand := valueType(g.choose(int(stringType) + 1))
+			return infix(g.expression(operand, depth+1), operator, g.expression(operand, depth+1))
+		case 3:
+			operator := []string{"<", ">", "<=", ">=", "==", "!="}[g.choose(6)]
+			return infix(g.expression(floatType, depth+1), operator, g.expression(intType, depth+1))
+		case 4:
+			operator := []string{"&&", "||"}[g.choose(2)]
+			return infix(g.expression(boolType, depth+1), operator, g.expression(boolType, depth+1))
+		default:
+			operator := []string{"<", ">", "<=", ">=", "==", "!="}[g.choose(6)]
+			return infix(g.expression(intType, depth+1), operator, g.expression(intType, depth+1))
+		}
+
+	case stringType:
+		switch g.choose(4) {
+		case 1:
+			// an index out of range yields null, which both engines reject in "+"
+			return index(g.expression(stringType, depth+1), g.expression(intType, depth+1))
+		case 2:
+			return g.interpolatedString(depth)
+		default:
+			return infix(g.expression(stringType, depth+1), "+", g.expression(stringType, depth+1))
+		}
+
+	case floatType:
+		if g.choose(4) == 1 {
+			return prefix("-", g.expression(floatType, depth+1))
+		}
+		// one side may be an int, which is converted
+		operator := []string{"+", "-", "*", "/", "%"}[g.choose(5)]
+		left, right := g.expression(floatType, depth+1), g.expression(floatType, depth+1)
+		switch g.choose(3) {
+		case 1:
+			left = g.expression(intType, depth+1)
+		case 2:
+			right = g.expression(intType, depth+1)
+		}
+		return infix(left, operator, right)
+
+	case arrayType:
+		switch g.choose(3) {
+		case 1:
+			return call(ident("rest"), g.expression(arrayType, depth+1))
+		case 2:
+			return call(ident("push"), g.expression(arrayType, depth+1), g.expression(intType, depth+1))
+		default:
+			elements := make([]ast.ExpressionNode, g.choose(4))
+			for i := range elements {
+				elements[i] = g.expression(intType, depth+1)
+			}
+			return array(elements...)
+		}
+
+	default:
+		// keys are distinct literals, duplicate keys would make the result
+		// depend on the evaluation order of the pairs
+		hash := &ast.HashLiteralNode{
+			Token: token.Token{Type: token.LBRACE, Literal: "{"},
+			Pairs: map[ast.ExpressionNode]ast.ExpressionNode{},
+		}
+		for i := g.choose(4); i > 0; i-- {
+			hash.Pairs[intLiteral(int64(i))] = g.expression(intType, depth+1)
+		}
+		return hash
+	}
+}
+
+// interpolatedString returns an interpolated string of values of any type,
+// which checks that both engines render them the same way
+func (g *generator) interpolatedString(depth int) ast.ExpressionNode {
+	str := &ast.InterpolatedStringNode{
+		Token:    token.Token{Type: token.TEMPLATE_HEAD, Literal: "<"},
+		EndToken: token.Token{Type: token.TEMPLATE_TAIL, Literal: ">"},
+	}
+
+	str.Parts = append(str.Parts, g.leaf(stringType))
+	for i := g.choose(3); i >= 0; i-- {
+		typ := valueType(g.choose(int(numValueTypes)))
+		str.Parts = append(str.Parts, g.expression(typ, depth+1))
+	}
+
+	return str
+}
+
+func (g *generator) leaf(typ valueType) ast.ExpressionNode {
+	switch typ {
+	case intType:
+		if g.choose(8) == 0 {
+			// big integers mix with the others in every int operation
+			literal := []string{"0n", "3n", "9223372036854775807n", "100000000000000000000n"}[g.choose(4)]
+			value, _ := new(big.Int).SetString(strings.TrimSuffix(literal, "n"), 10)
+			return &ast.BigIntLiteralNode{
+				Token: token.Token{Type: token.BIGINT, Literal: literal},
+				Value: value,
+			}
+		}
+		value := int64(g.choose(21) - 5)
+		if value < 0 {
+			return prefix("-", intLiteral(-value))
+		}
+		return intLiteral(value)
+	case boolType:
+		if g.choose(2) == 0 {
+			return &ast.BooleanNode{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true}
+		}
+		return &ast.BooleanNode{Token: token.Token{Type: token.FALSE, Literal: "false"}, Value: false}
+	case stringType:
+		value := []string{"", "a", "b", "monkey", "héllo", "世界"}[g.choose(6)]
+		return &ast.StringLiteralNode{
+			Token: token.Token{Type: token.STRING, Literal: value},
+			Value: value,
+		}
+	case floatType:
+		literal := []string{"0.5", "1.5", "2.25", "1e3", "1.5e-3"}[g.choose(5)]
+		value, _ := strconv.ParseFloat(literal, 64)
+		return &ast.FloatLiteralNode{
+			Token: token.Token{Type: token.FLOAT, Literal: literal},
+			Value: value,
+		}
+	case arrayType:
+		elements := make([]ast.ExpressionNode, g.choose(3))
+		for i := range elements {
+			elements[i] = g.leaf(intType)
+		}
+		return array(elements...)
+	default:
+		return &ast.HashLiteralNode{
+			Token: token.Token{Type: token.LBRACE, Literal: "{"},
+			Pairs: map[ast.ExpressionNode]ast.ExpressionNode{intLiteral(1): g.leaf(intType)},
+		}
+	}
+}
+
+func ident(name string) *ast.IdentifierNode {
+	return &ast.IdentifierNode{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func intLiteral(value int64) *ast.IntegerLiteralNode {
+	return &ast.IntegerLiteralNode{
+		Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(value, 10)},
+		Value: value,
+	}
+}
+
+func prefix(operator string, right ast.ExpressionNode) ast.ExpressionNode {
+	return &ast.PrefixExpressionNode{
+		Token:     token.Token{Type: token.TokenType(operator), Literal: operator},
+		Operator:  operator,
+		RightNode: right,
+	}
+}
+
+func infix(left ast.ExpressionNode, operator string, right ast.ExpressionNode) ast.ExpressionNode {
+	return &ast.InfixExpressionNode{
+		Token:     token.Token{Type: token.TokenType(operator), Literal: operator},
+		LeftNode:  left,
+		Operator:  operator,
+		RightNode: right,
+	}
+}
+
+func call(fn ast.ExpressionNode, args ...ast.ExpressionNode) ast.ExpressionNode {
+	return &ast.CallExpressionNode{
+		Token:    token.Token{Type: token.LPAREN, Literal: "("},
+		FnNode:   fn,
+		ArgNodes: args,
+	}
+}
+
+func spread(value ast.ExpressionNode) ast.ExpressionNode {
+	return &ast.SpreadNode{
+		Token:     token.Token{Type: token.ELLIPSIS, Literal: "..."},
+		ValueNode: value,
+	}
+}
+
+func namedArgument(name string, value ast.ExpressionNode) ast.ExpressionNode {
+	return &ast.NamedArgumentNode{
+		Token:     token.Token{Type: token.IDENT, Literal: name},
+		Name:      ident(name),
+		ValueNode: value,
+	}
+}
+
+func index(left, index ast.ExpressionNode) ast.ExpressionNode {
+	return &ast.IndexExpressionNode{
+		Token: token.Token{Type: token.LBRACKET, Literal: "["},
+		Left:  left,
+		Index: index,
+	}
+}
+
+func array(elements ...ast.ExpressionNode) ast.ExpressionNode {
+	return &ast.ArrayLiteralNode{
+		Token:    token.Token{Type: token.LBRACKET, Literal: "["},
+		Elements: elements,
+	}
+}
+
+func block(statements ...ast.StatementNode) *ast.BlockStatementNode {
+	return &ast.BlockStatementNode{
+		Token:          token.Token{Type: token.LBRACE, Literal: "{"},
+		StatementNodes: statements,
+	}
+}
+
+func assign(target ast.ExpressionNode, operator string, value ast.ExpressionNode) ast.ExpressionNode {
+	return &ast.AssignExpressionNode{
+		Token:     token.Token{Type: token.TokenType(operator), Literal: operator},
+		Target:    target,
+		Operator:  operator,
+		ValueNode: value,
+	}
+}
+
+func let(name string, value ast.ExpressionNode) ast.StatementNode {
+	return &ast.LetStatementNode{
+		Token:     token.Token{Type: token.LET, Literal: "let"},
+		NameNode:  *ident(name),
+		ValueNode: value,
+	}
+}
+
+func ret(value ast.ExpressionNode) ast.StatementNode {
+	return &ast.ReturnStatementNode{
+		Token:           token.Token{Type: token.RETURN, Literal: "return"},
+		ReturnValueNode: value,
+	}
+}
+
+func exprStatement(expr ast.ExpressionNode) ast.StatementNode {
+	return &ast.ExpressionStatementNode{ExpressionNode: expr}
+}
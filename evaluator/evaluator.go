@@ -9,92 +9,164 @@ var (
 	NULL  = &object.NullObject{}
 	TRUE  = &object.BoolObject{Value: true}
 	FALSE = &object.BoolObject{Value: false}
+
+	BREAK    = &object.BreakObject{}
+	CONTINUE = &object.ContinueObject{}
 )
 
+// Config holds the settings of an Evaluator
+type Config struct {
+	// Overflow is what happens when an integer operation overflows, the
+	// default is to wrap around
+	Overflow object.OverflowPolicy
+}
+
+// Evaluator is a tree-walking interpreter
+type Evaluator struct {
+	config Config
+}
+
+func New(config Config) *Evaluator {
+	return &Evaluator{config: config}
+}
+
+// Eval evaluates a node with the default configuration
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(Config{}).Eval(node, env)
+}
+
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.ProgramNode:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 
 	case *ast.BlockStatementNode:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 
 	case *ast.ExpressionStatementNode:
-		return Eval(node.ExpressionNode, env)
+		return e.Eval(node.ExpressionNode, env)
 
 	case *ast.ReturnStatementNode:
-		resultObject := Eval(node.ReturnValueNode, env)
+		resultObject := e.Eval(node.ReturnValueNode, env)
 		if isError(resultObject) {
 			return resultObject
 		}
 		return &object.ReturnValueObject{ValueObject: resultObject}
 
+	case *ast.WhileStatementNode:
+		return e.evalWhileStatement(node, env)
+
+	case *ast.ForStatementNode:
+		return e.evalForStatement(node, env)
+
+	case *ast.BreakStatementNode:
+		return BREAK
+
+	case *ast.ContinueStatementNode:
+		return CONTINUE
+
 	case *ast.LetStatementNode:
-		resultObject := Eval(node.ValueNode, env)
+		resultObject := e.Eval(node.ValueNode, env)
 		if isError(resultObject) {
 			return resultObject
 		}
+
+		if node.PatternNode != nil {
+			// the names are only set once the whole value matched
+			patternEnv := object.NewEnclosedEnvironment(env)
+			if !e.matchPattern(node.PatternNode, resultObject, patternEnv) {
+				return newErrorObject("pattern does not match %s", resultObject.Inspect())
+			}
+			for _, name := range ast.PatternBindings(node.PatternNode) {
+				value, _ := patternEnv.Get(name)
+				env.Set(name, value)
+			}
+			return nil
+		}
+
 		env.Set(node.NameNode.Value, resultObject)
 
 	// Expressions
 	case *ast.IntegerLiteralNode:
 		return &object.IntObject{Value: node.Value}
 
+	case *ast.BigIntLiteralNode:
+		return &object.BigIntObject{Value: node.Value}
+
+	case *ast.FloatLiteralNode:
+		return &object.FloatObject{Value: node.Value}
+
 	case *ast.StringLiteralNode:
 		return &object.StringObject{Value: node.Value}
 
+	case *ast.InterpolatedStringNode:
+		return e.evalInterpolatedString(node, env)
+
 	case *ast.BooleanNode:
 		return nativeBoolToObject(node.Value)
 
 	case *ast.PrefixExpressionNode:
-		rightObject := Eval(node.RightNode, env)
+		rightObject := e.Eval(node.RightNode, env)
 		if isError(rightObject) {
 			return rightObject
 		}
-		return evalPrefixExpression(node.Operator, rightObject)
+		return e.evalPrefixExpression(node.Operator, rightObject)
 
 	case *ast.InfixExpressionNode:
-		leftObject := Eval(node.LeftNode, env)
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, env)
+		}
+
+		leftObject := e.Eval(node.LeftNode, env)
 		if isError(leftObject) {
 			return leftObject
 		}
 
-		rightObject := Eval(node.RightNode, env)
+		rightObject := e.Eval(node.RightNode, env)
 		if isError(rightObject) {
 			return rightObject
 		}
 
-		return evalInfixExpression(node.Operator, leftObject, rightObject)
+		return e.evalInfixExpression(node.Operator, leftObject, rightObject)
+
+	case *ast.AssignExpressionNode:
+		return e.evalAssignExpression(node, env)
 
 	case *ast.IfExpressionNode:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+
+	case *ast.MatchExpressionNode:
+		return e.evalMatchExpression(node, env)
 
 	case *ast.IdentifierNode:
 		return evalIdentifier(node, env)
 
 	case *ast.FunctionLiteralNode:
 		return &object.FunctionObject{
-			ParamNodes: node.ParamNodes,
-			Env:        env,
-			BodyNode:   node.BodyNode,
+			ParamNodes:   node.ParamNodes,
+			DefaultNodes: node.DefaultNodes,
+			RestNode:     node.RestNode,
+			Env:          env,
+			BodyNode:     node.BodyNode,
+			Name:         node.Name,
 		}
 
 	case *ast.CallExpressionNode:
-		fnObject := Eval(node.FnNode, env)
+		fnObject := e.Eval(node.FnNode, env)
 		if isError(fnObject) {
 			return fnObject
 		}
 
-		argObjects := evalExpressions(node.ArgNodes, env)
+		argObjects, names := e.evalArguments(node.ArgNodes, env)
 		if len(argObjects) == 1 && isError(argObjects[0]) {
 			return argObjects[0]
 		}
 
-		return applyFunction(fnObject, argObjects)
+		return e.applyFunction(fnObject, argObjects, names)
 
 	case *ast.ArrayLiteralNode:
-		elementObjects := evalExpressions(node.Elements, env)
+		elementObjects := e.evalExpressions(node.Elements, env)
 
 		if len(elementObjects) == 1 && isError(elementObjects[0]) {
 			return elementObjects[0]
@@ -103,12 +175,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.ArrayObject{Elements: elementObjects}
 
 	case *ast.IndexExpressionNode:
-		leftObject := Eval(node.Left, env)
+		leftObject := e.Eval(node.Left, env)
 		if isError(leftObject) {
 			return leftObject
 		}
 
-		indexObject := Eval(node.Index, env)
+		indexObject := e.Eval(node.Index, env)
 		if isError(indexObject) {
 			return indexObject
 		}
@@ -116,7 +188,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIndexExpression(leftObject, indexObject)
 
 	case *ast.HashLiteralNode:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 	}
 
 	return nil
@@ -1,16 +1,23 @@
 package evaluator
 
 import (
+	"math"
 	"monkey/ast"
 	"monkey/object"
+	"strings"
 )
 
-func evalPrefixExpression(operator string, rightObject object.Object) object.Object {
+func (e *Evaluator) evalPrefixExpression(operator string, rightObject object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(rightObject)
 	case "-":
-		return evalMinusPrefixOperatorExpression(rightObject)
+		return e.evalMinusPrefixOperatorExpression(rightObject)
+	case "~":
+		if object.IsInteger(rightObject) {
+			return object.ComplementInteger(rightObject)
+		}
+		return newErrorObject("Unknown operator: ~%s", rightObject.Type())
 	default:
 		return newErrorObject("Unknown operator: %s%s", operator, rightObject.Type())
 	}
@@ -29,19 +36,27 @@ func evalBangOperatorExpression(rightObject object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(rightObject object.Object) object.Object {
-	if rightObject.Type() != object.INT_OBJ {
+func (e *Evaluator) evalMinusPrefixOperatorExpression(rightObject object.Object) object.Object {
+	switch rightObject := rightObject.(type) {
+	case *object.IntObject, *object.BigIntObject:
+		result, err := object.NegateInteger(rightObject, e.config.Overflow)
+		if err != nil {
+			return newErrorObject("%s", err)
+		}
+		return result
+	case *object.FloatObject:
+		return &object.FloatObject{Value: -rightObject.Value}
+	default:
 		return newErrorObject("Unknown operator: -%s", rightObject.Type())
 	}
-
-	value := rightObject.(*object.IntObject).Value
-	return &object.IntObject{Value: -value}
 }
 
-func evalInfixExpression(operator string, leftObject, rightObject object.Object) object.Object {
+func (e *Evaluator) evalInfixExpression(operator string, leftObject, rightObject object.Object) object.Object {
 	switch {
-	case leftObject.Type() == object.INT_OBJ && rightObject.Type() == object.INT_OBJ:
-		return evalIntegerInfixExpression(operator, leftObject, rightObject)
+	case object.IsInteger(leftObject) && object.IsInteger(rightObject):
+		return e.evalIntegerInfixExpression(operator, leftObject, rightObject)
+	case isNumber(leftObject) && isNumber(rightObject):
+		return evalFloatInfixExpression(operator, leftObject, rightObject)
 	case leftObject.Type() == object.STRING_OBJ && rightObject.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, leftObject, rightObject)
 	case operator == "==":
@@ -57,23 +72,64 @@ func evalInfixExpression(operator string, leftObject, rightObject object.Object)
 	}
 }
 
-func evalIntegerInfixExpression(operator string, leftObject, rightObject object.Object) object.Object {
-	leftValue := leftObject.(*object.IntObject).Value
-	rightValue := rightObject.(*object.IntObject).Value
+// evalIntegerInfixExpression evaluates operations on ints and big ints,
+// the arithmetic is shared with the VM
+func (e *Evaluator) evalIntegerInfixExpression(operator string, leftObject, rightObject object.Object) object.Object {
+	switch operator {
+	case "+", "-", "*", "/", "%", "&", "|", "^", "<<", ">>":
+		result, err := object.IntegerOperation(operator, leftObject, rightObject, e.config.Overflow)
+		if err != nil {
+			return newErrorObject("%s", err)
+		}
+		return result
+	}
+
+	cmp := object.CompareIntegers(leftObject, rightObject)
+
+	switch operator {
+	case "<":
+		return nativeBoolToObject(cmp < 0)
+	case ">":
+		return nativeBoolToObject(cmp > 0)
+	case "<=":
+		return nativeBoolToObject(cmp <= 0)
+	case ">=":
+		return nativeBoolToObject(cmp >= 0)
+	case "==":
+		return nativeBoolToObject(cmp == 0)
+	case "!=":
+		return nativeBoolToObject(cmp != 0)
+	default:
+		return newErrorObject("Unknown operator: %s %s %s",
+			leftObject.Type(), operator, rightObject.Type())
+	}
+}
+
+// evalFloatInfixExpression evaluates operations on two floats, or on a
+// float and an int, which is converted to a float
+func evalFloatInfixExpression(operator string, leftObject, rightObject object.Object) object.Object {
+	leftValue, _ := object.AsFloat(leftObject)
+	rightValue, _ := object.AsFloat(rightObject)
 
 	switch operator {
 	case "+":
-		return &object.IntObject{Value: leftValue + rightValue}
+		return &object.FloatObject{Value: leftValue + rightValue}
 	case "-":
-		return &object.IntObject{Value: leftValue - rightValue}
+		return &object.FloatObject{Value: leftValue - rightValue}
 	case "*":
-		return &object.IntObject{Value: leftValue * rightValue}
+		return &object.FloatObject{Value: leftValue * rightValue}
 	case "/":
-		return &object.IntObject{Value: leftValue / rightValue}
+		return &object.FloatObject{Value: leftValue / rightValue}
+	case "%":
+		return &object.FloatObject{Value: math.Mod(leftValue, rightValue)}
 	case "<":
 		return nativeBoolToObject(leftValue < rightValue)
 	case ">":
 		return nativeBoolToObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToObject(leftValue >= rightValue)
 	case "==":
 		return nativeBoolToObject(leftValue == rightValue)
 	case "!=":
@@ -88,34 +144,157 @@ func evalStringInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
-	if operator != "+" {
+	leftVal := left.(*object.StringObject).Value
+	rightVal := right.(*object.StringObject).Value
+
+	switch operator {
+	case "+":
+		return &object.StringObject{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToObject(leftVal != rightVal)
+	default:
 		return newErrorObject("Unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
+}
 
-	leftVal := left.(*object.StringObject).Value
-	rightVal := right.(*object.StringObject).Value
-	return &object.StringObject{Value: leftVal + rightVal}
+// evalLogicalExpression evaluates && and ||, the right operand is only
+// evaluated when the left one does not decide the result. The result is
+// the last operand evaluated, so `name || "default"` works as expected.
+func (e *Evaluator) evalLogicalExpression(
+	node *ast.InfixExpressionNode,
+	env *object.Environment,
+) object.Object {
+	leftObject := e.Eval(node.LeftNode, env)
+	if isError(leftObject) {
+		return leftObject
+	}
+
+	if isTruthy(leftObject) == (node.Operator == "||") {
+		return leftObject
+	}
+
+	return e.Eval(node.RightNode, env)
 }
 
-func evalIfExpression(
+func (e *Evaluator) evalIfExpression(
 	node *ast.IfExpressionNode,
 	env *object.Environment,
 ) object.Object {
-	conditionObject := Eval(node.ConditionNode, env)
+	conditionObject := e.Eval(node.ConditionNode, env)
 	if isError(conditionObject) {
 		return conditionObject
 	}
 
 	if isTruthy(conditionObject) {
-		return Eval(node.ConsequenceNode, env)
+		return e.Eval(node.ConsequenceNode, env)
 	} else if node.AlternativeNode != nil {
-		return Eval(node.AlternativeNode, env)
+		return e.Eval(node.AlternativeNode, env)
 	} else {
 		return NULL
 	}
 }
 
+// evalMatchExpression evaluates the first arm with a pattern that matches
+// the subject and a true guard. Every arm has an environment of its own for
+// the names its patterns bind, they are not visible outside of it.
+func (e *Evaluator) evalMatchExpression(node *ast.MatchExpressionNode, env *object.Environment) object.Object {
+	subjectObject := e.Eval(node.SubjectNode, env)
+	if isError(subjectObject) {
+		return subjectObject
+	}
+
+	for _, arm := range node.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+		if !e.matchAnyPattern(arm.Patterns, subjectObject, armEnv) {
+			continue
+		}
+
+		if arm.GuardNode != nil {
+			guardObject := e.Eval(arm.GuardNode, armEnv)
+			if isError(guardObject) {
+				return guardObject
+			}
+			if !isTruthy(guardObject) {
+				continue
+			}
+		}
+
+		return e.Eval(arm.BodyNode, armEnv)
+	}
+
+	return NULL
+}
+
+func (e *Evaluator) matchAnyPattern(patterns []ast.ExpressionNode, value object.Object, env *object.Environment) bool {
+	for _, pattern := range patterns {
+		if e.matchPattern(pattern, value, env) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPattern reports whether the value matches the pattern. Names are
+// bound from left to right as their part of the value is reached, so a
+// pattern that fails may leave some of them set in env.
+func (e *Evaluator) matchPattern(pattern ast.ExpressionNode, value object.Object, env *object.Environment) bool {
+	switch pattern := pattern.(type) {
+	case *ast.IdentifierNode:
+		if !ast.IsWildcard(pattern) {
+			env.Set(pattern.Value, value)
+		}
+		return true
+
+	case *ast.ArrayPatternNode:
+		array, ok := value.(*object.ArrayObject)
+		if !ok {
+			return false
+		}
+
+		n := len(pattern.Elements)
+		if len(array.Elements) < n || (pattern.Rest == nil && len(array.Elements) != n) {
+			return false
+		}
+
+		for i, element := range pattern.Elements {
+			if !e.matchPattern(element, array.Elements[i], env) {
+				return false
+			}
+		}
+
+		if pattern.Rest != nil {
+			e.matchPattern(pattern.Rest, object.ArrayRest(array, n), env)
+		}
+		return true
+
+	case *ast.HashPatternNode:
+		hash, ok := value.(*object.HashObject)
+		if !ok {
+			return false
+		}
+
+		for i, key := range pattern.Keys {
+			keyObject := e.Eval(key, env).(object.Hashable)
+			pair, ok := hash.Pairs[keyObject.HashKey()]
+			if !ok || !e.matchPattern(pattern.Values[i], pair.Value, env) {
+				return false
+			}
+		}
+		return true
+
+	case *ast.TypePatternNode:
+		return object.HasType(value, pattern.TypeName) && e.matchPattern(pattern.PatternNode, value, env)
+
+	default:
+		// literals are compared like ==
+		return e.evalInfixExpression("==", value, e.Eval(pattern, env)) == TRUE
+	}
+}
+
 func evalIdentifier(node *ast.IdentifierNode, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
@@ -128,11 +307,85 @@ func evalIdentifier(node *ast.IdentifierNode, env *object.Environment) object.Ob
 	return newErrorObject("Identifier not found: " + node.Value)
 }
 
-func evalExpressions(exprNodes []ast.ExpressionNode, env *object.Environment) []object.Object {
+// evalAssignExpression updates a variable, or an element of an array or a
+// hash. A compound assignment like x += 1 reads the current value before
+// the right hand side is evaluated.
+func (e *Evaluator) evalAssignExpression(node *ast.AssignExpressionNode, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.IdentifierNode:
+		valueObject := e.evalAssignedValue(node, func() object.Object {
+			return evalIdentifier(target, env)
+		}, env)
+		if isError(valueObject) {
+			return valueObject
+		}
+
+		if !env.Assign(target.Value, valueObject) {
+			if _, ok := builtins[target.Value]; ok {
+				return newErrorObject("Cannot assign to builtin %s", target.Value)
+			}
+			return newErrorObject("Assignment to undefined variable %s", target.Value)
+		}
+		return valueObject
+
+	case *ast.IndexExpressionNode:
+		leftObject := e.Eval(target.Left, env)
+		if isError(leftObject) {
+			return leftObject
+		}
+
+		indexObject := e.Eval(target.Index, env)
+		if isError(indexObject) {
+			return indexObject
+		}
+
+		valueObject := e.evalAssignedValue(node, func() object.Object {
+			return evalIndexExpression(leftObject, indexObject)
+		}, env)
+		if isError(valueObject) {
+			return valueObject
+		}
+
+		if err := object.SetIndex(leftObject, indexObject, valueObject); err != nil {
+			return newErrorObject("%s", err)
+		}
+		return valueObject
+
+	default:
+		return newErrorObject("Cannot assign to %s", node.Target.String())
+	}
+}
+
+// evalAssignedValue evaluates the value of an assignment, applying the
+// operator of a compound assignment to the current value of the target
+func (e *Evaluator) evalAssignedValue(
+	node *ast.AssignExpressionNode,
+	current func() object.Object,
+	env *object.Environment,
+) object.Object {
+	operator := node.BinaryOperator()
+
+	var currentObject object.Object
+	if operator != "" {
+		currentObject = current()
+		if isError(currentObject) {
+			return currentObject
+		}
+	}
+
+	valueObject := e.Eval(node.ValueNode, env)
+	if isError(valueObject) || operator == "" {
+		return valueObject
+	}
+
+	return e.evalInfixExpression(operator, currentObject, valueObject)
+}
+
+func (e *Evaluator) evalExpressions(exprNodes []ast.ExpressionNode, env *object.Environment) []object.Object {
 	var resultObjects []object.Object
 
 	for _, exprNode := range exprNodes {
-		resultObject := Eval(exprNode, env)
+		resultObject := e.Eval(exprNode, env)
 
 		if isError(resultObject) {
 			return []object.Object{resultObject}
@@ -144,15 +397,73 @@ func evalExpressions(exprNodes []ast.ExpressionNode, env *object.Environment) []
 	return resultObjects
 }
 
-func applyFunction(fnObject object.Object, argObjects []object.Object) object.Object {
+// evalArguments evaluates the arguments of a call, the elements of spread
+// arrays become separate arguments. The names of the arguments passed by
+// name are returned too, they are the last arguments.
+func (e *Evaluator) evalArguments(argNodes []ast.ExpressionNode, env *object.Environment) ([]object.Object, []string) {
+	var argObjects []object.Object
+	var names []string
+
+	for _, argNode := range argNodes {
+		switch argNode := argNode.(type) {
+		case *ast.SpreadNode:
+			valueObject := e.Eval(argNode.ValueNode, env)
+			if isError(valueObject) {
+				return []object.Object{valueObject}, nil
+			}
+			array, ok := valueObject.(*object.ArrayObject)
+			if !ok {
+				return []object.Object{newErrorObject("spread argument must be an array, got %s", valueObject.Type())}, nil
+			}
+			argObjects = append(argObjects, array.Elements...)
+
+		case *ast.NamedArgumentNode:
+			valueObject := e.Eval(argNode.ValueNode, env)
+			if isError(valueObject) {
+				return []object.Object{valueObject}, nil
+			}
+			argObjects = append(argObjects, valueObject)
+			names = append(names, argNode.Name.Value)
+
+		default:
+			argObject := e.Eval(argNode, env)
+			if isError(argObject) {
+				return []object.Object{argObject}, nil
+			}
+			argObjects = append(argObjects, argObject)
+		}
+	}
+
+	return argObjects, names
+}
+
+func (e *Evaluator) applyFunction(fnObject object.Object, argObjects []object.Object, names []string) object.Object {
 	switch fnObjectCasted := fnObject.(type) {
 	case *object.FunctionObject:
+		arity := fnObjectCasted.Arity()
+		if len(names) > 0 {
+			bound, err := object.BindArguments(fnObjectCasted.Name, arity, fnObjectCasted.ParamNames(), argObjects, names)
+			if err != nil {
+				return newErrorObject("%s", err)
+			}
+			argObjects = bound
+		} else if !arity.Accepts(len(argObjects)) {
+			return newErrorObject("%s", object.ArityError(fnObjectCasted.Name, arity, len(argObjects)))
+		}
 
-		extendedEnv := extendFnEnv(fnObjectCasted, argObjects)
-		resultObject := Eval(fnObjectCasted.BodyNode, extendedEnv)
+		extendedEnv, errorObject := e.extendFnEnv(fnObjectCasted, argObjects)
+		if errorObject != nil {
+			return errorObject
+		}
+		resultObject := e.Eval(fnObjectCasted.BodyNode, extendedEnv)
 		return unwrapReturnValue(resultObject)
 
 	case *object.BuiltinObject:
+		if len(names) > 0 {
+			// builtins have no parameter names, any name is unknown
+			_, err := object.BindArguments("", object.Arity{}, nil, argObjects, names)
+			return newErrorObject("%s", err)
+		}
 		if result := fnObjectCasted.Fn(argObjects...); result != nil {
 			return result
 		}
@@ -163,14 +474,36 @@ func applyFunction(fnObject object.Object, argObjects []object.Object) object.Ob
 	}
 }
 
-func extendFnEnv(fnObject *object.FunctionObject, argObjects []object.Object) *object.Environment {
+// extendFnEnv binds the parameters of the function to the arguments. The
+// default values of missing arguments, which are nil or past the end, are
+// evaluated in the new environment, so they can refer to the parameters
+// before them.
+func (e *Evaluator) extendFnEnv(fnObject *object.FunctionObject, argObjects []object.Object) (*object.Environment, object.Object) {
 	env := object.NewEnclosedEnvironment(fnObject.Env)
 
+	required := len(fnObject.ParamNodes) - len(fnObject.DefaultNodes)
 	for id, paramNode := range fnObject.ParamNodes {
-		env.Set(paramNode.Value, argObjects[id])
+		if id < len(argObjects) && argObjects[id] != nil {
+			env.Set(paramNode.Value, argObjects[id])
+			continue
+		}
+
+		defaultObject := e.Eval(fnObject.DefaultNodes[id-required], env)
+		if isError(defaultObject) {
+			return nil, defaultObject
+		}
+		env.Set(paramNode.Value, defaultObject)
 	}
 
-	return env
+	if fnObject.RestNode != nil {
+		rest := []object.Object{}
+		if len(argObjects) > len(fnObject.ParamNodes) {
+			rest = append(rest, argObjects[len(fnObject.ParamNodes):]...)
+		}
+		env.Set(fnObject.RestNode.Value, &object.ArrayObject{Elements: rest})
+	}
+
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -185,6 +518,8 @@ func evalIndexExpression(leftObject, indexObject object.Object) object.Object {
 	switch {
 	case leftObject.Type() == object.ARRAY_OBJ && indexObject.Type() == object.INT_OBJ:
 		return evalArrayIndexExpression(leftObject, indexObject)
+	case leftObject.Type() == object.STRING_OBJ && indexObject.Type() == object.INT_OBJ:
+		return evalStringIndexExpression(leftObject, indexObject)
 	case leftObject.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(leftObject, indexObject)
 	default:
@@ -204,30 +539,63 @@ func evalArrayIndexExpression(arrayObject, indexObject object.Object) object.Obj
 	return arrayObjectCasted.Elements[idx]
 }
 
-func evalHashLiteral(
-	node *ast.HashLiteralNode,
+func evalStringIndexExpression(stringObject, indexObject object.Object) object.Object {
+	idx := indexObject.(*object.IntObject).Value
+
+	char, ok := stringObject.(*object.StringObject).RuneAt(idx)
+	if !ok {
+		return NULL
+	}
+
+	return &object.StringObject{Value: char}
+}
+
+func (e *Evaluator) evalInterpolatedString(
+	node *ast.InterpolatedStringNode,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	var out strings.Builder
 
-	for keyNode, valueNode := range node.Pairs {
-		keyObject := Eval(keyNode, env)
-		if isError(keyObject) {
-			return keyObject
+	for _, part := range node.Parts {
+		partObject := e.Eval(part, env)
+		if isError(partObject) {
+			return partObject
 		}
 
-		hashKey, ok := keyObject.(object.Hashable)
-		if !ok {
-			return newErrorObject("Unusable as hash key: %s", keyObject.Type())
+		out.WriteString(partObject.Inspect())
+	}
+
+	return &object.StringObject{Value: out.String()}
+}
+
+func (e *Evaluator) evalHashLiteral(
+	node *ast.HashLiteralNode,
+	env *object.Environment,
+) object.Object {
+	// all pairs are evaluated before the keys are checked, like in the vm
+	objects := []object.Object{}
+	for _, keyNode := range node.Keys() {
+		keyObject := e.Eval(keyNode, env)
+		if isError(keyObject) {
+			return keyObject
 		}
 
-		valueObject := Eval(valueNode, env)
+		valueObject := e.Eval(node.Pairs[keyNode], env)
 		if isError(valueObject) {
 			return valueObject
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: keyObject, Value: valueObject}
+		objects = append(objects, keyObject, valueObject)
+	}
+
+	pairs := make(map[object.HashKey]object.HashPair)
+	for i := 0; i < len(objects); i += 2 {
+		hashKey, ok := objects[i].(object.Hashable)
+		if !ok {
+			return newErrorObject("Unusable as hash key: %s", objects[i].Type())
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: objects[i], Value: objects[i+1]}
 	}
 
 	return &object.HashObject{Pairs: pairs}
@@ -5,11 +5,11 @@ import (
 	"monkey/object"
 )
 
-func evalProgram(node *ast.ProgramNode, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(node *ast.ProgramNode, env *object.Environment) object.Object {
 	var resultObject object.Object
 
 	for _, statementNode := range node.StatementNodes {
-		resultObject = Eval(statementNode, env)
+		resultObject = e.Eval(statementNode, env)
 
 		switch resultObject := resultObject.(type) {
 		case *object.ReturnValueObject:
@@ -22,16 +22,89 @@ func evalProgram(node *ast.ProgramNode, env *object.Environment) object.Object {
 	return resultObject
 }
 
-func evalBlockStatement(node *ast.BlockStatementNode, env *object.Environment) object.Object {
+// evalBlockStatement evaluates to its last statement, or to null when that
+// statement has no value, like a let
+func (e *Evaluator) evalBlockStatement(node *ast.BlockStatementNode, env *object.Environment) object.Object {
 	var resultObject object.Object
 
 	for _, statementNode := range node.StatementNodes {
-		resultObject = Eval(statementNode, env)
+		resultObject = e.Eval(statementNode, env)
 
-		if resultObject != nil && (resultObject.Type() == object.RETURN_VALUE_OBJ || resultObject.Type() == object.ERROR_OBJ) {
+		if resultObject == nil {
+			continue
+		}
+
+		switch resultObject.Type() {
+		case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 			return resultObject
 		}
 	}
 
+	if resultObject == nil {
+		return NULL
+	}
+
 	return resultObject
 }
+
+// evalWhileStatement runs a while loop. Loops are statements, so like a let
+// they evaluate to nil.
+func (e *Evaluator) evalWhileStatement(node *ast.WhileStatementNode, env *object.Environment) object.Object {
+	for {
+		conditionObject := e.Eval(node.ConditionNode, env)
+		if isError(conditionObject) {
+			return conditionObject
+		}
+		if !isTruthy(conditionObject) {
+			return nil
+		}
+
+		if resultObject, done := e.evalLoopBody(node.BodyNode, env); done {
+			return resultObject
+		}
+	}
+}
+
+func (e *Evaluator) evalForStatement(node *ast.ForStatementNode, env *object.Environment) object.Object {
+	if node.InitNode != nil {
+		if initObject := e.Eval(node.InitNode, env); isError(initObject) {
+			return initObject
+		}
+	}
+
+	for {
+		if node.ConditionNode != nil {
+			conditionObject := e.Eval(node.ConditionNode, env)
+			if isError(conditionObject) {
+				return conditionObject
+			}
+			if !isTruthy(conditionObject) {
+				return nil
+			}
+		}
+
+		if resultObject, done := e.evalLoopBody(node.BodyNode, env); done {
+			return resultObject
+		}
+
+		if node.UpdateNode != nil {
+			if updateObject := e.Eval(node.UpdateNode, env); isError(updateObject) {
+				return updateObject
+			}
+		}
+	}
+}
+
+// evalLoopBody runs one iteration of a loop. It reports whether the loop
+// is done, because of a break, a return or an error, and what the loop
+// statement then evaluates to.
+func (e *Evaluator) evalLoopBody(body *ast.BlockStatementNode, env *object.Environment) (object.Object, bool) {
+	switch resultObject := e.Eval(body, env).(type) {
+	case *object.BreakObject:
+		return nil, true
+	case *object.ReturnValueObject, *object.ErrorObject:
+		return resultObject, true
+	default:
+		return nil, false
+	}
+}
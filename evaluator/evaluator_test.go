@@ -27,6 +27,11 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"1 + 7 % 3 * 2", 3},
+		{"0xFF + 0b1", 256},
+		{"1_000 * 0o10", 8000},
 	}
 
 	for _, tt := range tests {
@@ -35,6 +40,69 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"~0", -1},
+		{"~5", -6},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"-16 >> 2", -4},
+		{"1 << 63 >> 63", -1},
+		{"1 << 64", 0},
+		{"1 | 2 << 2 & 12", 9},
+		{"1 << -1", "negative shift count: -1"},
+		{"8 >> -2", "negative shift count: -2"},
+		{"~true", "Unknown operator: ~BOOL"},
+		{"1.5 & 1", "Unknown operator: FLOAT & INT"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.ErrorObject)
+			if !ok {
+				t.Errorf("%s - no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 / 4.0", 2.5},
+		{"2.5 * 2", 5},
+		{"1e3 - 1", 999},
+		{"7.5 % 2", 1.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -59,6 +127,29 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"0.5 != 0.5", false},
+		{`"a" == "a"`, true},
+		{`"a" + "b" == "ab"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" != "a"`, false},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 <= 1.5", true},
+		{"2 >= 2.5", false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
 	}
 
 	for _, tt := range tests {
@@ -67,6 +158,33 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// the result is the operand that decided it
+		{"1 && 2", 2},
+		{"false && 2", false},
+		{"0 || 2", 0},
+		// the right operand is not evaluated when the left decides
+		{"false && len(1)", false},
+		{"true || len(1)", true},
+		{"let f = fn(n) { n > 0 && f(n - 1) || n == 0 }; f(10)", true},
+		{"let a = [1]; len(a) > 1 && a[1] > 5", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -98,6 +216,97 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"match (2) { 1 => 10, 2 => 20, _ => 30 }", 20},
+		{"match (5) { 1 => 10, 2 => 20, _ => 30 }", 30},
+		{"match (5) { 1 => 10, 2 => 20 }", nil},
+		{"match (3) { 1, 2 => 10, 3, 4 => 20 }", 20},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{"match (1 < 2) { false => 1, true => 2 }", 2},
+		{"match (-1) { 1 => 1, -1 => 2 }", 2},
+		{"match (2.0) { 1 => 1, 2 => 2 }", 2},
+		{"match (2) { 1.5 => 1, 2.0 => 2 }", 2},
+		{"match (2n) { 1 => 1, 2 => 2 }", 2},
+		{"match ([1]) { 1 => 1, _ => 2 }", 2},
+		{"match (1) { 1, _ => 1, 1 => 2 }", 1},
+		{"match (1) { 1 => { let x = 5; x * 2 } _ => 0 }", 10},
+		{"match (1) { 1 => { let x = 5 } _ => 0 }", nil},
+		{"match (1) { 1 => {}, _ => 0 }", nil},
+		{"let f = fn(n) { match (n) { 0 => 10, 1 => 11, 2, 3 => 12, 5 => 15, _ => 0 } }; f(0) + f(3) * 100", 1210},
+		{"let f = fn(n) { match (n) { 0 => 10, 1 => 11, 2, 3 => 12, 5 => 15, _ => 0 } }; f(4) + f(5) * 100", 1500},
+		{"let f = fn(n) { match (n) { -2 => 1, -1 => 2, 0 => 3, 1 => 4 } }; f(-2) * 10 + f(1)", 14},
+		{"let f = fn(n) { match (n) { 0 => 1, 1 => 2, 2 => 3, 3 => 4 } }; f(2.0) * 10 + f(3n)", 34},
+		{"let f = fn(n) { match (n) { 0 => 1, 1 => 2, 2 => 3, 3 => 4 } }; f(9)", nil},
+		{"let f = fn(n) { match (n) { 0 => 1, 1 => 2, 2 => 3, 3 => 4 } }; f(1.5)", nil},
+		{"let f = fn(n) { match (n) { 0 => 1, 1 => 2, 2 => 3, 3 => 4 } }; f(\"1\")", nil},
+		{"let f = fn(n) { match (n) { 0 => 1, 0 => 2, 1 => 3, 2 => 4, 3 => 5 } }; f(0)", 1},
+		{"let s = 0; for (let i = 0; i < 10; i += 1) { match (i % 3) { 0 => { continue } 1 => { s += i } _ => { if (i > 7) { break } } } }; s", 12},
+		{"let f = fn(n) { match (n) { 0 => { return 7 } _ => 1 }; 2 }; f(0) * 10 + f(1)", 72},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"match ([1, 2]) { [a, b] => a + b }", 3},
+		{"match ([1, 2, 3]) { [a, b] => 0, [a, ...rest] => len(rest) }", 2},
+		{"match ([]) { [a, ..._] => 1, [] => 2 }", 2},
+		{"match ([1, [2, 3]]) { [1, [a, b]] => a * b, _ => 0 }", 6},
+		{"match ([1, [2]]) { [1, [a, b]] => a * b, _ => 0 }", 0},
+		{`match ({"name": "x", "age": 3}) { {"age": a} => a }`, 3},
+		{`match ({"age": "3"}) { {"age": int(a)} => a, {"age": string(s)} => len(s) }`, 1},
+		{`match ({}) { {"age": a} => a, _ => -1 }`, -1},
+		{`match ({}["k"]) { null(_) => 1 }`, 1},
+		{"match (5n) { int(n) => 1, _ => 2 }", 1},
+		{"match (1.5) { int(n) => 1, float(f) => 2 }", 2},
+		{"match (true) { bool(b) => 1 }", 1},
+		{"match (len) { function(f) => f([1, 2]) }", 2},
+		{"match (fn(x) { x }) { function(f) => f(7) }", 7},
+		{"match ([3, 1]) { [a, b] if a < b => 1, [a, b] if a > b => 2, _ => 3 }", 2},
+		{"match ([1]) { [x] if x > 5 => 1 }", nil},
+		{"let a = 10; let b = 20; match ([1, 2]) { [a, b] if a > 1 => 0, _ => a + b }", 30},
+		{"let x = 10; match ([1, \"no\"]) { [x, 2] => 0, _ => x }", 10},
+		{"let x = 10; match ([1, \"no\"]) { [x, 2] => 0, _ => 0 }; x", 10},
+		{"let f = fn() { let x = 10; match ([1, 2]) { [x, 3] => 0, [_, x] if x > 5 => 0, _ => 0 }; x }; f()", 10},
+		{"match ([1]) { [len] => len, _ => 0 }; len([1, 2])", 2},
+		{"match ([1, 2]) { [x, 1], [1, x] => x, _ => 0 }", 2},
+		{"let f = fn(v) { match (v) { [h, ...t] => h + f(t), [] => 0 } }; f([1, 2, 3, 4])", 10},
+		{"let f = fn(v) { match (v) { [a] => fn() { a } } }; f([9])()", 9},
+		{"let [a, b, ...c] = [1, 2, 3, 4]; a + b + len(c)", 5},
+		{`let {"x": x, "y": [y]} = {"x": 1, "y": [2]}; x * 10 + y`, 12},
+		{"let f = fn(p) { let [a, b] = p; a - b }; f([5, 3])", 2},
 	}
 
 	for _, tt := range tests {
@@ -173,6 +382,10 @@ func TestErrorHandling(t *testing.T) {
 			"5 + true; 5;",
 			"type mismatch: INT + BOOL",
 		},
+		{
+			"let [a, b] = [1];",
+			"pattern does not match [1]",
+		},
 		{
 			"-true",
 			"Unknown operator: -BOOL",
@@ -221,6 +434,94 @@ func TestErrorHandling(t *testing.T) {
 			`999[1]`,
 			"Index operator not supported: INT",
 		},
+		{
+			"fn(a, b) { a + b }(1);",
+			"wrong number of arguments: want=2, got=1",
+		},
+		{
+			"fn() { 1 }(1, 2);",
+			"wrong number of arguments: want=0, got=2",
+		},
+		{
+			"let f = fn(a, b = 1) { a }; f()",
+			"wrong number of arguments to `f`: want=1 to 2, got=0",
+		},
+		{
+			"let f = fn(a, b = 1) { a }; f(1, 2, 3)",
+			"wrong number of arguments to `f`: want=1 to 2, got=3",
+		},
+		{
+			"let f = fn(a, ...r) { a }; f()",
+			"wrong number of arguments to `f`: want=at least 1, got=0",
+		},
+		{
+			"let f = fn(a) { a }; f(...[1, 2])",
+			"wrong number of arguments to `f`: want=1, got=2",
+		},
+		{
+			"let f = fn(a) { a }; f(...1)",
+			"spread argument must be an array, got INT",
+		},
+		{
+			"let f = fn(a) { a }; f(b: 1)",
+			"unknown parameter `b` of `f`",
+		},
+		{
+			"let f = fn(a, b = 1) { a }; f(1, a: 2)",
+			"duplicate argument for parameter `a` of `f`",
+		},
+		{
+			"let f = fn(a, b = 1) { a }; f(b: 2)",
+			"missing argument for parameter `a` of `f`",
+		},
+		{
+			"fn(a) { a }(1, 2, a: 3)",
+			"duplicate argument for parameter `a`",
+		},
+		{
+			"len(x: 1)",
+			"unknown parameter `x`",
+		},
+		{
+			"let f = fn(a, ...r) { a }; f(r: [])",
+			"unknown parameter `r` of `f`",
+		},
+		{
+			"let f = fn(a = x) { a }; f()",
+			"Identifier not found: x",
+		},
+		{
+			"let f = fn() { for (let i = 0; i < 0; i += 1) { let k = i; } k }; f()",
+			"Identifier not found: k",
+		},
+		{
+			"while (false) { let z = 1; }; z",
+			"Identifier not found: z",
+		},
+		{
+			"let f = fn(a = b, b = 1) { a }; f()",
+			"Identifier not found: b",
+		},
+		{
+			"let f = fn(a = r, ...r) { a }; f()",
+			"Identifier not found: r",
+		},
+		{
+			"x = 1",
+			"Assignment to undefined variable x",
+		},
+		{
+			"len = 1",
+			"Cannot assign to builtin len",
+		},
+		{
+			"let a = [1]; a[1] = 2",
+			"index out of range: 1",
+		},
+		{
+			"let s = \"ab\"; s[0] = \"c\"",
+			"index assignment not supported: STRING",
+		},
 	}
 
 	for _, tt := range tests {
@@ -240,6 +541,44 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1 }; i", 5},
+		{"let i = 0; while (false) { let i = i + 1 }; i", 0},
+		{"let s = 0; for (let i = 1; i <= 100; let i = i + 1) { let s = s + i }; s", 5050},
+		{"let s = 0; for (let i = 0; i < 10; let i = i + 1) { if (i % 2 == 0) { continue } let s = s + i }; s", 25},
+		{"let i = 0; for (;;) { let i = i + 1; if (i == 7) { break } }; i", 7},
+		{"let n = 0; for (let i = 0; i < 4; let i = i + 1) { for (let j = 0; j < 4; let j = j + 1) { if (j > i) { break } let n = n + 1 } }; n", 10},
+		{"let f = fn(n) { let i = 0; while (true) { if (i * i >= n) { return i } let i = i + 1 } }; f(50)", 8},
+		{"let f = fn() { let i = 0; while (i < 3) { let i = i + 1 } }; f()", nil},
+		{"let f = fn(xs) { let s = 0; let i = 0; while (i < len(xs)) { let s = s + xs[i]; let i = i + 1 }; s }; f([1, 2, 3])", 6},
+		{"let i = 0; while (i < 100000) { let i = i + 1 }; i", 100000},
+		{"if (true) { let x = 1 }", nil},
+		{"let x = 1; let x = x + 1; x", 2},
+		{"let i = 0; while (true) { i += 1; if (i < 3) { continue } else if (i == 5) { break } }; i", 5},
+		{"let s = 0; for (let i = 0; i < 6; i += 1) { match (i % 3) { 0 => { continue }, 2 => if (i > 3) { break }, _ => 0 }; s += i }; s", 7},
+		{"let i = 0; let n = 1 + fn() { while (true) { i += 1; if (i > 3) { break } }; i }(); n", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+
+	errorInput := "let i = 0; while (i < 3) { let i = i + 1; if (i == 2) { i + true } }; i"
+	if errObj, ok := testEval(errorInput).(*object.ErrorObject); !ok || errObj.Message != "type mismatch: INT + BOOL" {
+		t.Errorf("expected a type mismatch error, got=%s", testEval(errorInput).Inspect())
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -256,6 +595,30 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; x = 5; x", 5},
+		{"let x = 1; x += 2; x", 3},
+		{"let x = 7; x -= 2; x *= 3; x /= 5; x %= 2; x", 1},
+		{"let x = 6; x &= 3; x |= 8; x ^= 1; x <<= 2; x >>= 1; x", 22},
+		{"let x = 1; let y = 2; x = y = 3; x + y", 6},
+		{"let x = 1; (x += 4) * 2", 10},
+		{"let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n", 2},
+		{"let s = 0; for (let i = 0; i < 5; i += 1) { s += i }; s", 10},
+		{"let a = [1, 2, 3]; a[1] = 20; a[1] + a[2]", 23},
+		{"let a = [1, 2, 3]; let b = a; b[0] *= 10; a[0]", 10},
+		{"let h = {}; h[\"k\"] = 1; h[\"k\"] += 2; h[\"k\"]", 3},
+		{"let h = {1: 1}; h[1n] = 5; h[1]", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
@@ -299,6 +662,44 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn(a, b = 2) { a + b }; f(1)", 3},
+		{"let f = fn(a, b = 2) { a + b }; f(1, 5)", 6},
+		{"let f = fn(a, b = a * 2, c = a + b) { c }; f(1)", 3},
+		{"let f = fn(a, b = a * 2, c = a + b) { c }; f(1, 5)", 6},
+		{"let n = 10; let f = fn(a = n) { a }; f()", 10},
+		{"let make = fn(x) { fn(y = x) { y } }; make(4)()", 4},
+		{"let f = fn(a, b = fn() { a }) { b() }; f(8)", 8},
+		{"let f = fn(a = 1) { a = a + 1; a }; f() + f(5)", 8},
+		{"let b = 10; let f = fn(a = b, b = 1) { a + b }; f()", 11},
+		{"let b = 10; let f = fn(a = b, b = 1) { a + b }; f(b: 5)", 15},
+		{"let r = [1, 2]; let f = fn(a = r, ...r) { len(a) * 10 + len(r) }; f()", 20},
+		{"let count = fn(...rest) { len(rest) }; count()", 0},
+		{"let count = fn(...rest) { len(rest) }; count(1, 2, 3)", 3},
+		{"let f = fn(a, b = 1, ...rest) { a + b + len(rest) }; f(1)", 2},
+		{"let f = fn(a, b = 1, ...rest) { a + b + len(rest) }; f(1, 2, 3, 4)", 5},
+		{"let add = fn(a, b) { a + b }; add(...[1, 2])", 3},
+		{"let add = fn(a, b, c) { a * 100 + b * 10 + c }; add(1, ...[2], 3)", 123},
+		{"let add = fn(a, b, c) { a * 100 + b * 10 + c }; add(...[], 1, ...[2, 3])", 123},
+		{"let f = fn(...r) { r }; f(...[1, 2], 3)[2]", 3},
+		{"len(...[[1, 2]])", 2},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(1, c: 5)", 125},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(c: 4, a: 7)", 724},
+		{"let f = fn(a, b = a + 1) { a * 10 + b }; f(b: 9, a: 1)", 19},
+		{"let f = fn(a, ...rest) { a + len(rest) }; f(...[], a: 5)", 5},
+		{"let f = fn(a, b, ...rest) { a * 10 + b + len(rest) }; f(...[1], b: 2)", 12},
+		{"let calls = []; let f = fn(a, b) { a - b }; f(b: fn() { calls = push(calls, 1); 1 }(), a: fn() { calls = push(calls, 2); 5 }()) * 10 + calls[0]", 41},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestEnclosingEnvironments(t *testing.T) {
 	input := `
 		let first = 10;
@@ -328,6 +729,76 @@ func TestClosures(t *testing.T) {
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestMutableClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+		let newCounter = fn() {
+			let n = 0;
+			fn() { n += 1; n };
+		};
+		let first = newCounter();
+		let second = newCounter();
+		first(); first();
+		first() * 10 + second();
+		`, 31},
+		{`
+		let newCell = fn() {
+			let v = 0;
+			[fn() { v }, fn(x) { v = x }];
+		};
+		let cell = newCell();
+		cell[1](42);
+		cell[0]();
+		`, 42},
+		{`
+		let outer = fn() {
+			let x = 1;
+			let add = fn(n) { x += n };
+			add(10);
+			add(100);
+			x;
+		};
+		outer();
+		`, 111},
+		{`
+		let newTripler = fn() {
+			let a = 1;
+			fn() { fn() { a *= 3; a } };
+		};
+		let tripler = newTripler()();
+		tripler();
+		tripler();
+		`, 9},
+		{`
+		let fib = fn() {
+			let calls = 0;
+			let f = fn(n) { calls += 1; if (n < 2) { return n } f(n - 1) + f(n - 2) };
+			f(10) * 1000 + calls;
+		};
+		fib();
+		`, 55177},
+		{`
+		let f = fn() { f = 5; 1 };
+		f() + f;
+		`, 6},
+		{`
+		let last = fn() {
+			let fns = [];
+			for (let i = 0; i < 3; i += 1) { fns = push(fns, fn() { i }) }
+			fns[0]();
+		};
+		last();
+		`, 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
@@ -342,6 +813,89 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringEscapes(t *testing.T) {
+	input := `"tab\there\n\"quoted\" \u{e9}" + ` + "`raw\\n`"
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.StringObject)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "tab\there\n\"quoted\" éraw\\n" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ann"; "hello ${name}!"`, "hello Ann!"},
+		{`"${1 + 2} ${1.5} ${true} ${[1, "a"]} ${{"k": 2}}"`, "3 1.5 true [1, a] {k : 2}"},
+		{`let items = [1, 2]; "you have ${len(items)} items"`, "you have 2 items"},
+		{`"${"nested ${"deep"}"}"`, "nested deep"},
+		{`"costs \${x} and $5"`, "costs ${x} and $5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.StringObject)
+		if !ok {
+			t.Errorf("%s - object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+
+	evaluated := testEval(`"a ${-true} b"`)
+	errObj, ok := evaluated.(*object.ErrorObject)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "Unknown operator: -BOOL" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"abc"[2]`, "c"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[2]`, "l"},
+		{`"世界"[1]`, "界"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+		{`""[0]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		expected, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(*object.StringObject)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", expected, str.Value)
+		}
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -364,8 +918,9 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo, 世界")`, 9},
 		{`len(1)`, "argument to `len` not supported, got INT"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len("one", "two")`, "wrong number of arguments to `len`: want=1, got=2"},
 		{`len([1, 2, 3])`, 3},
 		{`len([])`, 0},
 		{`puts("hello", "world!")`, nil},
@@ -562,6 +1117,14 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{5: 5}[5]`,
 			5,
 		},
+		{
+			`{5: 5}[5.0]`,
+			5,
+		},
+		{
+			`{2.5: 5}[2.5]`,
+			5,
+		},
 		{
 			`{true: 5}[true]`,
 			5,
@@ -582,6 +1145,76 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		policy   object.OverflowPolicy
+		expected string // Inspect form of the result
+	}{
+		{"1 / 0", object.OverflowWrap, "Error: division by zero"},
+		{"1 % 0", object.OverflowPromote, "Error: division by zero"},
+		{"9223372036854775807 + 1", object.OverflowWrap, "-9223372036854775808"},
+		{"9223372036854775807 + 1", object.OverflowError, "Error: integer overflow: 9223372036854775807 + 1"},
+		{"9223372036854775807 + 1", object.OverflowPromote, "9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", object.OverflowError, "Error: integer overflow: -(-9223372036854775808)"},
+		{"-(-9223372036854775807 - 1)", object.OverflowPromote, "9223372036854775808"},
+		{"9223372036854775807 * 4 / 4", object.OverflowPromote, "9223372036854775807"},
+		{"9223372036854775807 + 1 > 9223372036854775807", object.OverflowPromote, "true"},
+		{"9223372036854775807 + 1 == 9223372036854775807 + 1", object.OverflowPromote, "true"},
+		{"(9223372036854775807 + 1) * 0.5", object.OverflowPromote, "4.611686018427388e+18"},
+		{"let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(25)", object.OverflowPromote, "15511210043330985984000000"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := New(Config{Overflow: tt.policy}).Eval(program, object.NewEnvironment())
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s (%s) - expected=%s, got=%s", tt.input, tt.policy, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Inspect form of the result
+	}{
+		{"123456789012345678901234567890n", "123456789012345678901234567890"},
+		{"-123456789012345678901234567890n", "-123456789012345678901234567890"},
+		{"9223372036854775807n + 1", "9223372036854775808"},
+		{"1 + 9223372036854775807n", "9223372036854775808"},
+		{"2n * 3", "6"},
+		{"100000000000000000000n / 3", "33333333333333333333"},
+		{"-7n % 2", "-1"},
+		{"1n << 100", "1267650600228229401496703205376"},
+		{"(1n << 100) >> 99", "2"},
+		{"~0n", "-1"},
+		{"0xFFn & 0x0F", "15"},
+		{"1n / 0", "Error: division by zero"},
+		{"5n == 5", "true"},
+		{"5 != 5n", "false"},
+		{"100000000000000000000n > 9223372036854775807", "true"},
+		{"-100000000000000000000n < -9223372036854775807", "true"},
+		{"3n <= 3", "true"},
+		{"1n + 0.5", "1.5"},
+		{"2n > 1.5", "true"},
+		{"{5: \"five\"}[5n]", "five"},
+		{"{100000000000000000000n: 1}[100000000000000000000n]", "1"},
+		{"let fact = fn(n) { if (n == 0) { 1n } else { n * fact(n - 1) } }; fact(30)", "265252859812191058636308480000000"},
+		{"\"${2n * 21}\"", "42"},
+		{"5n + true", "Error: type mismatch: BIGINT + BOOL"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -606,6 +1239,21 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.FloatObject)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.BoolObject)
 	if !ok {
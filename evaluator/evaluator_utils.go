@@ -30,6 +30,11 @@ func newErrorObject(format string, a ...interface{}) *object.ErrorObject {
 	return &object.ErrorObject{Message: fmt.Sprintf(format, a...)}
 }
 
+func isNumber(obj object.Object) bool {
+	_, ok := object.AsFloat(obj)
+	return ok
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -10,6 +10,47 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
+
+func isDigitInBase(ch byte, base byte) bool {
+	switch base {
+	case 'b':
+		return ch == '0' || ch == '1'
+	case 'o':
+		return '0' <= ch && ch <= '7'
+	default:
+		return isHexDigit(ch)
+	}
+}
+
+// lower returns the lower case of an ASCII letter
+func lower(ch byte) byte {
+	return ch | 0x20
+}
+
+// validUnderscores reports whether every '_' in a number literal is
+// between two digits, as in 1_000
+func validUnderscores(literal string, isDigit func(byte) bool) bool {
+	for i := 0; i < len(literal); i++ {
+		if literal[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(literal)-1 || !isDigit(literal[i-1]) || !isDigit(literal[i+1]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isContinuationByte reports whether ch is a non-leading byte of a
+// multi-byte UTF-8 sequence, so columns are counted in characters
+func isContinuationByte(ch byte) bool {
+	return ch&0xC0 == 0x80
+}
+
 func newToken[T byte | string](tokenType token.TokenType, ch T) token.Token {
 	var literal string
 
@@ -1,16 +1,51 @@
 package lexer
 
-import "monkey/token"
+import (
+	"fmt"
+	"monkey/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Messages of the ERROR tokens for input that ends inside a literal or
+// comment, more input can complete them
+const (
+	UnterminatedComment   = "unterminated block comment"
+	UnterminatedString    = "unterminated string"
+	UnterminatedRawString = "unterminated raw string"
+)
+
+const invalidUnderscore = "'_' must separate successive digits"
+
+// baseNames maps the letter of an integer base prefix to the name of the
+// base, used in error messages
+var baseNames = map[byte]string{
+	'x': "hexadecimal",
+	'o': "octal",
+	'b': "binary",
+}
 
 type Lexer struct {
+	filename     string
 	input        string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
+
+	// templates holds, for each string interpolation the lexer is in, the
+	// number of braces opened inside of it, so the '}' ending it is known
+	templates []int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename("", input)
+}
+
+func NewWithFilename(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, line: 1}
 
 	l.readChar()
 
@@ -18,9 +53,74 @@ func New(input string) *Lexer {
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tk token.Token
+	trivia, errTk := l.readTrivia()
+	if errTk != nil {
+		errTk.LeadingTrivia = trivia
+		return *errTk
+	}
+
+	start := l.currentPosition()
+	tk := l.scanToken()
+	tk.Start = start
+	tk.End = l.currentPosition()
+	tk.LeadingTrivia = trivia
+
+	return tk
+}
+
+// readTrivia skips whitespace and comments, and returns the comments. An
+// unterminated block comment is returned as an ERROR token.
+func (l *Lexer) readTrivia() ([]token.Trivia, *token.Token) {
+	var trivia []token.Trivia
+
+	for {
+		l.skipWhitespace()
+
+		if l.ch != '/' || (l.peekChar() != '/' && l.peekChar() != '*') {
+			return trivia, nil
+		}
+
+		start := l.currentPosition()
+		kind := token.LINE_COMMENT
 
-	l.skipWhitespace()
+		if l.peekChar() == '/' {
+			l.skipLineComment()
+		} else {
+			kind = token.BLOCK_COMMENT
+			if !l.skipBlockComment() {
+				tk := newToken(token.ERROR, UnterminatedComment)
+				tk.Start = start
+				tk.End = l.currentPosition()
+				return trivia, &tk
+			}
+		}
+
+		trivia = append(trivia, token.Trivia{
+			Kind:  kind,
+			Text:  l.input[start.Offset:l.position],
+			Start: start,
+			End:   l.currentPosition(),
+		})
+	}
+}
+
+// compoundAssignTypes maps the operators that have a compound assignment,
+// like +=, to its token type
+var compoundAssignTypes = map[token.TokenType]token.TokenType{
+	token.PLUS:        token.PLUS_ASSIGN,
+	token.MINUS:       token.MINUS_ASSIGN,
+	token.ASTERISK:    token.ASTERISK_ASSIGN,
+	token.SLASH:       token.SLASH_ASSIGN,
+	token.PERCENT:     token.PERCENT_ASSIGN,
+	token.AMPERSAND:   token.AMPERSAND_ASSIGN,
+	token.PIPE:        token.PIPE_ASSIGN,
+	token.CARET:       token.CARET_ASSIGN,
+	token.SHIFT_LEFT:  token.SHIFT_LEFT_ASSIGN,
+	token.SHIFT_RIGHT: token.SHIFT_RIGHT_ASSIGN,
+}
+
+func (l *Lexer) scanToken() token.Token {
+	var tk token.Token
 
 	switch l.ch {
 	case '=':
@@ -30,6 +130,9 @@ func (l *Lexer) NextToken() token.Token {
 		if l.peekChar() == '=' {
 			tokenType = token.EQ
 			literal = literal + string(l.readChar())
+		} else if l.peekChar() == '>' {
+			tokenType = token.ARROW
+			literal = literal + string(l.readChar())
 		}
 
 		tk = newToken(token.TokenType(tokenType), literal)
@@ -51,19 +154,57 @@ func (l *Lexer) NextToken() token.Token {
 		tk = newToken(token.SLASH, l.ch)
 	case '*':
 		tk = newToken(token.ASTERISK, l.ch)
+	case '%':
+		tk = newToken(token.PERCENT, l.ch)
 	case '<':
-		tk = newToken(token.LT, l.ch)
+		if l.peekChar() == '<' {
+			tk = l.readOneOrTwoCharToken(token.LT, '<', token.SHIFT_LEFT)
+		} else {
+			tk = l.readOneOrTwoCharToken(token.LT, '=', token.LT_EQ)
+		}
 	case '>':
-		tk = newToken(token.GT, l.ch)
+		if l.peekChar() == '>' {
+			tk = l.readOneOrTwoCharToken(token.GT, '>', token.SHIFT_RIGHT)
+		} else {
+			tk = l.readOneOrTwoCharToken(token.GT, '=', token.GT_EQ)
+		}
+	case '&':
+		tk = l.readOneOrTwoCharToken(token.AMPERSAND, '&', token.AND)
+	case '|':
+		tk = l.readOneOrTwoCharToken(token.PIPE, '|', token.OR)
+	case '^':
+		tk = newToken(token.CARET, l.ch)
+	case '~':
+		tk = newToken(token.TILDE, l.ch)
 	case ';':
 		tk = newToken(token.SEMICOLON, l.ch)
 	case ':':
 		tk = newToken(token.COLON, l.ch)
+	case '.':
+		if strings.HasPrefix(l.input[l.position:], token.ELLIPSIS) {
+			l.readChar()
+			l.readChar()
+			tk = newToken(token.ELLIPSIS, token.ELLIPSIS)
+		} else {
+			tk = newToken(token.ILLEGAL, l.ch)
+		}
 	case ',':
 		tk = newToken(token.COMMA, l.ch)
 	case '{':
+		if n := len(l.templates); n > 0 {
+			l.templates[n-1]++
+		}
 		tk = newToken(token.LBRACE, l.ch)
 	case '}':
+		n := len(l.templates)
+		if n > 0 && l.templates[n-1] == 0 {
+			l.templates = l.templates[:n-1]
+			tk = l.readString(true)
+			break
+		}
+		if n > 0 {
+			l.templates[n-1]--
+		}
 		tk = newToken(token.RBRACE, l.ch)
 	case '(':
 		tk = newToken(token.LPAREN, l.ch)
@@ -74,7 +215,9 @@ func (l *Lexer) NextToken() token.Token {
 	case ']':
 		tk = newToken(token.RBRACKET, l.ch)
 	case '"':
-		tk = newToken(token.STRING, l.readString())
+		tk = l.readString(false)
+	case '`':
+		tk = l.readRawString()
 	case 0:
 		tk = newToken(token.EOF, "")
 	default:
@@ -83,31 +226,76 @@ func (l *Lexer) NextToken() token.Token {
 			tk = newToken(token.CheckIsKeyword(literal), literal)
 			return tk
 		} else if isDigit(l.ch) {
-			literal := l.readNumber()
-			tk = newToken(token.INT, literal)
+			literal, tokenType := l.readNumber()
+			tk = newToken(tokenType, literal)
 			return tk
 		} else {
 			tk = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
+	if assignType, ok := compoundAssignTypes[tk.Type]; ok && l.peekChar() == '=' {
+		l.readChar()
+		tk = newToken(assignType, tk.Literal+"=")
+	}
+
 	l.readChar()
 	return tk
 }
 
+// readOneOrTwoCharToken reads a token that is either the current char, or
+// the current char followed by next, like < and <=
+func (l *Lexer) readOneOrTwoCharToken(
+	single token.TokenType,
+	next byte,
+	double token.TokenType,
+) token.Token {
+	if l.peekChar() != next {
+		return newToken(single, l.ch)
+	}
+
+	literal := string(l.ch)
+	literal = literal + string(l.readChar())
+
+	return newToken(double, literal)
+}
+
 func (l *Lexer) readChar() byte {
+	if l.readPosition > len(l.input) {
+		// Already at the end of input, stay there
+		return l.ch
+	}
+
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
 		l.ch = l.input[l.readPosition]
 	}
 
+	if !isContinuationByte(l.ch) {
+		l.column++
+	}
+
 	l.position = l.readPosition
 	l.readPosition += 1
 
 	return l.ch
 }
 
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -122,6 +310,37 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+func (l *Lexer) skipLineComment() {
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+}
+
+// skipBlockComment skips a block comment and the comments nested in it, it
+// returns false if the input ends before the comment is closed
+func (l *Lexer) skipBlockComment() bool {
+	depth := 0
+
+	for {
+		switch {
+		case l.ch == 0:
+			return false
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return true
+			}
+		}
+
+		l.readChar()
+	}
+}
+
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 
@@ -132,25 +351,241 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer, or a float when the digits are followed by
+// a fraction or an exponent, like 1.5, 2e10 or 1.5e-3. Integers can have a
+// 0x, 0o or 0b base prefix, and digits can be separated by underscores.
+// An n suffix makes an integer a big integer, like 10n. Malformed numbers
+// are returned as an ERROR, with the message as literal.
+func (l *Lexer) readNumber() (string, token.TokenType) {
+	if l.ch == '0' && baseNames[lower(l.peekChar())] != "" {
+		return l.readBigIntSuffix(l.readPrefixedInteger())
+	}
+
 	position := l.position
+	tokenType := token.TokenType(token.INT)
 
-	for isDigit(l.ch) {
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
 		l.readChar()
+		l.readDigits()
 	}
 
-	return l.input[position:l.position]
+	if (l.ch == 'e' || l.ch == 'E') && l.isExponentNext() {
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		l.readDigits()
+	}
+
+	literal := l.input[position:l.position]
+	if !validUnderscores(literal, isDigit) {
+		return invalidUnderscore, token.ERROR
+	}
+
+	return l.readBigIntSuffix(literal, tokenType)
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readBigIntSuffix reads the n suffix after a number, if there is one, and
+// turns the integer into a big integer
+func (l *Lexer) readBigIntSuffix(literal string, tokenType token.TokenType) (string, token.TokenType) {
+	if l.ch != 'n' || tokenType == token.ERROR {
+		return literal, tokenType
+	}
+	l.readChar()
+
+	if tokenType == token.FLOAT {
+		return "the n suffix is only allowed on integers", token.ERROR
+	}
+
+	return literal + "n", token.BIGINT
+}
+
+// readPrefixedInteger reads an integer with a base prefix, like 0xFF,
+// 0o755 or 0b1010
+func (l *Lexer) readPrefixedInteger() (string, token.TokenType) {
+	position := l.position
+	base := lower(l.readChar())
+	l.readChar()
+
+	for isHexDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+
+	literal := l.input[position:l.position]
+	digits := literal[2:]
+
+	if strings.Trim(digits, "_") == "" {
+		return fmt.Sprintf("%s literal has no digits", baseNames[base]), token.ERROR
+	}
+
+	for i := 0; i < len(digits); i++ {
+		if digits[i] != '_' && !isDigitInBase(digits[i], base) {
+			return fmt.Sprintf("invalid digit %q in %s literal", digits[i], baseNames[base]), token.ERROR
+		}
+	}
+
+	// an underscore may follow the prefix, like in 0x_FF
+	if !validUnderscores(strings.TrimPrefix(digits, "_"), isHexDigit) {
+		return invalidUnderscore, token.ERROR
+	}
+
+	return literal, token.INT
+}
+
+// readDigits reads decimal digits and the underscores between them
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// isExponentNext reports whether the 'e' under examination starts an
+// exponent, that is it is followed by digits with an optional sign
+func (l *Lexer) isExponentNext() bool {
+	next := l.readPosition
+	if next < len(l.input) && (l.input[next] == '+' || l.input[next] == '-') {
+		next++
+	}
+
+	return next < len(l.input) && isDigit(l.input[next])
+}
+
+// readString reads a double quoted string and processes its escape
+// sequences. A string containing ${ is read up to it, and returned as the
+// head of an interpolated string; continued is set when reading the rest
+// of the string after the } of an interpolation. Malformed strings are
+// read up to the closing quote and returned as an ERROR token.
+func (l *Lexer) readString(continued bool) token.Token {
+	var out strings.Builder
+	errMessage := ""
 
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+
+		switch l.ch {
+		case '"', '$':
+			if l.ch == '$' && l.peekChar() != '{' {
+				out.WriteByte(l.ch)
+				continue
+			}
+
+			tokenType := stringTokenType(l.ch == '$', continued)
+			if tokenType != token.STRING && tokenType != token.TEMPLATE_TAIL {
+				// stop on the '{', the lexer moves past it as usual
+				l.readChar()
+				l.templates = append(l.templates, 0)
+			}
+
+			if errMessage != "" {
+				return newToken(token.ERROR, errMessage)
+			}
+			return newToken(tokenType, out.String())
+		case 0:
+			return newToken(token.ERROR, UnterminatedString)
+		case '\\':
+			r, ok := l.readEscape()
+			if l.ch == 0 {
+				return newToken(token.ERROR, UnterminatedString)
+			}
+			if !ok && errMessage == "" {
+				errMessage = fmt.Sprintf("invalid escape sequence in string: %s", r)
+			}
+			out.WriteString(r)
+		default:
+			out.WriteByte(l.ch)
 		}
 	}
+}
 
-	return l.input[position:l.position]
+// stringTokenType returns the type of a string part, which depends on
+// whether it ends with ${ or the closing quote, and whether it starts
+// after the } of an interpolation
+func stringTokenType(interpolation, continued bool) token.TokenType {
+	switch {
+	case interpolation && continued:
+		return token.TEMPLATE_MIDDLE
+	case interpolation:
+		return token.TEMPLATE_HEAD
+	case continued:
+		return token.TEMPLATE_TAIL
+	default:
+		return token.STRING
+	}
+}
+
+// readEscape reads the escape sequence after a backslash, and returns the
+// text it stands for, or the invalid sequence if it is not valid
+func (l *Lexer) readEscape() (string, bool) {
+	start := l.position
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		return "\n", true
+	case 't':
+		return "\t", true
+	case 'r':
+		return "\r", true
+	case '0':
+		return "\x00", true
+	case '\\', '"', '$':
+		return string(l.ch), true
+	case 'u':
+		if r, ok := l.readUnicodeEscape(); ok {
+			return string(r), true
+		}
+	case 0:
+		return "", false
+	}
+
+	return l.input[start : l.position+1], false
+}
+
+// readUnicodeEscape reads the code point of a \u{1F600} or \u00e9 escape
+func (l *Lexer) readUnicodeEscape() (rune, bool) {
+	var digits []byte
+
+	if l.peekChar() == '{' {
+		l.readChar()
+		for isHexDigit(l.peekChar()) && len(digits) <= 6 {
+			digits = append(digits, l.readChar())
+		}
+		if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+			return 0, false
+		}
+		l.readChar()
+	} else {
+		for len(digits) < 4 && isHexDigit(l.peekChar()) {
+			digits = append(digits, l.readChar())
+		}
+		if len(digits) != 4 {
+			return 0, false
+		}
+	}
+
+	value, _ := strconv.ParseUint(string(digits), 16, 32)
+	r := rune(value)
+
+	return r, utf8.ValidRune(r)
+}
+
+// readRawString reads a backtick string, which has no escape sequences and
+// can span lines
+func (l *Lexer) readRawString() token.Token {
+	position := l.position + 1
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case '`':
+			return newToken(token.STRING, l.input[position:l.position])
+		case 0:
+			return newToken(token.ERROR, UnterminatedRawString)
+		}
+	}
 }
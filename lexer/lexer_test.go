@@ -14,7 +14,7 @@ func TestNextToken(t *testing.T) {
 	};
 
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -30,6 +30,12 @@ func TestNextToken(t *testing.T) {
 	"foo bar"
 	[1, 2];
 	{"foo": "bar"}
+	a <= b >= c % d && e || f;
+	~a & b | c ^ d << 2 >> 1;
+	x += 1; x -= 1; x *= 1; x /= 1; x %= 1;
+	x &= 1; x |= 1; x ^= 1; x <<= 1; x >>= 1;
+	match (x) { _ => 1 }
+	[a, ...b]
 	`
 
 	tests := []struct {
@@ -122,6 +128,359 @@ func TestNextToken(t *testing.T) {
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "d"},
+		{token.AND, "&&"},
+		{token.IDENT, "e"},
+		{token.OR, "||"},
+		{token.IDENT, "f"},
+		{token.SEMICOLON, ";"},
+		{token.TILDE, "~"},
+		{token.IDENT, "a"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "b"},
+		{token.PIPE, "|"},
+		{token.IDENT, "c"},
+		{token.CARET, "^"},
+		{token.IDENT, "d"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.INT, "2"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.AMPERSAND_ASSIGN, "&="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PIPE_ASSIGN, "|="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.CARET_ASSIGN, "^="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SHIFT_LEFT_ASSIGN, "<<="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SHIFT_RIGHT_ASSIGN, ">>="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "_"},
+		{token.ARROW, "=>"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "b"},
+		{token.RBRACKET, "]"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tk := l.NextToken()
+
+		if tk.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tk.Type)
+		}
+
+		if tk.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tk.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  \"héllo\" + x"
+
+	pos := func(offset, line, column int) token.Position {
+		return token.Position{Filename: "test.monkey", Offset: offset, Line: line, Column: column}
+	}
+
+	tests := []struct {
+		expectedType  token.TokenType
+		expectedStart token.Position
+		expectedEnd   token.Position
+	}{
+		{token.LET, pos(0, 1, 1), pos(3, 1, 4)},
+		{token.IDENT, pos(4, 1, 5), pos(5, 1, 6)},
+		{token.ASSIGN, pos(6, 1, 7), pos(7, 1, 8)},
+		{token.INT, pos(8, 1, 9), pos(9, 1, 10)},
+		{token.SEMICOLON, pos(9, 1, 10), pos(10, 1, 11)},
+		{token.STRING, pos(13, 2, 3), pos(21, 2, 10)},
+		{token.PLUS, pos(22, 2, 11), pos(23, 2, 12)},
+		{token.IDENT, pos(24, 2, 13), pos(25, 2, 14)},
+		{token.EOF, pos(25, 2, 14), pos(25, 2, 14)},
+	}
+
+	l := NewWithFilename("test.monkey", input)
+
+	for i, tt := range tests {
+		tk := l.NextToken()
+
+		if tk.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tk.Type)
+		}
+
+		if tk.Start != tt.expectedStart {
+			t.Fatalf("tests[%d] - start wrong. expected=%+v, got=%+v",
+				i, tt.expectedStart, tk.Start)
+		}
+
+		if tk.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - end wrong. expected=%+v, got=%+v",
+				i, tt.expectedEnd, tk.End)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// header
+let x = 5; // trailing
+/* block /* nested */ still a comment */ x / 2 * 3
+// end`
+
+	pos := func(offset, line, column int) token.Position {
+		return token.Position{Offset: offset, Line: line, Column: column}
+	}
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedTrivia  []token.Trivia
+	}{
+		{token.LET, "let", []token.Trivia{
+			{Kind: token.LINE_COMMENT, Text: "// header", Start: pos(0, 1, 1), End: pos(9, 1, 10)},
+		}},
+		{token.IDENT, "x", nil},
+		{token.ASSIGN, "=", nil},
+		{token.INT, "5", nil},
+		{token.SEMICOLON, ";", nil},
+		{token.IDENT, "x", []token.Trivia{
+			{Kind: token.LINE_COMMENT, Text: "// trailing", Start: pos(21, 2, 12), End: pos(32, 2, 23)},
+			{Kind: token.BLOCK_COMMENT, Text: "/* block /* nested */ still a comment */", Start: pos(33, 3, 1), End: pos(73, 3, 41)},
+		}},
+		{token.SLASH, "/", nil},
+		{token.INT, "2", nil},
+		{token.ASTERISK, "*", nil},
+		{token.INT, "3", nil},
+		{token.EOF, "", []token.Trivia{
+			{Kind: token.LINE_COMMENT, Text: "// end", Start: pos(84, 4, 1), End: pos(90, 4, 7)},
+		}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tk := l.NextToken()
+
+		if tk.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tk.Type)
+		}
+
+		if tk.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tk.Literal)
+		}
+
+		if len(tk.LeadingTrivia) != len(tt.expectedTrivia) {
+			t.Fatalf("tests[%d] - wrong number of trivia. expected=%d, got=%d (%+v)",
+				i, len(tt.expectedTrivia), len(tk.LeadingTrivia), tk.LeadingTrivia)
+		}
+
+		for j, trivia := range tk.LeadingTrivia {
+			if trivia != tt.expectedTrivia[j] {
+				t.Fatalf("tests[%d] - trivia[%d] wrong. expected=%+v, got=%+v",
+					i, j, tt.expectedTrivia[j], trivia)
+			}
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("1 /* one /* two */ three")
+
+	if tk := l.NextToken(); tk.Type != token.INT {
+		t.Fatalf("expected INT, got %q", tk.Type)
+	}
+
+	tk := l.NextToken()
+	if tk.Type != token.ERROR {
+		t.Fatalf("expected ERROR, got %q", tk.Type)
+	}
+	if tk.Literal != "unterminated block comment" {
+		t.Errorf("wrong literal. got=%q", tk.Literal)
+	}
+	if tk.Start.Offset != 2 || tk.End.Offset != 24 {
+		t.Errorf("wrong span. expected 2..24, got %d..%d", tk.Start.Offset, tk.End.Offset)
+	}
+
+	if tk := l.NextToken(); tk.Type != token.EOF {
+		t.Fatalf("expected EOF, got %q", tk.Type)
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"42", []token.Token{{Type: token.INT, Literal: "42"}}},
+		{"3.14", []token.Token{{Type: token.FLOAT, Literal: "3.14"}}},
+		{"1.5e-3", []token.Token{{Type: token.FLOAT, Literal: "1.5e-3"}}},
+		{"2E+10", []token.Token{{Type: token.FLOAT, Literal: "2E+10"}}},
+		{"7e3", []token.Token{{Type: token.FLOAT, Literal: "7e3"}}},
+		{"1.", []token.Token{{Type: token.INT, Literal: "1"}, {Type: token.ILLEGAL, Literal: "."}}},
+		{"1e", []token.Token{{Type: token.INT, Literal: "1"}, {Type: token.IDENT, Literal: "e"}}},
+		{"1.5.2", []token.Token{{Type: token.FLOAT, Literal: "1.5"}, {Type: token.ILLEGAL, Literal: "."}, {Type: token.INT, Literal: "2"}}},
+		{"0xFF", []token.Token{{Type: token.INT, Literal: "0xFF"}}},
+		{"0Xab_cd", []token.Token{{Type: token.INT, Literal: "0Xab_cd"}}},
+		{"0o755", []token.Token{{Type: token.INT, Literal: "0o755"}}},
+		{"0b1010", []token.Token{{Type: token.INT, Literal: "0b1010"}}},
+		// leading zeros are lexed as decimals and rejected by the parser
+		{"0755", []token.Token{{Type: token.INT, Literal: "0755"}}},
+		{"09", []token.Token{{Type: token.INT, Literal: "09"}}},
+		{"0x_FF", []token.Token{{Type: token.INT, Literal: "0x_FF"}}},
+		{"1_000_000", []token.Token{{Type: token.INT, Literal: "1_000_000"}}},
+		{"1_000.000_1e1_0", []token.Token{{Type: token.FLOAT, Literal: "1_000.000_1e1_0"}}},
+		{"0xFFg", []token.Token{{Type: token.INT, Literal: "0xFF"}, {Type: token.IDENT, Literal: "g"}}},
+		{"0x", []token.Token{{Type: token.ERROR, Literal: "hexadecimal literal has no digits"}}},
+		{"0b_", []token.Token{{Type: token.ERROR, Literal: "binary literal has no digits"}}},
+		{"0b102", []token.Token{{Type: token.ERROR, Literal: "invalid digit '2' in binary literal"}}},
+		{"0o78", []token.Token{{Type: token.ERROR, Literal: "invalid digit '8' in octal literal"}}},
+		{"1__0", []token.Token{{Type: token.ERROR, Literal: "'_' must separate successive digits"}}},
+		{"1_", []token.Token{{Type: token.ERROR, Literal: "'_' must separate successive digits"}}},
+		{"0x__1", []token.Token{{Type: token.ERROR, Literal: "'_' must separate successive digits"}}},
+		{"1_.5", []token.Token{{Type: token.ERROR, Literal: "'_' must separate successive digits"}}},
+		{"42n", []token.Token{{Type: token.BIGINT, Literal: "42n"}}},
+		{"0xFF_FFn", []token.Token{{Type: token.BIGINT, Literal: "0xFF_FFn"}}},
+		{"1nx", []token.Token{{Type: token.BIGINT, Literal: "1n"}, {Type: token.IDENT, Literal: "x"}}},
+		{"1.5n", []token.Token{{Type: token.ERROR, Literal: "the n suffix is only allowed on integers"}}},
+		{"1e3n", []token.Token{{Type: token.ERROR, Literal: "the n suffix is only allowed on integers"}}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		for i, expected := range append(tt.expected, token.Token{Type: token.EOF}) {
+			tk := l.NextToken()
+
+			if tk.Type != expected.Type || tk.Literal != expected.Literal {
+				t.Fatalf("%q tokens[%d] - expected=%s %q, got=%s %q",
+					tt.input, i, expected.Type, expected.Literal, tk.Type, tk.Literal)
+			}
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"plain"`, token.STRING, "plain"},
+		{`"cost: $5 \${x}"`, token.STRING, "cost: $5 ${x}"},
+		{`"a\nb\tc\rd"`, token.STRING, "a\nb\tc\rd"},
+		{`"quote \" and backslash \\"`, token.STRING, `quote " and backslash \`},
+		{`"nul \0"`, token.STRING, "nul \x00"},
+		{`"é\u{1F600}\u{41}"`, token.STRING, "é😀A"},
+		{`"héllo"`, token.STRING, "héllo"},
+		{"`raw \\n \"string\"\nsecond line`", token.STRING, "raw \\n \"string\"\nsecond line"},
+		{`"bad \q"`, token.ERROR, `invalid escape sequence in string: \q`},
+		{`"bad \u{110000}"`, token.ERROR, `invalid escape sequence in string: \u{110000}`},
+		{`"short \u12"`, token.ERROR, `invalid escape sequence in string: \u12`},
+		{`"unterminated`, token.ERROR, UnterminatedString},
+		{`"ends with \`, token.ERROR, UnterminatedString},
+		{"`unterminated", token.ERROR, UnterminatedRawString},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tk := l.NextToken()
+
+		if tk.Type != tt.expectedType {
+			t.Fatalf("%s - tokentype wrong. expected=%q, got=%q",
+				tt.input, tt.expectedType, tk.Type)
+		}
+
+		if tk.Literal != tt.expectedLiteral {
+			t.Fatalf("%s - literal wrong. expected=%q, got=%q",
+				tt.input, tt.expectedLiteral, tk.Literal)
+		}
+
+		if tk.End.Offset != len(tt.input) {
+			t.Errorf("%s - token does not span the input. end=%d, want=%d",
+				tt.input, tk.End.Offset, len(tt.input))
+		}
+
+		if tk := l.NextToken(); tk.Type != token.EOF {
+			t.Errorf("%s - expected EOF after the string, got %q", tt.input, tk.Type)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"a ${x} b ${ {1: "c${y}"}[1] }\n"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE_HEAD, "a "},
+		{token.IDENT, "x"},
+		{token.TEMPLATE_MIDDLE, " b "},
+		{token.LBRACE, "{"},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.TEMPLATE_HEAD, "c"},
+		{token.IDENT, "y"},
+		{token.TEMPLATE_TAIL, ""},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_TAIL, "\n"},
 		{token.EOF, ""},
 	}
 
@@ -140,4 +499,11 @@ func TestNextToken(t *testing.T) {
 				i, tt.expectedLiteral, tk.Literal)
 		}
 	}
+
+	l = New(`"a ${x} b`)
+	for _, expected := range []token.TokenType{token.TEMPLATE_HEAD, token.IDENT, token.ERROR} {
+		if tk := l.NextToken(); tk.Type != expected {
+			t.Fatalf("unterminated interpolated string - expected=%q, got=%q", expected, tk.Type)
+		}
+	}
 }
@@ -2,20 +2,83 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"monkey/repl"
 	"os"
 	"os/user"
 )
 
+const usage = `Usage:
+	monkey [repl [-engine vm|evaluator|both]]
+	                                     start the interactive REPL
+	monkey run [-engine vm|evaluator] [-overflow wrap|error|promote] <file> [args...]
+	                                     run a script or compiled bytecode
+	monkey build [-o out.mkc] [-strip] <file>
+	                                     compile a script to bytecode
+	monkey tokens <file>                 print the tokens of a script
+	monkey ast <file>                    print the syntax tree of a script
+	monkey disasm <file>                 print the bytecode of a script
+
+Script arguments are available to the program in the "args" array.
+
+Exit codes:
+	0  success
+	1  the file could not be read or written
+	2  invalid command line
+	3  syntax errors
+	4  compile errors
+	5  runtime error
+`
+
+const (
+	exitOK           = 0
+	exitIOError      = 1
+	exitUsage        = 2
+	exitParseError   = 3
+	exitCompileError = 4
+	exitRuntimeError = 5
+)
+
 func main() {
+	if len(os.Args) < 2 {
+		startRepl(os.Stdout, repl.EngineVM)
+		return
+	}
+
+	args := os.Args[2:]
+
+	switch os.Args[1] {
+	case "repl":
+		os.Exit(replCommand(args, os.Stdout, os.Stderr))
+	case "run":
+		os.Exit(runCommand(args, os.Stdout, os.Stderr))
+	case "build":
+		os.Exit(buildCommand(args, os.Stdout, os.Stderr))
+	case "tokens":
+		os.Exit(tokensCommand(args, os.Stdout, os.Stderr))
+	case "ast":
+		os.Exit(astCommand(args, os.Stdout, os.Stderr))
+	case "disasm":
+		os.Exit(disasmCommand(args, os.Stdout, os.Stderr))
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(exitUsage)
+	}
+}
+
+func startRepl(out io.Writer, engine repl.Engine) {
 	user, err := user.Current()
 
 	if err != nil {
 		panic(err)
 	}
 
-	fmt.Printf("Hello %s! This is a Monkey programing language!\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
+	fmt.Fprintf(out, "Hello %s! This is a Monkey programing language!\n", user.Username)
+	fmt.Fprintf(out, "Feel free to type in commands\n")
+
+	fmt.Fprintf(out, "Type :help for the list of REPL commands\n")
 
-	repl.Start(os.Stdin, os.Stdout)
+	repl.StartWithEngine(os.Stdin, out, engine)
 }
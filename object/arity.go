@@ -0,0 +1,104 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Arity describes how many arguments a function accepts
+type Arity struct {
+	Required int  // parameters without a default value
+	Params   int  // all named parameters, not counting the rest parameter
+	Variadic bool // a rest parameter takes any further arguments
+}
+
+// Accepts reports whether a call with numArgs arguments is valid
+func (a Arity) Accepts(numArgs int) bool {
+	return numArgs >= a.Required && (a.Variadic || numArgs <= a.Params)
+}
+
+// String returns the accepted argument counts, like "2", "1 to 3" or
+// "at least 1"
+func (a Arity) String() string {
+	switch {
+	case a.Variadic:
+		return fmt.Sprintf("at least %d", a.Required)
+	case a.Required == a.Params:
+		return fmt.Sprintf("%d", a.Required)
+	default:
+		return fmt.Sprintf("%d to %d", a.Required, a.Params)
+	}
+}
+
+// ArityError returns the message both engines report when the function
+// name is called with numArgs arguments it does not accept. Anonymous
+// functions have an empty name.
+func ArityError(name string, arity Arity, numArgs int) string {
+	if name == "" {
+		return fmt.Sprintf("wrong number of arguments: want=%s, got=%d", arity, numArgs)
+	}
+	return fmt.Sprintf("wrong number of arguments to `%s`: want=%s, got=%d", name, arity, numArgs)
+}
+
+// checkArity returns the error for a builtin called with the wrong number
+// of arguments, or nil
+func checkArity(name string, arity Arity, args []Object) *ErrorObject {
+	if arity.Accepts(len(args)) {
+		return nil
+	}
+	return newError("%s", ArityError(name, arity, len(args)))
+}
+
+// BindArguments orders the arguments of a call by the parameters of the
+// function name. The last len(names) arguments are passed by the given
+// names, the others by position. The result has an argument for every
+// parameter, nil where the call passed none, followed by the further
+// positional arguments. Builtins have no parameter names.
+func BindArguments(name string, arity Arity, params []string, args []Object, names []string) ([]Object, error) {
+	numPositional := len(args) - len(names)
+
+	bound := make([]Object, arity.Params)
+	if numPositional > arity.Params {
+		bound = make([]Object, numPositional)
+	}
+	copy(bound, args[:numPositional])
+
+	for i, argName := range names {
+		index := indexOf(params, argName)
+		if index < 0 {
+			return nil, fmt.Errorf("unknown parameter `%s`%s", argName, ofFunction(name))
+		}
+		if bound[index] != nil {
+			return nil, fmt.Errorf("duplicate argument for parameter `%s`%s", argName, ofFunction(name))
+		}
+		bound[index] = args[numPositional+i]
+	}
+
+	if !arity.Variadic && numPositional > arity.Params {
+		return nil, errors.New(ArityError(name, arity, len(args)))
+	}
+
+	for i := 0; i < arity.Required; i++ {
+		if bound[i] == nil {
+			return nil, fmt.Errorf("missing argument for parameter `%s`%s", params[i], ofFunction(name))
+		}
+	}
+
+	return bound, nil
+}
+
+func indexOf(params []string, name string) int {
+	for i, param := range params {
+		if param == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func ofFunction(name string) string {
+	if name == "" {
+		return ""
+	}
+	return " of `" + name + "`"
+}
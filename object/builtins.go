@@ -1,6 +1,13 @@
 package object
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Stdout is where the puts builtin writes to
+var Stdout io.Writer = os.Stdout
 
 var Builtins = []struct {
 	Name    string
@@ -9,16 +16,15 @@ var Builtins = []struct {
 	{
 		"len",
 		&BuiltinObject{Fn: func(args ...Object) Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
+			if err := checkArity("len", Arity{Required: 1, Params: 1}, args); err != nil {
+				return err
 			}
 
 			switch arg := args[0].(type) {
 			case *ArrayObject:
 				return &IntObject{Value: int64(len(arg.Elements))}
 			case *StringObject:
-				return &IntObject{Value: int64(len(arg.Value))}
+				return &IntObject{Value: int64(arg.Len())}
 			default:
 				return newError("argument to `len` not supported, got %s",
 					args[0].Type())
@@ -30,7 +36,7 @@ var Builtins = []struct {
 		"puts",
 		&BuiltinObject{Fn: func(args ...Object) Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(Stdout, arg.Inspect())
 			}
 
 			return nil
@@ -40,9 +46,8 @@ var Builtins = []struct {
 	{
 		"first",
 		&BuiltinObject{Fn: func(args ...Object) Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
+			if err := checkArity("first", Arity{Required: 1, Params: 1}, args); err != nil {
+				return err
 			}
 			if args[0].Type() != ARRAY_OBJ {
 				return newError("argument to `first` must be ARRAY, got %s",
@@ -61,9 +66,8 @@ var Builtins = []struct {
 	{
 		"last",
 		&BuiltinObject{Fn: func(args ...Object) Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
+			if err := checkArity("last", Arity{Required: 1, Params: 1}, args); err != nil {
+				return err
 			}
 			if args[0].Type() != ARRAY_OBJ {
 				return newError("argument to `last` must be ARRAY, got %s",
@@ -83,9 +87,8 @@ var Builtins = []struct {
 	{
 		"rest",
 		&BuiltinObject{Fn: func(args ...Object) Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
-					len(args))
+			if err := checkArity("rest", Arity{Required: 1, Params: 1}, args); err != nil {
+				return err
 			}
 			if args[0].Type() != ARRAY_OBJ {
 				return newError("argument to `rest` must be ARRAY, got %s",
@@ -107,9 +110,8 @@ var Builtins = []struct {
 	{
 		"push",
 		&BuiltinObject{Fn: func(args ...Object) Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2",
-					len(args))
+			if err := checkArity("push", Arity{Required: 2, Params: 2}, args); err != nil {
+				return err
 			}
 			if args[0].Type() != ARRAY_OBJ {
 				return newError("argument to `push` must be ARRAY, got %s",
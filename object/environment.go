@@ -30,3 +30,18 @@ func (e *Environment) Set(name string, value Object) Object {
 	e.store[name] = value
 	return value
 }
+
+// Assign updates a variable in the scope where it was defined. It returns
+// false if the variable is not defined in this or any outer scope.
+func (e *Environment) Assign(name string, value Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = value
+		return true
+	}
+
+	if e.outer != nil {
+		return e.outer.Assign(name, value)
+	}
+
+	return false
+}
@@ -0,0 +1,31 @@
+package object
+
+import "fmt"
+
+// SetIndex implements left[index] = value, it updates arrays and hashes in
+// place, so every reference to them sees the new element
+func SetIndex(left, index, value Object) error {
+	switch left := left.(type) {
+	case *ArrayObject:
+		i, ok := index.(*IntObject)
+		if !ok {
+			return fmt.Errorf("array index must be an integer, got %s", index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return fmt.Errorf("index out of range: %d", i.Value)
+		}
+		left.Elements[i.Value] = value
+		return nil
+
+	case *HashObject:
+		key, ok := index.(Hashable)
+		if !ok {
+			return fmt.Errorf("unusable as hash key: %s", index.Type())
+		}
+		left.Pairs[key.HashKey()] = HashPair{Key: index, Value: value}
+		return nil
+
+	default:
+		return fmt.Errorf("index assignment not supported: %s", left.Type())
+	}
+}
@@ -0,0 +1,225 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// OverflowPolicy selects what happens when the result of an integer
+// operation does not fit in 64 bits
+type OverflowPolicy string
+
+const (
+	OverflowWrap    OverflowPolicy = "wrap"    // wrap around, like Go does
+	OverflowError   OverflowPolicy = "error"   // fail with an error
+	OverflowPromote OverflowPolicy = "promote" // continue with a BigIntObject
+)
+
+// ParseOverflowPolicy maps a user supplied policy name to a policy
+func ParseOverflowPolicy(name string) (OverflowPolicy, bool) {
+	switch policy := OverflowPolicy(name); policy {
+	case OverflowWrap, OverflowError, OverflowPromote:
+		return policy, true
+	}
+	return "", false
+}
+
+var ErrDivisionByZero = errors.New("division by zero")
+
+// maxShift limits the shifts of big integers, larger shifts would take up
+// all the memory
+const maxShift = 1 << 16
+
+// IsInteger reports whether obj is an IntObject or a BigIntObject
+func IsInteger(obj Object) bool {
+	switch obj.(type) {
+	case *IntObject, *BigIntObject:
+		return true
+	default:
+		return false
+	}
+}
+
+// IntegerOperation computes an arithmetic, bitwise or shift operation on
+// two integers. An int64 result that overflows is handled as the policy
+// says, an empty policy wraps. Operations with a BigIntObject operand are
+// exact and return a BigIntObject.
+func IntegerOperation(operator string, left, right Object, policy OverflowPolicy) (Object, error) {
+	leftInt, leftOk := left.(*IntObject)
+	rightInt, rightOk := right.(*IntObject)
+
+	if !leftOk || !rightOk {
+		return bigOperation(operator, asBig(left), asBig(right))
+	}
+
+	a, b := leftInt.Value, rightInt.Value
+
+	switch operator {
+	case "/", "%":
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+	case "<<", ">>":
+		if b < 0 {
+			return nil, fmt.Errorf("negative shift count: %d", b)
+		}
+	}
+
+	result, exact, err := int64Operation(operator, a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case exact || policy == "" || policy == OverflowWrap:
+		return &IntObject{Value: result}, nil
+	case policy == OverflowError:
+		return nil, fmt.Errorf("integer overflow: %d %s %d", a, operator, b)
+	default:
+		return bigOperation(operator, big.NewInt(a), big.NewInt(b))
+	}
+}
+
+// NegateInteger returns the negation of an integer
+func NegateInteger(obj Object, policy OverflowPolicy) (Object, error) {
+	if integer, ok := obj.(*IntObject); ok {
+		if integer.Value != math.MinInt64 || policy == "" || policy == OverflowWrap {
+			return &IntObject{Value: -integer.Value}, nil
+		}
+		if policy == OverflowError {
+			return nil, fmt.Errorf("integer overflow: -(%d)", integer.Value)
+		}
+	}
+
+	return &BigIntObject{Value: new(big.Int).Neg(asBig(obj))}, nil
+}
+
+// ComplementInteger returns the bitwise complement of an integer, that is
+// -x - 1
+func ComplementInteger(obj Object) Object {
+	if integer, ok := obj.(*IntObject); ok {
+		return &IntObject{Value: ^integer.Value}
+	}
+
+	return &BigIntObject{Value: new(big.Int).Not(asBig(obj))}
+}
+
+// CompareIntegers returns -1, 0 or +1 when left is less than, equal to or
+// greater than right
+func CompareIntegers(left, right Object) int {
+	leftInt, leftOk := left.(*IntObject)
+	rightInt, rightOk := right.(*IntObject)
+
+	if !leftOk || !rightOk {
+		return asBig(left).Cmp(asBig(right))
+	}
+
+	switch {
+	case leftInt.Value < rightInt.Value:
+		return -1
+	case leftInt.Value > rightInt.Value:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// int64Operation computes an operation with Go semantics, and reports
+// whether the result is exact, that is it did not overflow
+func int64Operation(operator string, a, b int64) (int64, bool, error) {
+	switch operator {
+	case "+":
+		r := a + b
+		return r, (a^r)&(b^r) >= 0, nil
+	case "-":
+		r := a - b
+		return r, (a^b)&(a^r) >= 0, nil
+	case "*":
+		r := a * b
+		exact := a == 0 || (r/a == b && !(a == -1 && b == math.MinInt64))
+		return r, exact, nil
+	case "/":
+		return a / b, !(a == math.MinInt64 && b == -1), nil
+	case "%":
+		return a % b, true, nil
+	case "&":
+		return a & b, true, nil
+	case "|":
+		return a | b, true, nil
+	case "^":
+		return a ^ b, true, nil
+	case "<<":
+		if b >= 64 {
+			return 0, a == 0, nil
+		}
+		r := a << b
+		return r, r>>b == a, nil
+	case ">>":
+		return a >> b, true, nil
+	default:
+		return 0, false, fmt.Errorf("unknown integer operator: %s", operator)
+	}
+}
+
+func bigOperation(operator string, a, b *big.Int) (Object, error) {
+	r := new(big.Int)
+
+	switch operator {
+	case "+":
+		r.Add(a, b)
+	case "-":
+		r.Sub(a, b)
+	case "*":
+		r.Mul(a, b)
+	case "/", "%":
+		if b.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		// Quo and Rem truncate like the int64 operations
+		if operator == "/" {
+			r.Quo(a, b)
+		} else {
+			r.Rem(a, b)
+		}
+	case "&":
+		r.And(a, b)
+	case "|":
+		r.Or(a, b)
+	case "^":
+		r.Xor(a, b)
+	case "<<", ">>":
+		if b.Sign() < 0 {
+			return nil, fmt.Errorf("negative shift count: %s", b)
+		}
+		if operator == ">>" {
+			if !b.IsInt64() || b.Int64() > int64(a.BitLen()) {
+				b = big.NewInt(int64(a.BitLen()))
+			}
+			r.Rsh(a, uint(b.Int64()))
+			break
+		}
+		if a.Sign() != 0 && (!b.IsInt64() || b.Int64() > maxShift) {
+			return nil, fmt.Errorf("shift count too large: %s", b)
+		}
+		if a.Sign() != 0 {
+			r.Lsh(a, uint(b.Int64()))
+		}
+	default:
+		return nil, fmt.Errorf("unknown integer operator: %s", operator)
+	}
+
+	return &BigIntObject{Value: r}, nil
+}
+
+func asBig(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *IntObject:
+		return big.NewInt(obj.Value)
+	case *BigIntObject:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
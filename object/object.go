@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/code"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type BuiltinFunction func(args ...Object) Object
@@ -18,10 +23,14 @@ const (
 	ERROR_OBJ = "ERROR"
 
 	INT_OBJ    = "INT"
+	BIGINT_OBJ = "BIGINT"
+	FLOAT_OBJ  = "FLOAT"
 	BOOL_OBJ   = "BOOL"
 	STRING_OBJ = "STRING"
 
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 
 	FN_OBJ     = "FN"
 	BULTIN_OBJ = "BUILTIN"
@@ -31,6 +40,7 @@ const (
 
 	COMPILED_FN_OBJ = "COMPILED_FUNC_OBJ"
 	CLOSURE_OBJ     = "CLOSURE_OBJ"
+	UPVALUE_OBJ     = "UPVALUE_OBJ"
 )
 
 type HashKey struct {
@@ -58,6 +68,70 @@ func (i *IntObject) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+/* Big integer object */
+
+// BigIntObject is an arbitrary precision integer, written with an n suffix
+// like 10n, or the result of an overflow with the promote policy. It
+// compares and hashes like the IntObject of the same value.
+type BigIntObject struct {
+	Value *big.Int
+}
+
+func (b *BigIntObject) Type() ObjectType { return BIGINT_OBJ }
+func (b *BigIntObject) Inspect() string  { return b.Value.String() }
+func (b *BigIntObject) HashKey() HashKey {
+	if b.Value.IsInt64() {
+		return HashKey{Type: INT_OBJ, Value: uint64(b.Value.Int64())}
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(b.Value.String()))
+
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+/* Float object */
+type FloatObject struct {
+	Value float64
+}
+
+func (f *FloatObject) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect keeps a fraction on integral values, so 2.0 does not print like
+// the integer 2
+func (f *FloatObject) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+	return s + ".0"
+}
+
+// HashKey hashes integral floats like the equal integer, so 1.0 and 1 are
+// the same hash key
+func (f *FloatObject) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return HashKey{Type: INT_OBJ, Value: uint64(int64(f.Value))}
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
+// AsFloat returns the value of an int or float object as a float, it is
+// used for arithmetic that mixes ints and floats
+func AsFloat(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *IntObject:
+		return float64(obj.Value), true
+	case *BigIntObject:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f, true
+	case *FloatObject:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
 /* Boolean object */
 type BoolObject struct {
 	Value bool
@@ -83,7 +157,28 @@ type StringObject struct {
 }
 
 func (s *StringObject) Type() ObjectType { return STRING_OBJ }
-func (s *StringObject) Inspect() string  { return s.Value }
+
+// Len returns the length of the string in runes
+func (s *StringObject) Len() int { return utf8.RuneCountInString(s.Value) }
+
+// RuneAt returns the rune at index as a string, indexes count runes, not
+// bytes
+func (s *StringObject) RuneAt(index int64) (string, bool) {
+	if index < 0 {
+		return "", false
+	}
+
+	var i int64
+	for _, r := range s.Value {
+		if i == index {
+			return string(r), true
+		}
+		i++
+	}
+
+	return "", false
+}
+func (s *StringObject) Inspect() string { return s.Value }
 func (s *StringObject) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -105,6 +200,17 @@ type ReturnValueObject struct {
 func (rv *ReturnValueObject) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValueObject) Inspect() string  { return rv.ValueObject.Inspect() }
 
+/* Break and continue objects, they unwind a loop body */
+type BreakObject struct{}
+
+func (b *BreakObject) Type() ObjectType { return BREAK_OBJ }
+func (b *BreakObject) Inspect() string  { return "break" }
+
+type ContinueObject struct{}
+
+func (c *ContinueObject) Type() ObjectType { return CONTINUE_OBJ }
+func (c *ContinueObject) Inspect() string  { return "continue" }
+
 /* ErrorObject object */
 type ErrorObject struct {
 	Message string
@@ -115,9 +221,12 @@ func (e *ErrorObject) Inspect() string  { return fmt.Sprintf("Error: %s", e.Mess
 
 /* FunctionObject object */
 type FunctionObject struct {
-	ParamNodes []*ast.IdentifierNode
-	BodyNode   *ast.BlockStatementNode
-	Env        *Environment
+	ParamNodes   []*ast.IdentifierNode
+	DefaultNodes []ast.ExpressionNode // default values of the last parameters
+	RestNode     *ast.IdentifierNode  // the ...rest parameter, if any
+	BodyNode     *ast.BlockStatementNode
+	Env          *Environment
+	Name         string // name the function was bound to with let, if any
 }
 
 func (f *FunctionObject) Type() ObjectType { return FN_OBJ }
@@ -125,8 +234,16 @@ func (f *FunctionObject) Inspect() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.ParamNodes {
-		params = append(params, p.String())
+	required := len(f.ParamNodes) - len(f.DefaultNodes)
+	for i, p := range f.ParamNodes {
+		if i < required {
+			params = append(params, p.String())
+		} else {
+			params = append(params, p.String()+" = "+f.DefaultNodes[i-required].String())
+		}
+	}
+	if f.RestNode != nil {
+		params = append(params, "..."+f.RestNode.String())
 	}
 
 	out.WriteString("fn")
@@ -139,6 +256,25 @@ func (f *FunctionObject) Inspect() string {
 	return out.String()
 }
 
+// ParamNames returns the names of the parameters, not counting the rest
+// parameter
+func (f *FunctionObject) ParamNames() []string {
+	names := make([]string, len(f.ParamNodes))
+	for i, paramNode := range f.ParamNodes {
+		names[i] = paramNode.Value
+	}
+	return names
+}
+
+// Arity returns the argument counts the function accepts
+func (f *FunctionObject) Arity() Arity {
+	return Arity{
+		Required: len(f.ParamNodes) - len(f.DefaultNodes),
+		Params:   len(f.ParamNodes),
+		Variadic: f.RestNode != nil,
+	}
+}
+
 /* Builtin obect */
 type BuiltinObject struct {
 	Fn BuiltinFunction
@@ -189,6 +325,8 @@ func (h *HashObject) Inspect() string {
 			fmt.Sprintf("%s : %s", pair.Key.Inspect(), pair.Value.Inspect()),
 		)
 	}
+	// sorted, so the output does not depend on the order of the map
+	sort.Strings(strPairs)
 
 	out.WriteString("{")
 	out.WriteString(strings.Join(strPairs, ", "))
@@ -200,7 +338,12 @@ func (h *HashObject) Inspect() string {
 type CompiledFnObject struct {
 	Instructions  code.Instructions
 	NumLocals     int
-	NumParameters int
+	NumParameters int             // named parameters, not counting the rest parameter
+	ParamNames    []string        // names of the parameters, for named arguments
+	NumDefaults   int             // the last parameters that have a default value
+	Variadic      bool            // the local after the parameters holds the further arguments
+	Name          string          // name the function was bound to with let, if any
+	SourceMap     *code.SourceMap // debug info, may be nil
 }
 
 func (cf *CompiledFnObject) Type() ObjectType { return COMPILED_FN_OBJ }
@@ -208,12 +351,52 @@ func (cf *CompiledFnObject) Inspect() string {
 	return fmt.Sprintf("CompiledFunction[%p]", cf)
 }
 
+// Arity returns the argument counts the function accepts
+func (cf *CompiledFnObject) Arity() Arity {
+	return Arity{
+		Required: cf.NumParameters - cf.NumDefaults,
+		Params:   cf.NumParameters,
+		Variadic: cf.Variadic,
+	}
+}
+
 type ClosureObject struct {
 	Fn   *CompiledFnObject
-	Free []Object
+	Free []*UpvalueObject
 }
 
 func (c *ClosureObject) Type() ObjectType { return CLOSURE_OBJ }
 func (c *ClosureObject) Inspect() string {
 	return fmt.Sprintf("CLOSURE_OBJ[%p]", c)
 }
+
+// UpvalueObject A variable captured by closures, shared by all of them.
+// While the function that defined the variable runs, the upvalue is open
+// and refers to the stack slot of the variable. When the function returns
+// the upvalue is closed, it keeps the last value of the variable itself.
+type UpvalueObject struct {
+	location *Object
+	closed   Object
+}
+
+func NewOpenUpvalue(slot *Object) *UpvalueObject {
+	return &UpvalueObject{location: slot}
+}
+
+func NewClosedUpvalue(value Object) *UpvalueObject {
+	u := &UpvalueObject{closed: value}
+	u.location = &u.closed
+	return u
+}
+
+func (u *UpvalueObject) Get() Object      { return *u.location }
+func (u *UpvalueObject) Set(value Object) { *u.location = value }
+func (u *UpvalueObject) Type() ObjectType { return UPVALUE_OBJ }
+func (u *UpvalueObject) Inspect() string  { return fmt.Sprintf("UPVALUE_OBJ[%p]", u) }
+
+// Close copies the value out of the stack slot, the slot is reused once
+// the function returns
+func (u *UpvalueObject) Close() {
+	u.closed = *u.location
+	u.location = &u.closed
+}
@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &StringObject{Value: "Hello World"}
@@ -58,3 +62,276 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	half1 := &FloatObject{Value: 0.5}
+	half2 := &FloatObject{Value: 0.5}
+	two := &FloatObject{Value: 2}
+
+	if half1.HashKey() != half2.HashKey() {
+		t.Errorf("floats with same content have different hash keys")
+	}
+
+	if half1.HashKey() == two.HashKey() {
+		t.Errorf("floats with different content have same hash keys")
+	}
+
+	if two.HashKey() != (&IntObject{Value: 2}).HashKey() {
+		t.Errorf("integral float has a different hash key than the equal integer")
+	}
+
+	if half1.HashKey() == (&IntObject{Value: 0}).HashKey() {
+		t.Errorf("fractional float has the same hash key as its truncated integer")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-3, "-3.0"},
+		{0.0015, "0.0015"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		if got := (&FloatObject{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("wrong inspect for %g. expected=%q, got=%q", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	hash := &HashObject{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []string{"c", "a", "b"} {
+		k := &StringObject{Value: key}
+		hash.Pairs[k.HashKey()] = HashPair{Key: k, Value: &IntObject{Value: 1}}
+	}
+
+	expected := "{a : 1, b : 1, c : 1}"
+	for i := 0; i < 10; i++ {
+		if got := hash.Inspect(); got != expected {
+			t.Fatalf("wrong inspect. expected=%q, got=%q", expected, got)
+		}
+	}
+}
+
+func TestIntegerOperation(t *testing.T) {
+	big := func(s string) Object {
+		value, _ := new(big.Int).SetString(s, 10)
+		return &BigIntObject{Value: value}
+	}
+	integer := func(value int64) Object { return &IntObject{Value: value} }
+
+	tests := []struct {
+		operator    string
+		left, right Object
+		policy      OverflowPolicy
+		expected    string // Inspect form of the result, or the error message
+	}{
+		{"+", integer(1), integer(2), OverflowError, "3"},
+		{"+", integer(math.MaxInt64), integer(1), OverflowWrap, "-9223372036854775808"},
+		{"+", integer(math.MaxInt64), integer(1), "", "-9223372036854775808"},
+		{"+", integer(math.MaxInt64), integer(1), OverflowError, "integer overflow: 9223372036854775807 + 1"},
+		{"+", integer(math.MaxInt64), integer(1), OverflowPromote, "9223372036854775808"},
+		{"-", integer(math.MinInt64), integer(1), OverflowPromote, "-9223372036854775809"},
+		{"-", integer(math.MinInt64), integer(1), OverflowError, "integer overflow: -9223372036854775808 - 1"},
+		{"*", integer(math.MaxInt64), integer(2), OverflowPromote, "18446744073709551614"},
+		{"*", integer(-1), integer(math.MinInt64), OverflowError, "integer overflow: -1 * -9223372036854775808"},
+		{"*", integer(math.MinInt64), integer(-1), OverflowError, "integer overflow: -9223372036854775808 * -1"},
+		{"*", integer(1 << 32), integer(1 << 30), OverflowError, "4611686018427387904"},
+		{"/", integer(math.MinInt64), integer(-1), OverflowPromote, "9223372036854775808"},
+		{"/", integer(7), integer(-2), OverflowError, "-3"},
+		{"/", integer(1), integer(0), OverflowWrap, "division by zero"},
+		{"%", integer(1), integer(0), OverflowPromote, "division by zero"},
+		{"%", integer(math.MinInt64), integer(-1), OverflowError, "0"},
+		{"<<", integer(1), integer(62), OverflowError, "4611686018427387904"},
+		{"<<", integer(1), integer(63), OverflowError, "integer overflow: 1 << 63"},
+		{"<<", integer(1), integer(64), OverflowWrap, "0"},
+		{"<<", integer(3), integer(64), OverflowPromote, "55340232221128654848"},
+		{"<<", integer(0), integer(100), OverflowError, "0"},
+		{"<<", integer(1), integer(-1), OverflowPromote, "negative shift count: -1"},
+		{">>", integer(-1), integer(100), OverflowError, "-1"},
+		{"+", big("9223372036854775808"), integer(-1), OverflowWrap, "9223372036854775807"},
+		{"-", big("9223372036854775808"), big("9223372036854775808"), OverflowWrap, "0"},
+		{"/", big("-18446744073709551616"), integer(-3), OverflowWrap, "6148914691236517205"},
+		{"%", big("-18446744073709551616"), integer(3), OverflowWrap, "-1"},
+		{"/", big("18446744073709551616"), integer(0), OverflowWrap, "division by zero"},
+		{">>", big("-18446744073709551616"), integer(1000), OverflowWrap, "-1"},
+		{"<<", big("18446744073709551616"), big("18446744073709551616"), OverflowWrap, "shift count too large: 18446744073709551616"},
+	}
+
+	for _, tt := range tests {
+		result, err := IntegerOperation(tt.operator, tt.left, tt.right, tt.policy)
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = result.Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("%s %s %s (%s) - expected=%s, got=%s",
+				tt.left.Inspect(), tt.operator, tt.right.Inspect(), tt.policy, tt.expected, got)
+		}
+
+		bigOperand := tt.left.Type() == BIGINT_OBJ || tt.right.Type() == BIGINT_OBJ
+		if err == nil && bigOperand && result.Type() != BIGINT_OBJ {
+			t.Errorf("%s %s %s - expected a big integer, got %T",
+				tt.left.Inspect(), tt.operator, tt.right.Inspect(), result)
+		}
+	}
+}
+
+func TestNegateInteger(t *testing.T) {
+	tests := []struct {
+		value    Object
+		policy   OverflowPolicy
+		expected string
+	}{
+		{&IntObject{Value: 5}, OverflowError, "-5"},
+		{&IntObject{Value: math.MinInt64}, OverflowWrap, "-9223372036854775808"},
+		{&IntObject{Value: math.MinInt64}, OverflowError, "integer overflow: -(-9223372036854775808)"},
+		{&IntObject{Value: math.MinInt64}, OverflowPromote, "9223372036854775808"},
+		{&BigIntObject{Value: new(big.Int).Lsh(big.NewInt(1), 63)}, OverflowWrap, "-9223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		result, err := NegateInteger(tt.value, tt.policy)
+
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			got = result.Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("-%s (%s) - expected=%s, got=%s", tt.value.Inspect(), tt.policy, tt.expected, got)
+		}
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &IntObject{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+
+	if !inner.Assign("x", &IntObject{Value: 2}) {
+		t.Fatalf("Assign returned false for a variable of the outer scope")
+	}
+	if x, _ := outer.Get("x"); x.(*IntObject).Value != 2 {
+		t.Errorf("outer x was not updated. got=%s", x.Inspect())
+	}
+	if _, ok := inner.store["x"]; ok {
+		t.Errorf("Assign defined x in the inner scope")
+	}
+
+	if inner.Assign("y", &IntObject{Value: 3}) {
+		t.Errorf("Assign returned true for an undefined variable")
+	}
+	if _, ok := inner.Get("y"); ok {
+		t.Errorf("Assign defined the undefined variable y")
+	}
+}
+
+func TestHasType(t *testing.T) {
+	tests := []struct {
+		obj      Object
+		typeName string
+		expected bool
+	}{
+		{&IntObject{Value: 1}, "int", true},
+		{&BigIntObject{Value: big.NewInt(1)}, "int", true},
+		{&FloatObject{Value: 1}, "int", false},
+		{&FloatObject{Value: 1}, "float", true},
+		{&StringObject{Value: "a"}, "string", true},
+		{&ArrayObject{}, "array", true},
+		{&ArrayObject{}, "hash", false},
+		{&BuiltinObject{}, "function", true},
+		{&ClosureObject{}, "function", true},
+		{&NullObject{}, "null", true},
+		{&NullObject{}, "none", false},
+	}
+
+	for _, tt := range tests {
+		if got := HasType(tt.obj, tt.typeName); got != tt.expected {
+			t.Errorf("HasType(%s, %q) - expected=%t, got=%t", tt.obj.Type(), tt.typeName, tt.expected, got)
+		}
+	}
+}
+
+func TestArity(t *testing.T) {
+	tests := []struct {
+		arity    Arity
+		numArgs  int
+		accepts  bool
+		expected string
+	}{
+		{Arity{Required: 2, Params: 2}, 2, true, "2"},
+		{Arity{Required: 2, Params: 2}, 1, false, "2"},
+		{Arity{Required: 1, Params: 3}, 3, true, "1 to 3"},
+		{Arity{Required: 1, Params: 3}, 4, false, "1 to 3"},
+		{Arity{Required: 1, Params: 1, Variadic: true}, 9, true, "at least 1"},
+		{Arity{Required: 1, Params: 1, Variadic: true}, 0, false, "at least 1"},
+	}
+
+	for _, tt := range tests {
+		if tt.arity.Accepts(tt.numArgs) != tt.accepts {
+			t.Errorf("%+v accepts %d arguments: want=%t", tt.arity, tt.numArgs, tt.accepts)
+		}
+		if tt.arity.String() != tt.expected {
+			t.Errorf("wrong string for %+v. want=%q, got=%q", tt.arity, tt.expected, tt.arity.String())
+		}
+	}
+}
+
+func TestBindArguments(t *testing.T) {
+	one, two, three := &IntObject{Value: 1}, &IntObject{Value: 2}, &IntObject{Value: 3}
+	params := []string{"a", "b", "c"}
+
+	tests := []struct {
+		arity    Arity
+		args     []Object
+		names    []string
+		expected []Object
+		err      string
+	}{
+		{Arity{Required: 1, Params: 3}, []Object{one, three}, []string{"c"}, []Object{one, nil, three}, ""},
+		{Arity{Required: 2, Params: 3}, []Object{two, one}, []string{"b", "a"}, []Object{one, two, nil}, ""},
+		{Arity{Required: 1, Params: 3, Variadic: true}, []Object{one, two, three, one, two}, []string{"b"}, nil, "duplicate argument for parameter `b` of `f`"},
+		{Arity{Required: 1, Params: 1, Variadic: true}, []Object{one, two, three}, []string{}, []Object{one, two, three}, ""},
+		{Arity{Required: 1, Params: 3}, []Object{one}, []string{"d"}, nil, "unknown parameter `d` of `f`"},
+		{Arity{Required: 2, Params: 3}, []Object{one, three}, []string{"c"}, nil, "missing argument for parameter `b` of `f`"},
+		{Arity{Required: 3, Params: 3}, []Object{one, two, three, one}, []string{"a"}, nil, "duplicate argument for parameter `a` of `f`"},
+		{Arity{Required: 1, Params: 1}, []Object{one, two, three}, []string{}, nil, "wrong number of arguments to `f`: want=1, got=3"},
+	}
+
+	for _, tt := range tests {
+		bound, err := BindArguments("f", tt.arity, params[:tt.arity.Params], tt.args, tt.names)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("wrong error for %v. want=%q, got=%v", tt.names, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %v: %s", tt.names, err)
+			continue
+		}
+		if len(bound) != len(tt.expected) {
+			t.Fatalf("wrong number of arguments for %v. want=%d, got=%d", tt.names, len(tt.expected), len(bound))
+		}
+		for i, arg := range tt.expected {
+			if bound[i] != arg {
+				t.Errorf("wrong argument %d for %v. want=%v, got=%v", i, tt.names, arg, bound[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package object
+
+// HasType reports whether obj has the type named in a type check pattern,
+// like int(n). Integers of any size are int, and builtins as well as user
+// functions are function.
+func HasType(obj Object, typeName string) bool {
+	switch typeName {
+	case "int":
+		return obj.Type() == INT_OBJ || obj.Type() == BIGINT_OBJ
+	case "float":
+		return obj.Type() == FLOAT_OBJ
+	case "string":
+		return obj.Type() == STRING_OBJ
+	case "bool":
+		return obj.Type() == BOOL_OBJ
+	case "array":
+		return obj.Type() == ARRAY_OBJ
+	case "hash":
+		return obj.Type() == HASH_OBJ
+	case "function":
+		switch obj.Type() {
+		case FN_OBJ, BULTIN_OBJ, COMPILED_FN_OBJ, CLOSURE_OBJ:
+			return true
+		}
+	case "null":
+		return obj.Type() == NULL_OBJ
+	}
+
+	return false
+}
+
+// ArrayRest returns a new array of the elements of array from index on,
+// for the rest of an array pattern
+func ArrayRest(array *ArrayObject, index int) *ArrayObject {
+	elements := make([]Object, len(array.Elements)-index)
+	copy(elements, array.Elements[index:])
+
+	return &ArrayObject{Elements: elements}
+}
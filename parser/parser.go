@@ -9,24 +9,52 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN       // = or +=
+	LOGICAL_OR   // ||
+	LOGICAL_AND  // &&
 	EQUALS       // ==
 	LESS_GREATER // > or <
-	SUM          // +
-	PRODUCT      // *
+	SUM          // +, -, | or ^
+	PRODUCT      // *, /, %, &, << or >>
 	PREFIX       // -x or !x
 	CALL         // myFn(x)
 	INDEX        // array[index]
 )
 
 var precedenceMap = map[token.TokenType]int{
+	token.ASSIGN:             ASSIGN,
+	token.PLUS_ASSIGN:        ASSIGN,
+	token.MINUS_ASSIGN:       ASSIGN,
+	token.ASTERISK_ASSIGN:    ASSIGN,
+	token.SLASH_ASSIGN:       ASSIGN,
+	token.PERCENT_ASSIGN:     ASSIGN,
+	token.AMPERSAND_ASSIGN:   ASSIGN,
+	token.PIPE_ASSIGN:        ASSIGN,
+	token.CARET_ASSIGN:       ASSIGN,
+	token.SHIFT_LEFT_ASSIGN:  ASSIGN,
+	token.SHIFT_RIGHT_ASSIGN: ASSIGN,
+
+	token.OR:       LOGICAL_OR,
+	token.AND:      LOGICAL_AND,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESS_GREATER,
 	token.GT:       LESS_GREATER,
+	token.LT_EQ:    LESS_GREATER,
+	token.GT_EQ:    LESS_GREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
+
+	// like in Go, bitwise operators bind like the arithmetic ones
+	token.PIPE:        SUM,
+	token.CARET:       SUM,
+	token.AMPERSAND:   PRODUCT,
+	token.SHIFT_LEFT:  PRODUCT,
+	token.SHIFT_RIGHT: PRODUCT,
+
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
 }
@@ -37,8 +65,17 @@ type (
 )
 
 type Parser struct {
-	l      *lexer.Lexer
-	errors []string
+	l           *lexer.Lexer
+	diagnostics []Diagnostic
+	panicking   bool // set after an error until the parser has resynchronized
+	blockDepth  int  // number of enclosing block statements
+	loopDepth   int  // number of enclosing loops in the current function
+
+	// break and continue are only allowed where the value of no expression
+	// is used, the operands evaluated so far would be left behind
+	exprStatement bool          // the next expression is a whole expression statement
+	valueUsed     bool          // the value of the current expression is used
+	branches      []token.Token // break and continue in the current expression
 
 	curToken  token.Token
 	peekToken token.Token
@@ -49,20 +86,25 @@ type Parser struct {
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:           l,
+		diagnostics: []Diagnostic{},
 	}
 
 	p.prefixParseFnMap = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefixParserFn(token.IDENT, p.parseIdentifier)
 	p.registerPrefixParserFn(token.INT, p.parseIntegerLiteral)
+	p.registerPrefixParserFn(token.BIGINT, p.parseBigIntLiteral)
+	p.registerPrefixParserFn(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefixParserFn(token.STRING, p.parseStringLiteral)
+	p.registerPrefixParserFn(token.TEMPLATE_HEAD, p.parseInterpolatedString)
 	p.registerPrefixParserFn(token.BANG, p.parsePrefixExpression)
 	p.registerPrefixParserFn(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefixParserFn(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefixParserFn(token.TRUE, p.parseBooleanLiteral)
 	p.registerPrefixParserFn(token.FALSE, p.parseBooleanLiteral)
 	p.registerPrefixParserFn(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefixParserFn(token.IF, p.parseIfExpression)
+	p.registerPrefixParserFn(token.MATCH, p.parseMatchExpression)
 	p.registerPrefixParserFn(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefixParserFn(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefixParserFn(token.LBRACE, p.parseHashLiteral)
@@ -76,6 +118,24 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfixParserFn(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfixParserFn(token.LT, p.parseInfixExpression)
 	p.registerInfixParserFn(token.GT, p.parseInfixExpression)
+	p.registerInfixParserFn(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfixParserFn(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfixParserFn(token.PERCENT, p.parseInfixExpression)
+	p.registerInfixParserFn(token.AND, p.parseInfixExpression)
+	p.registerInfixParserFn(token.OR, p.parseInfixExpression)
+	p.registerInfixParserFn(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfixParserFn(token.PIPE, p.parseInfixExpression)
+	p.registerInfixParserFn(token.CARET, p.parseInfixExpression)
+	p.registerInfixParserFn(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfixParserFn(token.SHIFT_RIGHT, p.parseInfixExpression)
+
+	for _, assign := range []token.TokenType{
+		token.ASSIGN, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN,
+		token.SLASH_ASSIGN, token.PERCENT_ASSIGN, token.AMPERSAND_ASSIGN, token.PIPE_ASSIGN,
+		token.CARET_ASSIGN, token.SHIFT_LEFT_ASSIGN, token.SHIFT_RIGHT_ASSIGN,
+	} {
+		p.registerInfixParserFn(assign, p.parseAssignExpression)
+	}
 
 	p.registerInfixParserFn(token.LPAREN, p.parseCallExpression)
 	p.registerInfixParserFn(token.LBRACKET, p.parseIndexExpression)
@@ -93,7 +153,9 @@ func (p *Parser) ParseProgram() *ast.ProgramNode {
 
 	for !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.panicking {
+			p.synchronize()
+		} else {
 			program.StatementNodes = append(program.StatementNodes, stmt)
 		}
 		p.nextToken()
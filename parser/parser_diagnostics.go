@@ -0,0 +1,292 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+type DiagnosticCode string
+
+const (
+	CodeUnexpectedToken   DiagnosticCode = "P001" // a specific token was expected
+	CodeMissingExpression DiagnosticCode = "P002" // no expression can start with the token
+	CodeInvalidInteger    DiagnosticCode = "P003" // integer literal can not be parsed
+	CodeIllegalCharacter  DiagnosticCode = "P004" // the lexer did not recognize the input
+	CodeMalformedToken    DiagnosticCode = "P005" // the lexer found malformed input, like an unterminated comment
+	CodeInvalidFloat      DiagnosticCode = "P006" // float literal can not be parsed
+	CodeMisplacedBranch   DiagnosticCode = "P007" // break or continue outside of a loop, or inside an expression
+	CodeInvalidAssignment DiagnosticCode = "P008" // the left side of an assignment can not be assigned to
+	CodeInvalidPattern    DiagnosticCode = "P009" // a pattern is not supported, or binds names inconsistently
+	CodeInvalidParameter  DiagnosticCode = "P010" // a required parameter follows one with a default value
+	CodeInvalidArgument   DiagnosticCode = "P011" // a named argument is repeated or followed by a positional one
+)
+
+// Diagnostic A problem found while parsing the source
+type Diagnostic struct {
+	Severity Severity
+	Span     token.Span
+	Code     DiagnosticCode
+	Message  string
+
+	Expected []token.TokenType // tokens that would have been accepted, if known
+	Got      token.Token       // the offending token
+
+	Hint string // optional suggestion on how to fix the problem
+}
+
+func (d Diagnostic) Error() string {
+	return d.String()
+}
+
+func (d Diagnostic) String() string {
+	var out strings.Builder
+
+	fmt.Fprintf(&out, "%s: %s[%s]: %s", d.Span, d.Severity, d.Code, d.Message)
+
+	if d.Hint != "" {
+		fmt.Fprintf(&out, " (hint: %s)", d.Hint)
+	}
+
+	return out.String()
+}
+
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
+// Errors returns the messages of all error diagnostics
+func (p *Parser) Errors() []string {
+	errors := []string{}
+
+	for _, d := range p.diagnostics {
+		if d.Severity == SeverityError {
+			errors = append(errors, d.String())
+		}
+	}
+
+	return errors
+}
+
+// addError records an error, unless the parser is already recovering from
+// a previous one. Follow-up errors of a single mistake are mostly noise.
+func (p *Parser) addError(d Diagnostic) {
+	if p.panicking {
+		return
+	}
+
+	d.Severity = SeverityError
+	p.diagnostics = append(p.diagnostics, d)
+	p.panicking = true
+}
+
+// synchronize skips tokens until a likely statement boundary: a ';', the
+// '}' closing the enclosing block or the token before a 'let', 'return',
+// 'while' or 'for'.
+// Braces and parentheses opened while skipping are matched, so that the
+// rest of a broken construct, like a loop in expression position, is
+// skipped as a whole. The caller then advances past the current token as
+// usual.
+func (p *Parser) synchronize() {
+	p.panicking = false
+	depth := 0
+	parenDepth := 0
+
+	for {
+		switch p.curToken.Type {
+		case token.EOF:
+			return
+		case token.SEMICOLON:
+			if depth == 0 && parenDepth == 0 {
+				return
+			}
+		case token.LPAREN:
+			parenDepth++
+		case token.RPAREN:
+			if parenDepth > 0 {
+				parenDepth--
+			}
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			} else if p.blockDepth > 0 {
+				return
+			}
+		}
+
+		if depth == 0 && parenDepth == 0 {
+			switch p.peekToken.Type {
+			case token.LET, token.RETURN, token.WHILE, token.FOR, token.EOF:
+				return
+			case token.RBRACE:
+				if p.blockDepth > 0 {
+					return
+				}
+			}
+		}
+
+		p.nextToken()
+	}
+}
+
+func (p *Parser) peekError(t token.TokenType) {
+	if p.peekToken.Type == token.ILLEGAL {
+		p.illegalCharacterError(p.peekToken)
+		return
+	}
+
+	if p.peekToken.Type == token.ERROR {
+		p.malformedTokenError(p.peekToken)
+		return
+	}
+
+	p.addError(Diagnostic{
+		Span:     p.peekToken.Span(),
+		Code:     CodeUnexpectedToken,
+		Message:  fmt.Sprintf("expected %s, got %s", describeTokenType(t), describeToken(p.peekToken)),
+		Expected: []token.TokenType{t},
+		Got:      p.peekToken,
+		Hint:     expectHint(p.curToken, t),
+	})
+}
+
+func (p *Parser) noPrefixParserFnFound(t token.TokenType) {
+	if t == token.ILLEGAL {
+		p.illegalCharacterError(p.curToken)
+		return
+	}
+
+	if t == token.ERROR {
+		p.malformedTokenError(p.curToken)
+		return
+	}
+
+	p.addError(Diagnostic{
+		Span:    p.curToken.Span(),
+		Code:    CodeMissingExpression,
+		Message: fmt.Sprintf("expected an expression, got %s", describeToken(p.curToken)),
+		Got:     p.curToken,
+		Hint:    missingExpressionHint(p.curToken),
+	})
+}
+
+// illegalCharacterError reports an ILLEGAL token, a character that starts
+// no token
+func (p *Parser) illegalCharacterError(tk token.Token) {
+	p.addError(Diagnostic{
+		Span:    tk.Span(),
+		Code:    CodeIllegalCharacter,
+		Message: fmt.Sprintf("illegal character %q", tk.Literal),
+		Got:     tk,
+	})
+}
+
+// malformedTokenError reports an ERROR token, which carries the message
+// of the lexer as its literal
+func (p *Parser) malformedTokenError(tk token.Token) {
+	p.addError(Diagnostic{
+		Span:    tk.Span(),
+		Code:    CodeMalformedToken,
+		Message: tk.Literal,
+		Got:     tk,
+	})
+}
+
+func describeTokenType(t token.TokenType) string {
+	switch t {
+	case token.EOF:
+		return "end of input"
+	case token.IDENT:
+		return "identifier"
+	case token.INT, token.BIGINT:
+		return "integer"
+	case token.FLOAT:
+		return "float"
+	case token.STRING:
+		return "string"
+	case token.TEMPLATE_MIDDLE, token.TEMPLATE_TAIL:
+		return "'}'"
+	case token.TEMPLATE_HEAD:
+		return "string"
+	default:
+		if token.CheckIsKeyword(strings.ToLower(string(t))) == t {
+			return fmt.Sprintf("'%s'", strings.ToLower(string(t)))
+		}
+		return fmt.Sprintf("'%s'", t)
+	}
+}
+
+func describeToken(tk token.Token) string {
+	switch tk.Type {
+	case token.IDENT, token.INT, token.BIGINT, token.FLOAT:
+		return fmt.Sprintf("%s %s", describeTokenType(tk.Type), tk.Literal)
+	case token.STRING:
+		return fmt.Sprintf("%s %q", describeTokenType(tk.Type), tk.Literal)
+	default:
+		return describeTokenType(tk.Type)
+	}
+}
+
+func expectHint(cur token.Token, expected token.TokenType) string {
+	switch expected {
+	case token.RPAREN:
+		return "check for a missing ')'"
+	case token.RBRACKET:
+		return "check for a missing ']'"
+	case token.RBRACE:
+		return "check for a missing '}'"
+	case token.LBRACE:
+		return "blocks and function bodies are wrapped in '{' and '}'"
+	case token.IDENT:
+		if cur.Type == token.LET {
+			return "'let' must be followed by a variable name"
+		}
+	case token.ASSIGN:
+		if cur.Type == token.IDENT {
+			return "a let statement looks like 'let name = value;'"
+		}
+	case token.COLON:
+		return "hash entries look like 'key: value'"
+	case token.TEMPLATE_TAIL:
+		return "an interpolation holds one expression, like \"${a + b}\""
+	}
+
+	return ""
+}
+
+func missingExpressionHint(tk token.Token) string {
+	switch tk.Type {
+	case token.EOF:
+		return "the input ended before the expression was complete"
+	case token.RPAREN, token.RBRACKET, token.RBRACE:
+		return fmt.Sprintf("check for an unbalanced or misplaced '%s'", tk.Literal)
+	case token.ASSIGN:
+		return "use '==' to compare values, 'let' to define a variable"
+	case token.SEMICOLON:
+		return "an expression is missing before ';'"
+	case token.TEMPLATE_MIDDLE, token.TEMPLATE_TAIL:
+		return "an interpolation needs an expression between '${' and '}'"
+	}
+
+	return ""
+}
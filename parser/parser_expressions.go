@@ -6,12 +6,22 @@ import (
 )
 
 func (p *Parser) parseExpression(precedence int) ast.ExpressionNode {
+	valueUsed := p.valueUsed
+	defer func() { p.valueUsed = valueUsed }()
+	p.valueUsed = valueUsed || !p.exprStatement
+	p.exprStatement = false
+	numBranches := len(p.branches)
+
 	prefixParseFn := p.prefixParseFnMap[p.curToken.Type]
 	if prefixParseFn == nil {
 		p.noPrefixParserFnFound(p.curToken.Type)
 		return nil
 	}
 	leftExr := prefixParseFn()
+	if leftExr == nil {
+		// the error is reported, operators after it would only add more
+		return nil
+	}
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infixParseFn := p.infixParseFnMap[p.peekToken.Type]
@@ -19,6 +29,14 @@ func (p *Parser) parseExpression(precedence int) ast.ExpressionNode {
 			return leftExr
 		}
 
+		if len(p.branches) > numBranches {
+			// an if or match holding a break is the operand of an operator
+			p.misplacedBranch(p.branches[numBranches])
+			p.nextToken()
+			return nil
+		}
+		p.valueUsed = true
+
 		p.nextToken()
 		leftExr = infixParseFn(leftExr)
 	}
@@ -1,10 +1,14 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 func (p *Parser) parseIdentifier() ast.ExpressionNode {
@@ -14,10 +18,28 @@ func (p *Parser) parseIdentifier() ast.ExpressionNode {
 func (p *Parser) parseIntegerLiteral() ast.ExpressionNode {
 	il := &ast.IntegerLiteralNode{Token: p.curToken}
 
+	if p.hasLeadingZero() {
+		return nil
+	}
+
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		p.addError(Diagnostic{
+			Span:    p.curToken.Span(),
+			Code:    CodeInvalidInteger,
+			Message: fmt.Sprintf("integer literal %s overflows 64 bit integers", p.curToken.Literal),
+			Got:     p.curToken,
+			Hint:    fmt.Sprintf("the largest integer is %d, write %sn for a big integer", int64(math.MaxInt64), p.curToken.Literal),
+		})
+		return nil
+	}
 	if err != nil {
-		msg := fmt.Sprintf("Could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(Diagnostic{
+			Span:    p.curToken.Span(),
+			Code:    CodeInvalidInteger,
+			Message: fmt.Sprintf("could not parse %q as integer", p.curToken.Literal),
+			Got:     p.curToken,
+		})
 		return nil
 	}
 	il.Value = value
@@ -25,10 +47,114 @@ func (p *Parser) parseIntegerLiteral() ast.ExpressionNode {
 	return il
 }
 
+func (p *Parser) parseBigIntLiteral() ast.ExpressionNode {
+	bl := &ast.BigIntLiteralNode{Token: p.curToken}
+
+	if p.hasLeadingZero() {
+		return nil
+	}
+
+	value, ok := new(big.Int).SetString(strings.TrimSuffix(p.curToken.Literal, "n"), 0)
+	if !ok {
+		p.addError(Diagnostic{
+			Span:    p.curToken.Span(),
+			Code:    CodeInvalidInteger,
+			Message: fmt.Sprintf("could not parse %q as integer", p.curToken.Literal),
+			Got:     p.curToken,
+		})
+		return nil
+	}
+	bl.Value = value
+
+	return bl
+}
+
+// hasLeadingZero reports decimal integer literals like 0755, which some
+// languages read as octal. They are rejected rather than guessed at.
+func (p *Parser) hasLeadingZero() bool {
+	literal := p.curToken.Literal
+	suffix := ""
+	if strings.HasSuffix(literal, "n") {
+		literal, suffix = strings.TrimSuffix(literal, "n"), "n"
+	}
+
+	if len(literal) < 2 || literal[0] != '0' || !isDecimalDigit(literal[1]) && literal[1] != '_' {
+		return false
+	}
+
+	decimal := strings.TrimLeft(literal, "0_")
+	if decimal == "" {
+		decimal = "0"
+	}
+
+	p.addError(Diagnostic{
+		Span:    p.curToken.Span(),
+		Code:    CodeInvalidInteger,
+		Message: fmt.Sprintf("integer literal %s has a leading zero", p.curToken.Literal),
+		Got:     p.curToken,
+		Hint:    fmt.Sprintf("write 0o%s%s for an octal integer or %s%s for a decimal one", literal[1:], suffix, decimal, suffix),
+	})
+
+	return true
+}
+
+func isDecimalDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func (p *Parser) parseFloatLiteral() ast.ExpressionNode {
+	fl := &ast.FloatLiteralNode{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(Diagnostic{
+			Span:    p.curToken.Span(),
+			Code:    CodeInvalidFloat,
+			Message: fmt.Sprintf("could not parse %q as float", p.curToken.Literal),
+			Got:     p.curToken,
+			Hint:    "the value is out of the range of 64 bit floats",
+		})
+		return nil
+	}
+	fl.Value = value
+
+	return fl
+}
+
 func (p *Parser) parseStringLiteral() ast.ExpressionNode {
 	return &ast.StringLiteralNode{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.ExpressionNode {
+	str := &ast.InterpolatedStringNode{Token: p.curToken}
+
+	for {
+		if p.curToken.Literal != "" {
+			str.Parts = append(str.Parts, &ast.StringLiteralNode{Token: p.curToken, Value: p.curToken.Literal})
+		}
+
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if p.peekTokenIs(token.TEMPLATE_MIDDLE) {
+			p.nextToken()
+			continue
+		}
+
+		if !p.expectPeek(token.TEMPLATE_TAIL) {
+			return nil
+		}
+		break
+	}
+
+	if p.curToken.Literal != "" {
+		str.Parts = append(str.Parts, &ast.StringLiteralNode{Token: p.curToken, Value: p.curToken.Literal})
+	}
+	str.EndToken = p.curToken
+
+	return str
+}
+
 func (p *Parser) parseBooleanLiteral() ast.ExpressionNode {
 	return &ast.BooleanNode{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
@@ -60,6 +186,38 @@ func (p *Parser) parseInfixExpression(leftNode ast.ExpressionNode) ast.Expressio
 	return expr
 }
 
+// parseAssignExpression parses an assignment to an identifier or an index
+// expression. Assignments are right associative, a = b = 1 assigns 1 to b
+// and then to a.
+func (p *Parser) parseAssignExpression(target ast.ExpressionNode) ast.ExpressionNode {
+	switch target.(type) {
+	case *ast.IdentifierNode, *ast.IndexExpressionNode:
+	default:
+		p.addError(Diagnostic{
+			Span:    token.Span{Start: target.Pos(), End: target.End()},
+			Code:    CodeInvalidAssignment,
+			Message: fmt.Sprintf("cannot assign to %s", target.String()),
+			Got:     p.curToken,
+			Hint:    "only variables and index expressions can be assigned to",
+		})
+		return nil
+	}
+
+	expr := &ast.AssignExpressionNode{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+	expr.ValueNode = p.parseExpression(ASSIGN - 1)
+	if expr.ValueNode == nil {
+		return nil
+	}
+
+	return expr
+}
+
 func (p *Parser) parseGroupedExpression() ast.ExpressionNode {
 	p.nextToken()
 
@@ -95,6 +253,24 @@ func (p *Parser) parseIfExpression() ast.ExpressionNode {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+			// else if, the alternative is a block holding the next if
+			p.nextToken()
+			ifToken := p.curToken
+
+			next := p.parseIfExpression()
+			if next == nil {
+				return nil
+			}
+
+			expr.AlternativeNode = &ast.BlockStatementNode{
+				Token:          ifToken,
+				StatementNodes: []ast.StatementNode{&ast.ExpressionStatementNode{Token: ifToken, ExpressionNode: next}},
+				EndToken:       p.curToken,
+			}
+			return expr
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -105,59 +281,466 @@ func (p *Parser) parseIfExpression() ast.ExpressionNode {
 	return expr
 }
 
-func (p *Parser) parseFunctionLiteral() ast.ExpressionNode {
-	lit := &ast.FunctionLiteralNode{Token: p.curToken}
+// parseMatchExpression parses match (subject) { patterns => body, ... },
+// the arms are separated by commas, which are optional after a block
+func (p *Parser) parseMatchExpression() ast.ExpressionNode {
+	expr := &ast.MatchExpressionNode{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 
-	lit.ParamNodes = p.parseFunctionParameters()
+	p.nextToken()
+	expr.SubjectNode = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
-	lit.BodyNode = p.parseBlockStatement()
+	for !p.peekTokenIs(token.RBRACE) {
+		if p.peekTokenIs(token.EOF) {
+			p.expectPeek(token.RBRACE)
+			return nil
+		}
 
-	return lit
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expr.Arms = append(expr.Arms, arm)
+
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		} else if !p.curTokenIs(token.RBRACE) && !p.peekTokenIs(token.RBRACE) {
+			p.expectPeek(token.COMMA)
+			return nil
+		}
+	}
+
+	p.nextToken()
+	expr.EndToken = p.curToken
+
+	return expr
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.IdentifierNode {
-	identifiers := []*ast.IdentifierNode{}
+func (p *Parser) parseMatchArm() *ast.MatchArmNode {
+	arm := &ast.MatchArmNode{}
 
-	if p.peekTokenIs(token.RPAREN) {
+	for {
+		pattern := p.parsePattern()
+		if pattern == nil {
+			return nil
+		}
+		arm.Patterns = append(arm.Patterns, pattern)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		return identifiers
+		p.nextToken()
+	}
+
+	if !p.checkPatternBindings(arm.Patterns) {
+		return nil
 	}
 
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.GuardNode = p.parseExpression(LOWEST)
+		if arm.GuardNode == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	arm.Token = p.curToken
+
 	p.nextToken()
+	if p.curTokenIs(token.LBRACE) {
+		arm.BodyNode = p.parseBlockStatement()
+		return arm
+	}
+
+	bodyToken := p.curToken
+	p.exprStatement = true
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		return nil
+	}
 
-	ident := &ast.IdentifierNode{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	arm.BodyNode = &ast.BlockStatementNode{
+		Token:          bodyToken,
+		StatementNodes: []ast.StatementNode{&ast.ExpressionStatementNode{Token: bodyToken, ExpressionNode: value}},
+		EndToken:       p.curToken,
+	}
 
-	for p.peekTokenIs(token.COMMA) {
+	return arm
+}
+
+// checkPatternBindings reports a pattern that binds a name twice, or patterns
+// of the same arm that bind different names, the arm body could not tell
+// which of them are bound
+func (p *Parser) checkPatternBindings(patterns []ast.ExpressionNode) bool {
+	var first []string
+
+	for i, pattern := range patterns {
+		names := ast.PatternBindings(pattern)
+
+		seen := map[string]bool{}
+		for _, name := range names {
+			if seen[name] {
+				p.invalidPattern(pattern, fmt.Sprintf("%s is bound twice in %s", name, pattern.String()))
+				return false
+			}
+			seen[name] = true
+		}
+
+		if i == 0 {
+			first = names
+			continue
+		}
+
+		same := len(names) == len(first)
+		for _, name := range first {
+			same = same && seen[name]
+		}
+		if !same {
+			p.invalidPattern(pattern, fmt.Sprintf("%s must bind the same names as %s", pattern.String(), patterns[0].String()))
+			return false
+		}
+	}
+
+	return true
+}
+
+// patternTypes are the type names of type check patterns, like int(n)
+var patternTypes = map[string]bool{
+	"int":      true,
+	"float":    true,
+	"string":   true,
+	"bool":     true,
+	"array":    true,
+	"hash":     true,
+	"function": true,
+	"null":     true,
+}
+
+// parsePattern parses the pattern of a match arm or a let: a literal, a
+// name to bind, _ to match any value, an array pattern like [a, ...rest],
+// a hash pattern like {"name": n} or a type check like int(n)
+func (p *Parser) parsePattern() ast.ExpressionNode {
+	switch p.curToken.Type {
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	case token.IDENT:
+		if p.peekTokenIs(token.LPAREN) {
+			return p.parseTypePattern()
+		}
+		return &ast.IdentifierNode{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	return p.parseLiteralPattern()
+}
+
+func (p *Parser) parseArrayPattern() ast.ExpressionNode {
+	pattern := &ast.ArrayPatternNode{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			// the rest is always last
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.IdentifierNode{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	pattern.EndToken = p.curToken
+
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.ExpressionNode {
+	pattern := &ast.HashPatternNode{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
+
+		key := p.parseLiteralPattern()
+		if key == nil {
+			return nil
+		}
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
 		p.nextToken()
-		ident := &ast.IdentifierNode{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		value := p.parsePattern()
+		if value == nil {
+			return nil
+		}
+
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	pattern.EndToken = p.curToken
+
+	return pattern
+}
+
+func (p *Parser) parseTypePattern() ast.ExpressionNode {
+	pattern := &ast.TypePatternNode{Token: p.curToken, TypeName: p.curToken.Literal}
+
+	if !patternTypes[pattern.TypeName] {
+		p.addError(Diagnostic{
+			Span:    p.curToken.Span(),
+			Code:    CodeInvalidPattern,
+			Message: fmt.Sprintf("%s is not a type", pattern.TypeName),
+			Got:     p.curToken,
+			Hint:    "types are int, float, string, bool, array, hash, function and null",
+		})
+		return nil
+	}
+
+	p.nextToken()
+	p.nextToken()
+	pattern.PatternNode = p.parsePattern()
+	if pattern.PatternNode == nil {
+		return nil
 	}
 
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
+	pattern.EndToken = p.curToken
+
+	return pattern
+}
+
+// parseLiteralPattern parses a literal, the numbers may be negative
+func (p *Parser) parseLiteralPattern() ast.ExpressionNode {
+	pattern := p.parseExpression(PREFIX)
+	if pattern == nil {
+		return nil
+	}
+
+	if !isLiteralPattern(pattern) {
+		p.invalidPattern(pattern, fmt.Sprintf("%s is not a valid pattern", pattern.String()))
+		return nil
+	}
+
+	return pattern
+}
 
-	return identifiers
+func (p *Parser) invalidPattern(pattern ast.ExpressionNode, message string) {
+	p.addError(Diagnostic{
+		Span:    token.Span{Start: pattern.Pos(), End: pattern.End()},
+		Code:    CodeInvalidPattern,
+		Message: message,
+		Got:     p.curToken,
+		Hint:    "patterns are literals, names to bind, _, [a, ...rest], {\"key\": value} or type(pattern)",
+	})
+}
+
+func isLiteralPattern(pattern ast.ExpressionNode) bool {
+	switch pattern := pattern.(type) {
+	case *ast.IntegerLiteralNode, *ast.BigIntLiteralNode, *ast.FloatLiteralNode,
+		*ast.StringLiteralNode, *ast.BooleanNode:
+		return true
+	case *ast.PrefixExpressionNode:
+		switch pattern.RightNode.(type) {
+		case *ast.IntegerLiteralNode, *ast.BigIntLiteralNode, *ast.FloatLiteralNode:
+			return pattern.Operator == "-"
+		}
+	}
+
+	return false
+}
+
+func (p *Parser) parseFunctionLiteral() ast.ExpressionNode {
+	lit := &ast.FunctionLiteralNode{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	// break and continue can not jump out of the function
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	lit.BodyNode = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+
+	return lit
+}
+
+// parseFunctionParameters parses the parameters of lit up to the closing
+// ')'. Parameters are names, optionally followed by '= default', and a
+// last '...rest' parameter.
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteralNode) bool {
+	lit.ParamNodes = []*ast.IdentifierNode{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return true
+	}
+
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			lit.RestNode = &ast.IdentifierNode{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		ident := &ast.IdentifierNode{Token: p.curToken, Value: p.curToken.Literal}
+		lit.ParamNodes = append(lit.ParamNodes, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			defaultNode := p.parseExpression(LOWEST)
+			if defaultNode == nil {
+				return false
+			}
+			lit.DefaultNodes = append(lit.DefaultNodes, defaultNode)
+		} else if len(lit.DefaultNodes) > 0 {
+			p.addError(Diagnostic{
+				Span:    ident.Token.Span(),
+				Code:    CodeInvalidParameter,
+				Message: fmt.Sprintf("parameter %s needs a default value, it follows a parameter with one", ident.Value),
+				Got:     ident.Token,
+				Hint:    "move the parameters with default values to the end",
+			})
+			return false
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseCallExpression(fnNode ast.ExpressionNode) ast.ExpressionNode {
 	expr := &ast.CallExpressionNode{Token: p.curToken, FnNode: fnNode}
 
-	expr.ArgNodes = p.parseExpressionList(token.RPAREN)
+	expr.ArgNodes = p.parseCallArguments()
+	expr.EndToken = p.curToken
 
 	return expr
 }
 
+// parseCallArguments parses the arguments of a call up to the closing ')',
+// any of them may be spread with '...'. Arguments passed by name come last.
+func (p *Parser) parseCallArguments() []ast.ExpressionNode {
+	argNodes := []ast.ExpressionNode{}
+	named := map[string]bool{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return argNodes
+	}
+
+	for {
+		p.nextToken()
+		switch {
+		case p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON):
+			arg := &ast.NamedArgumentNode{Token: p.curToken, Name: &ast.IdentifierNode{Token: p.curToken, Value: p.curToken.Literal}}
+			if named[arg.Name.Value] {
+				p.invalidArgument(arg.Token, fmt.Sprintf("argument %s is passed twice", arg.Name.Value), "")
+				return nil
+			}
+			named[arg.Name.Value] = true
+
+			p.nextToken()
+			p.nextToken()
+			arg.ValueNode = p.parseExpression(LOWEST)
+			argNodes = append(argNodes, arg)
+		case len(named) > 0:
+			p.invalidArgument(p.curToken, "positional argument after a named argument", "pass the named arguments last")
+			return nil
+		case p.curTokenIs(token.ELLIPSIS):
+			spread := &ast.SpreadNode{Token: p.curToken}
+			p.nextToken()
+			spread.ValueNode = p.parseExpression(LOWEST)
+			argNodes = append(argNodes, spread)
+		default:
+			argNodes = append(argNodes, p.parseExpression(LOWEST))
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return argNodes
+}
+
+func (p *Parser) invalidArgument(tk token.Token, message, hint string) {
+	p.addError(Diagnostic{
+		Span:    tk.Span(),
+		Code:    CodeInvalidArgument,
+		Message: message,
+		Got:     tk,
+		Hint:    hint,
+	})
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.ExpressionNode {
 	exprNodes := []ast.ExpressionNode{}
 
@@ -186,6 +769,7 @@ func (p *Parser) parseArrayLiteral() ast.ExpressionNode {
 	array := &ast.ArrayLiteralNode{Token: p.curToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.EndToken = p.curToken
 
 	return array
 }
@@ -215,6 +799,7 @@ func (p *Parser) parseHashLiteral() ast.ExpressionNode {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.EndToken = p.curToken
 
 	return hash
 }
@@ -228,6 +813,7 @@ func (p *Parser) parseIndexExpression(left ast.ExpressionNode) ast.ExpressionNod
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	expr.EndToken = p.curToken
 
 	return expr
 }
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"monkey/ast"
 	"monkey/token"
 )
@@ -11,6 +12,12 @@ func (p *Parser) parseStatement() ast.StatementNode {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseBranchStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -19,12 +26,19 @@ func (p *Parser) parseStatement() ast.StatementNode {
 func (p *Parser) parseLetStatement() *ast.LetStatementNode {
 	stmt := &ast.LetStatementNode{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.PatternNode = p.parsePattern()
+		if stmt.PatternNode == nil || !p.checkPatternBindings([]ast.ExpressionNode{stmt.PatternNode}) {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.NameNode = ast.IdentifierNode{Token: p.curToken, Value: p.curToken.Literal}
 	}
 
-	stmt.NameNode = ast.IdentifierNode{Token: p.curToken, Value: p.curToken.Literal}
-
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -33,7 +47,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatementNode {
 
 	stmt.ValueNode = p.parseExpression(LOWEST)
 
-	if fl, ok := stmt.ValueNode.(*ast.FunctionLiteralNode); ok {
+	if fl, ok := stmt.ValueNode.(*ast.FunctionLiteralNode); ok && stmt.PatternNode == nil {
 		fl.Name = stmt.NameNode.Value
 	}
 
@@ -58,9 +72,178 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatementNode {
 	return stmt
 }
 
+func (p *Parser) parseWhileStatement() ast.StatementNode {
+	stmt := &ast.WhileStatementNode{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.ConditionNode = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	stmt.BodyNode = p.parseLoopBody()
+	if stmt.BodyNode == nil {
+		return nil
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.StatementNode {
+	stmt := &ast.ForStatementNode{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.parseForClauses(stmt) {
+		// the ';' between the clauses do not end the statement, so the
+		// clauses are skipped before resynchronizing
+		p.skipToClosingParen()
+		return nil
+	}
+
+	stmt.BodyNode = p.parseLoopBody()
+	if stmt.BodyNode == nil {
+		return nil
+	}
+
+	return stmt
+}
+
+// parseForClauses parses the init, condition and update clauses of a for
+// loop, up to the closing ')'
+func (p *Parser) parseForClauses(stmt *ast.ForStatementNode) bool {
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		stmt.InitNode = p.parseSimpleStatement()
+		if p.panicking {
+			return false
+		}
+	}
+	// a let or expression statement has already consumed its ';'
+	if !p.curTokenIs(token.SEMICOLON) && !p.expectPeek(token.SEMICOLON) {
+		return false
+	}
+
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		stmt.ConditionNode = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return false
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		stmt.UpdateNode = p.parseSimpleStatement()
+		if p.panicking {
+			return false
+		}
+	}
+
+	return p.expectPeek(token.RPAREN)
+}
+
+// skipToClosingParen skips tokens up to the ')' closing the current
+// parenthesis
+func (p *Parser) skipToClosingParen() {
+	depth := 0
+
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+		p.nextToken()
+	}
+}
+
+// parseSimpleStatement parses the init and update clauses of a for loop
+func (p *Parser) parseSimpleStatement() ast.StatementNode {
+	if p.curTokenIs(token.LET) {
+		return p.parseLetStatement()
+	}
+	return p.parseExpressionStatement()
+}
+
+// misplacedBranch reports a break or continue inside of an expression whose
+// value is used, like an operand or the value of a let
+func (p *Parser) misplacedBranch(tk token.Token) {
+	p.addError(Diagnostic{
+		Span:    tk.Span(),
+		Code:    CodeMisplacedBranch,
+		Message: fmt.Sprintf("%s can not be used inside an expression", tk.Literal),
+		Got:     tk,
+		Hint:    "use an if statement around the " + tk.Literal,
+	})
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatementNode {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	// the loop jumps from a statement of its body
+	valueUsed, branches := p.valueUsed, p.branches
+	p.loopDepth++
+	p.valueUsed, p.branches = false, nil
+	body := p.parseBlockStatement()
+	p.loopDepth--
+	p.valueUsed, p.branches = valueUsed, branches
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return body
+}
+
+// parseBranchStatement parses break and continue, which are only allowed
+// inside of a loop and not inside an expression whose value is used
+func (p *Parser) parseBranchStatement() ast.StatementNode {
+	tk := p.curToken
+
+	if p.loopDepth == 0 {
+		p.addError(Diagnostic{
+			Span:    tk.Span(),
+			Code:    CodeMisplacedBranch,
+			Message: fmt.Sprintf("%s is not inside a loop", tk.Literal),
+			Got:     tk,
+		})
+		return nil
+	}
+
+	if p.valueUsed {
+		p.misplacedBranch(tk)
+		return nil
+	}
+	p.branches = append(p.branches, tk)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if tk.Type == token.BREAK {
+		return &ast.BreakStatementNode{Token: tk}
+	}
+	return &ast.ContinueStatementNode{Token: tk}
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatementNode {
 	stmt := &ast.ExpressionStatementNode{Token: p.curToken}
 
+	p.exprStatement = true
 	stmt.ExpressionNode = p.parseExpression(LOWEST)
 
 	if p.peekTokenIs(token.SEMICOLON) {
@@ -74,13 +257,25 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatementNode {
 	block := &ast.BlockStatementNode{Token: p.curToken}
 	block.StatementNodes = []ast.StatementNode{}
 
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		statement := p.parseStatement()
-		block.StatementNodes = append(block.StatementNodes, statement)
+		if p.panicking {
+			p.synchronize()
+			if p.curTokenIs(token.RBRACE) {
+				break
+			}
+		} else {
+			block.StatementNodes = append(block.StatementNodes, statement)
+		}
 		p.nextToken()
 	}
 
+	block.EndToken = p.curToken
+
 	return block
 }
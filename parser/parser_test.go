@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -108,6 +110,97 @@ func TestIdentifierExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.25;", 3.25},
+		{"1.5e-3;", 0.0015},
+		{"2e3;", 2000},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.StatementNodes[0].(*ast.ExpressionStatementNode)
+		literal, ok := stmt.ExpressionNode.(*ast.FloatLiteralNode)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteralNode. got=%T", stmt.ExpressionNode)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0o755", 493},
+		{"0b1010", 10},
+		{"0", 0},
+		{"1_000_000", 1000000},
+		{"0x_7FFF_FFFF_FFFF_FFFF", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.StatementNodes[0].(*ast.ExpressionStatementNode)
+		literal, ok := stmt.ExpressionNode.(*ast.IntegerLiteralNode)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.ExpressionNode)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("%s - literal.Value not %d. got=%d", tt.input, tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %s. got=%s", tt.input, literal.String())
+		}
+	}
+}
+
+func TestBigIntLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5n", "5"},
+		{"99999999999999999999n", "99999999999999999999"},
+		{"0x8000_0000_0000_0000n", "9223372036854775808"},
+		{"0b1n", "1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.StatementNodes[0].(*ast.ExpressionStatementNode)
+		literal, ok := stmt.ExpressionNode.(*ast.BigIntLiteralNode)
+		if !ok {
+			t.Fatalf("exp not *ast.BigIntLiteralNode. got=%T", stmt.ExpressionNode)
+		}
+		if literal.Value.String() != tt.expected {
+			t.Errorf("%s - literal.Value not %s. got=%s", tt.input, tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %s. got=%s", tt.input, literal.String())
+		}
+	}
+}
+
 func TestIntegerLiteralExpression(t *testing.T) {
 	input := "5;"
 
@@ -349,6 +442,50 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c || d",
+			"((a || (b && c)) || d)",
+		},
+		{
+			"a == b && c < d || !e",
+			"(((a == b) && (c < d)) || (!e))",
+		},
+		{
+			"a | b & c ^ d",
+			"((a | (b & c)) ^ d)",
+		},
+		{
+			"1 + 2 << 3 * 4",
+			"(1 + ((2 << 3) * 4))",
+		},
+		{
+			"~a & b >> 1 == c",
+			"((((~a) & b) >> 1) == c)",
+		},
+		{
+			"a & b && c | d",
+			"((a & b) && (c | d))",
+		},
+		{
+			"x = y = a || b",
+			"(x = (y = (a || b)))",
+		},
+		{
+			"a[i + 1] += b * c",
+			"((a[(i + 1)]) += (b * c))",
+		},
+		{
+			"x <<= 1 + f(y = 2)",
+			"(x <<= (1 + f((y = 2))))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -401,6 +538,124 @@ func TestBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x; break; continue }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.StatementNodes) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.StatementNodes))
+	}
+
+	stmt, ok := program.StatementNodes[0].(*ast.WhileStatementNode)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatementNode. got=%T",
+			program.StatementNodes[0])
+	}
+
+	if !testInfixExpression(t, stmt.ConditionNode, "x", "<", "y") {
+		return
+	}
+
+	body := stmt.BodyNode.StatementNodes
+	if len(body) != 3 {
+		t.Fatalf("body is not 3 statements. got=%d\n", len(body))
+	}
+	if _, ok := body[1].(*ast.BreakStatementNode); !ok {
+		t.Errorf("body[1] is not ast.BreakStatementNode. got=%T", body[1])
+	}
+	if _, ok := body[2].(*ast.ContinueStatementNode); !ok {
+		t.Errorf("body[2] is not ast.ContinueStatementNode. got=%T", body[2])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0; i < 10; let i = i + 1) { puts(i) }", "for (let i = 0; (i < 10); let i = (i + 1)) puts(i)"},
+		{"for (;;) { break }", "for (; ; ) break;"},
+		{"for (init(); ; step()) { }; x", "for (init(); ; step()) x"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if _, ok := program.StatementNodes[0].(*ast.ForStatementNode); !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatementNode. got=%T",
+				program.StatementNodes[0])
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	l := lexer.New("for (let i = 0; i < n; let i = i + 1) { }")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.StatementNodes[0].(*ast.ForStatementNode)
+	if !testLetStatement(t, stmt.InitNode, "i") {
+		return
+	}
+	if !testInfixExpression(t, stmt.ConditionNode, "i", "<", "n") {
+		return
+	}
+	testLetStatement(t, stmt.UpdateNode, "i")
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedTarget   string
+		expectedOperator string
+		expectedValue    interface{}
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"x += y;", "x", "+=", "y"},
+		{"x >>= 2", "x", ">>=", 2},
+		{"a[0] = true;", "(a[0])", "=", true},
+		{`h["k"] %= 3;`, "(h[k])", "%=", 3},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.StatementNodes) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+				1, len(program.StatementNodes))
+		}
+
+		stmt := program.StatementNodes[0].(*ast.ExpressionStatementNode)
+		expr, ok := stmt.ExpressionNode.(*ast.AssignExpressionNode)
+		if !ok {
+			t.Fatalf("stmt is not ast.AssignExpressionNode. got=%T", stmt.ExpressionNode)
+		}
+
+		if expr.Target.String() != tt.expectedTarget {
+			t.Errorf("target is not %q. got=%q", tt.expectedTarget, expr.Target.String())
+		}
+		if expr.Operator != tt.expectedOperator {
+			t.Errorf("operator is not %q. got=%q", tt.expectedOperator, expr.Operator)
+		}
+		if !testLiteralExpression(t, expr.ValueNode, tt.expectedValue) {
+			return
+		}
+	}
+}
+
 func TestIfExpression(t *testing.T) {
 	input := `if (x < y) { x }`
 
@@ -450,6 +705,188 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (a) { 1 } else if (b) { 2 } else { 3 }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.StatementNodes[0].(*ast.ExpressionStatementNode)
+	exp, ok := stmt.ExpressionNode.(*ast.IfExpressionNode)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.ExpressionNode)
+	}
+
+	if len(exp.AlternativeNode.StatementNodes) != 1 {
+		t.Fatalf("alternative is not 1 statement. got=%d", len(exp.AlternativeNode.StatementNodes))
+	}
+
+	alternative := exp.AlternativeNode.StatementNodes[0].(*ast.ExpressionStatementNode)
+	next, ok := alternative.ExpressionNode.(*ast.IfExpressionNode)
+	if !ok {
+		t.Fatalf("alternative is not ast.IfExpression. got=%T", alternative.ExpressionNode)
+	}
+
+	if !testIdentifier(t, next.ConditionNode, "b") {
+		return
+	}
+	if next.AlternativeNode == nil || next.AlternativeNode.String() != "3" {
+		t.Errorf("wrong final alternative. got=%v", next.AlternativeNode)
+	}
+	if exp.End().String() != "1:42" {
+		t.Errorf("wrong end. want=1:42, got=%s", exp.End())
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (x) { 1, -2 => a, "s" => { b; c } true => d, _ => e }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.StatementNodes[0].(*ast.ExpressionStatementNode)
+	exp, ok := stmt.ExpressionNode.(*ast.MatchExpressionNode)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpressionNode. got=%T", stmt.ExpressionNode)
+	}
+
+	if !testIdentifier(t, exp.SubjectNode, "x") {
+		return
+	}
+
+	expected := []struct {
+		patterns []string
+		body     string
+	}{
+		{[]string{"1", "(-2)"}, "a"},
+		{[]string{`s`}, "bc"},
+		{[]string{"true"}, "d"},
+		{[]string{"_"}, "e"},
+	}
+
+	if len(exp.Arms) != len(expected) {
+		t.Fatalf("wrong number of arms. want=%d, got=%d", len(expected), len(exp.Arms))
+	}
+
+	for i, arm := range exp.Arms {
+		if len(arm.Patterns) != len(expected[i].patterns) {
+			t.Fatalf("arms[%d] - wrong number of patterns. want=%d, got=%d",
+				i, len(expected[i].patterns), len(arm.Patterns))
+		}
+		for j, pattern := range arm.Patterns {
+			if pattern.String() != expected[i].patterns[j] {
+				t.Errorf("arms[%d].Patterns[%d] - want=%q, got=%q",
+					i, j, expected[i].patterns[j], pattern.String())
+			}
+		}
+		if arm.BodyNode.String() != expected[i].body {
+			t.Errorf("arms[%d] - wrong body. want=%q, got=%q", i, expected[i].body, arm.BodyNode.String())
+		}
+	}
+
+	if !ast.IsWildcard(exp.Arms[3].Patterns[0]) {
+		t.Errorf("last pattern is not a wildcard")
+	}
+	if exp.End().String() != "1:60" {
+		t.Errorf("wrong end. want=1:60, got=%s", exp.End())
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		bindings []string
+	}{
+		{"x", "x", []string{"x"}},
+		{"_", "_", []string{}},
+		{"-1.5", "(-1.5)", []string{}},
+		{"[]", "[]", []string{}},
+		{"[a, [b, _], ...rest]", "[a, [b, _], ...rest]", []string{"a", "b", "rest"}},
+		{"[a, ..._]", "[a, ..._]", []string{"a"}},
+		{`{"name": n, 1: [x], true: _}`, "{name: n, 1: [x], true: _}", []string{"n", "x"}},
+		{"{}", "{}", []string{}},
+		{"int(n)", "int(n)", []string{"n"}},
+		{"array([first, ..._])", "array([first, ..._])", []string{"first"}},
+	}
+
+	for _, tt := range tests {
+		input := "match (v) { " + tt.input + " => 1 }"
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.StatementNodes[0].(*ast.ExpressionStatementNode)
+		exp := stmt.ExpressionNode.(*ast.MatchExpressionNode)
+		pattern := exp.Arms[0].Patterns[0]
+
+		if pattern.String() != tt.expected {
+			t.Errorf("wrong pattern for %q. want=%q, got=%q", tt.input, tt.expected, pattern.String())
+		}
+
+		bindings := ast.PatternBindings(pattern)
+		if strings.Join(bindings, ",") != strings.Join(tt.bindings, ",") {
+			t.Errorf("wrong bindings for %q. want=%v, got=%v", tt.input, tt.bindings, bindings)
+		}
+	}
+}
+
+func TestMatchGuard(t *testing.T) {
+	input := `match (x) { [a, b] if a > b => a, _ => 0 }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.StatementNodes[0].(*ast.ExpressionStatementNode)
+	exp := stmt.ExpressionNode.(*ast.MatchExpressionNode)
+
+	if !testInfixExpression(t, exp.Arms[0].GuardNode, "a", ">", "b") {
+		return
+	}
+	if exp.Arms[1].GuardNode != nil {
+		t.Errorf("arms[1] has a guard: %s", exp.Arms[1].GuardNode)
+	}
+	if exp.String() != "match x { [a, b] if (a > b) => a, _ => 0 }" {
+		t.Errorf("wrong string. got=%q", exp.String())
+	}
+}
+
+func TestLetDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, ...b] = c;", "let [a, ...b] = c;"},
+		{`let {"x": x, "y": [y]} = point`, "let {x: x, y: [y]} = point;"},
+		{"let [f] = [fn() { 1 }];", "let [f] = [fn() 1];"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.StatementNodes[0].(*ast.LetStatementNode)
+		if !ok {
+			t.Fatalf("statement is not *ast.LetStatementNode. got=%T", program.StatementNodes[0])
+		}
+		if stmt.PatternNode == nil {
+			t.Fatalf("let of %q has no pattern", tt.input)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("wrong string. want=%q, got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
 func TestIfElseExpression(t *testing.T) {
 	input := `if (x < y) { x } else { y }`
 
@@ -586,6 +1023,69 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultsAndRest(t *testing.T) {
+	tests := []struct {
+		input            string
+		expected         string
+		expectedRequired int
+		expectedRest     string
+	}{
+		{"fn(a, b = 1) {}", "fn(a, b = 1) ", 1, ""},
+		{"fn(a = 1, b = a + 1) {}", "fn(a = 1, b = (a + 1)) ", 0, ""},
+		{"fn(...rest) {}", "fn(...rest) ", 0, "rest"},
+		{"fn(a, b = [], ...rest) {}", "fn(a, b = [], ...rest) ", 1, "rest"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.StatementNodes[0].(*ast.ExpressionStatementNode)
+		function := stmt.ExpressionNode.(*ast.FunctionLiteralNode)
+
+		if function.String() != tt.expected {
+			t.Errorf("wrong function. want=%q, got=%q", tt.expected, function.String())
+		}
+		if function.NumRequired() != tt.expectedRequired {
+			t.Errorf("wrong number of required parameters for %q. want=%d, got=%d",
+				tt.input, tt.expectedRequired, function.NumRequired())
+		}
+		rest := ""
+		if function.RestNode != nil {
+			rest = function.RestNode.Value
+		}
+		if rest != tt.expectedRest {
+			t.Errorf("wrong rest parameter for %q. want=%q, got=%q", tt.input, tt.expectedRest, rest)
+		}
+	}
+}
+
+func TestSpreadArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(...a)", "f(...a)"},
+		{"f(1, ...a, 2)", "f(1, ...a, 2)"},
+		{"f(...g(x), ...[1, 2 + 3])", "f(...g(x), ...[1, (2 + 3)])"},
+		{"f(b: 2)", "f(b: 2)"},
+		{"f(1, ...a, c: x + 1, b: {1: 2})", "f(1, ...a, c: (x + 1), b: {1 : 2})"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -697,6 +1197,59 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedParts []interface{}
+		expectedStr   string
+	}{
+		{`"hello ${name}!"`, []interface{}{"hello ", "name", "!"}, `"hello ${name}!"`},
+		{`"${a}${b}"`, []interface{}{"a", "b"}, `"${a}${b}"`},
+		{`"${a + b} = ${c}"`, []interface{}{"(a + b)", " = ", "c"}, `"${(a + b)} = ${c}"`},
+		{`"${"in${x}"}"`, []interface{}{`"in${x}"`}, `"${"in${x}"}"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.StatementNodes[0].(*ast.ExpressionStatementNode)
+		str, ok := stmt.ExpressionNode.(*ast.InterpolatedStringNode)
+		if !ok {
+			t.Fatalf("exp not *ast.InterpolatedStringNode. got=%T", stmt.ExpressionNode)
+		}
+
+		if len(str.Parts) != len(tt.expectedParts) {
+			t.Fatalf("wrong number of parts for %s. want=%d, got=%d",
+				tt.input, len(tt.expectedParts), len(str.Parts))
+		}
+
+		for i, part := range str.Parts {
+			expected := tt.expectedParts[i].(string)
+			if literal, ok := part.(*ast.StringLiteralNode); ok {
+				if literal.Value != expected {
+					t.Errorf("%s - part %d has wrong text. want=%q, got=%q",
+						tt.input, i, expected, literal.Value)
+				}
+				continue
+			}
+			if part.String() != expected {
+				t.Errorf("%s - part %d wrong. want=%q, got=%q", tt.input, i, expected, part.String())
+			}
+		}
+
+		if str.String() != tt.expectedStr {
+			t.Errorf("wrong String(). want=%q, got=%q", tt.expectedStr, str.String())
+		}
+
+		if str.End().Offset != len(tt.input) {
+			t.Errorf("%s - node does not span the input. end=%d", tt.input, str.End().Offset)
+		}
+	}
+}
+
 func TestParsingEmptyArrayLiterals(t *testing.T) {
 	input := "[]"
 
@@ -967,6 +1520,198 @@ func TestFunctionLiteralWithName(t *testing.T) {
 	}
 }
 
+func TestNodePositions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStart string
+		expectedEnd   string
+	}{
+		{"foobar;", "1:1", "1:7"},
+		{"  1 + 2 * 3", "1:3", "1:12"},
+		{"let x = fn(a) {\n  a;\n};", "1:1", "3:2"},
+		{"add(1,\n 2)", "1:1", "2:4"},
+		{"[1, 2][0]", "1:1", "1:10"},
+		{`{"a": 1}`, "1:1", "1:9"},
+		{"if (x) { 1 } else { 2 }", "1:1", "1:24"},
+		{"return -x;", "1:1", "1:10"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.StatementNodes[0]
+		if stmt.Pos().String() != tt.expectedStart {
+			t.Errorf("wrong start for %q. want=%s, got=%s",
+				tt.input, tt.expectedStart, stmt.Pos())
+		}
+		if stmt.End().String() != tt.expectedEnd {
+			t.Errorf("wrong end for %q. want=%s, got=%s",
+				tt.input, tt.expectedEnd, stmt.End())
+		}
+	}
+}
+
+func TestParserDiagnostics(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedCode     DiagnosticCode
+		expectedPosition string
+		expectedExpected []token.TokenType
+		expectedGot      token.TokenType
+	}{
+		{"let = 5;", CodeUnexpectedToken, "1:5", []token.TokenType{token.IDENT}, token.ASSIGN},
+		{"let x 5;", CodeUnexpectedToken, "1:7", []token.TokenType{token.ASSIGN}, token.INT},
+		{"add(1, 2;", CodeUnexpectedToken, "1:9", []token.TokenType{token.RPAREN}, token.SEMICOLON},
+		{"1 + ;", CodeMissingExpression, "1:5", nil, token.SEMICOLON},
+		{"let x = 1 @ 2;", CodeIllegalCharacter, "1:11", nil, token.ILLEGAL},
+		{"puts(1.)", CodeIllegalCharacter, "1:7", nil, token.ILLEGAL},
+		{"puts(1.e5)", CodeIllegalCharacter, "1:7", nil, token.ILLEGAL},
+		{"99999999999999999999;", CodeInvalidInteger, "1:1", nil, token.INT},
+		{"let x = 0x8000_0000_0000_0000;", CodeInvalidInteger, "1:9", nil, token.INT},
+		{"let x = 0755;", CodeInvalidInteger, "1:9", nil, token.INT},
+		{"let x = 09;", CodeInvalidInteger, "1:9", nil, token.INT},
+		{"let x = 0755n;", CodeInvalidInteger, "1:9", nil, token.BIGINT},
+		{"let x = 0b12;", CodeMalformedToken, "1:9", nil, token.ERROR},
+		{"let x = 1.5n;", CodeMalformedToken, "1:9", nil, token.ERROR},
+		{"break;", CodeMisplacedBranch, "1:1", nil, token.BREAK},
+		{"1 = 2;", CodeInvalidAssignment, "1:1", nil, token.ASSIGN},
+		{"match (x) { -y => 1 }", CodeInvalidPattern, "1:13", nil, token.IDENT},
+		{"match (x) { 1 => 1 2 => 2 }", CodeUnexpectedToken, "1:20", []token.TokenType{token.COMMA}, token.INT},
+		{"match (x) { 1 = 1 }", CodeUnexpectedToken, "1:15", []token.TokenType{token.ARROW}, token.ASSIGN},
+		{"match (x) { f(y) => 1 }", CodeInvalidPattern, "1:13", nil, token.IDENT},
+		{"match (x) { [...a, b] => 1 }", CodeUnexpectedToken, "1:18", []token.TokenType{token.RBRACKET}, token.COMMA},
+		{"match (x) { {a: 1} => 1 }", CodeInvalidPattern, "1:14", nil, token.IDENT},
+		{"match (x) { [a, a] => 1 }", CodeInvalidPattern, "1:13", nil, token.RBRACKET},
+		{"match (x) { [a], [b] => 1 }", CodeInvalidPattern, "1:18", nil, token.RBRACKET},
+		{"let [a, b + 1] = x;", CodeUnexpectedToken, "1:11", []token.TokenType{token.COMMA}, token.PLUS},
+		{"match (x) { 1, 2 }", CodeUnexpectedToken, "1:18", []token.TokenType{token.ARROW}, token.RBRACE},
+		{"let x = 1; f(x) += 1;", CodeInvalidAssignment, "1:12", nil, token.PLUS_ASSIGN},
+		{"while (x) { fn() { continue } }", CodeMisplacedBranch, "1:20", nil, token.CONTINUE},
+		{"while (x) { a = a + [1, if (true) { continue } else { i }][0] }", CodeMisplacedBranch, "1:37", nil, token.CONTINUE},
+		{"while (x) { puts(if (true) { break } else { 1 }) }", CodeMisplacedBranch, "1:30", nil, token.BREAK},
+		{"while (x) { let x = if (c) { continue } else { i } }", CodeMisplacedBranch, "1:30", nil, token.CONTINUE},
+		{"while (x) { 1 + if (true) { continue } else { 2 } }", CodeMisplacedBranch, "1:29", nil, token.CONTINUE},
+		{"while (x) { if (true) { break } + 1 }", CodeMisplacedBranch, "1:25", nil, token.BREAK},
+		{"while (x) { match (x) { _ => { break } }[0] }", CodeMisplacedBranch, "1:32", nil, token.BREAK},
+		{"while x { }", CodeUnexpectedToken, "1:7", []token.TokenType{token.LPAREN}, token.IDENT},
+		{"for (let i = 0 i < 3;) { }", CodeUnexpectedToken, "1:16", []token.TokenType{token.SEMICOLON}, token.IDENT},
+		{"for (;; let i = i + 1 { }", CodeUnexpectedToken, "1:23", []token.TokenType{token.RPAREN}, token.LBRACE},
+		{"fn(a = 1, b) { }", CodeInvalidParameter, "1:11", nil, token.IDENT},
+		{"fn(...a, b) { }", CodeUnexpectedToken, "1:8", []token.TokenType{token.RPAREN}, token.COMMA},
+		{"fn(...a = 1) { }", CodeUnexpectedToken, "1:9", []token.TokenType{token.RPAREN}, token.ASSIGN},
+		{"fn(1) { }", CodeUnexpectedToken, "1:4", []token.TokenType{token.IDENT}, token.INT},
+		{"f(...)", CodeMissingExpression, "1:6", nil, token.RPAREN},
+		{"f(a: 1, 2)", CodeInvalidArgument, "1:9", nil, token.INT},
+		{"f(a: 1, ...b)", CodeInvalidArgument, "1:9", nil, token.ELLIPSIS},
+		{"f(a: 1, b: 2, a: 3)", CodeInvalidArgument, "1:15", nil, token.IDENT},
+		{"f(a: )", CodeMissingExpression, "1:6", nil, token.RPAREN},
+		{"1e999;", CodeInvalidFloat, "1:1", nil, token.FLOAT},
+		{"let x = 1; /* unterminated", CodeMalformedToken, "1:12", nil, token.ERROR},
+		{"add(1 /* unterminated", CodeMalformedToken, "1:7", nil, token.ERROR},
+		{`"a ${} b"`, CodeMissingExpression, "1:6", nil, token.TEMPLATE_TAIL},
+		{`"a ${x y}"`, CodeUnexpectedToken, "1:8", []token.TokenType{token.TEMPLATE_TAIL}, token.IDENT},
+		{`"a ${x`, CodeUnexpectedToken, "1:7", []token.TokenType{token.TEMPLATE_TAIL}, token.EOF},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Fatalf("wrong number of diagnostics for %q. want=1, got=%d (%v)",
+				tt.input, len(diagnostics), diagnostics)
+		}
+
+		d := diagnostics[0]
+		if d.Severity != SeverityError {
+			t.Errorf("wrong severity for %q. got=%s", tt.input, d.Severity)
+		}
+		if d.Code != tt.expectedCode {
+			t.Errorf("wrong code for %q. want=%s, got=%s", tt.input, tt.expectedCode, d.Code)
+		}
+		if d.Span.Start.String() != tt.expectedPosition {
+			t.Errorf("wrong position for %q. want=%s, got=%s",
+				tt.input, tt.expectedPosition, d.Span.Start)
+		}
+		if fmt.Sprint(d.Expected) != fmt.Sprint(tt.expectedExpected) {
+			t.Errorf("wrong expected tokens for %q. want=%v, got=%v",
+				tt.input, tt.expectedExpected, d.Expected)
+		}
+		if d.Got.Type != tt.expectedGot {
+			t.Errorf("wrong got token for %q. want=%s, got=%s",
+				tt.input, tt.expectedGot, d.Got.Type)
+		}
+	}
+}
+
+func TestLeadingZeroIntegers(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+		expectedHint    string
+	}{
+		{"0755", "integer literal 0755 has a leading zero", "write 0o755 for an octal integer or 755 for a decimal one"},
+		{"09", "integer literal 09 has a leading zero", "write 0o9 for an octal integer or 9 for a decimal one"},
+		{"0_10n", "integer literal 0_10n has a leading zero", "write 0o_10n for an octal integer or 10n for a decimal one"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Fatalf("wrong number of diagnostics for %q. want=1, got=%d (%v)",
+				tt.input, len(diagnostics), diagnostics)
+		}
+		if diagnostics[0].Message != tt.expectedMessage {
+			t.Errorf("wrong message for %q. want=%q, got=%q", tt.input, tt.expectedMessage, diagnostics[0].Message)
+		}
+		if diagnostics[0].Hint != tt.expectedHint {
+			t.Errorf("wrong hint for %q. want=%q, got=%q", tt.input, tt.expectedHint, diagnostics[0].Hint)
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors int
+		expectedAST    string
+	}{
+		{"let = 5; let y = 10; let 838383; y", 2, "let y = 10;y"},
+		{"let f = fn(x) { x + ; 2 }; f(1)", 1, "let f = fn<f>(x) 2;f(1)"},
+		{"let f = fn(x) { let = 2; x }; f(1)", 1, "let f = fn<f>(x) x;f(1)"},
+		{"if (x { 1 }; 3", 1, "3"},
+		{"let a = [1, 2; let b = 2; b", 1, "let b = 2;b"},
+		{"}}; 4", 1, "4"},
+		{"// comment\nlet a = /* inline */ 1; /* trailing */ a", 0, "let a = 1;a"},
+		{"{ x }", 1, ""},
+		{"let r = for (;;) { let a = 1; a }; 5", 1, "5"},
+		{"let r = while (x) { x }; let y = 1", 1, "let y = 1;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != tt.expectedErrors {
+			t.Errorf("wrong number of errors for %q. want=%d, got=%d (%v)",
+				tt.input, tt.expectedErrors, len(p.Errors()), p.Errors())
+		}
+		if program.String() != tt.expectedAST {
+			t.Errorf("wrong recovered program for %q. want=%q, got=%q",
+				tt.input, tt.expectedAST, program.String())
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.StatementNode, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
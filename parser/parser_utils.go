@@ -1,9 +1,6 @@
 package parser
 
-import (
-	"fmt"
-	"monkey/token"
-)
+import "monkey/token"
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
@@ -28,20 +25,6 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
-func (p *Parser) Errors() []string {
-	return p.errors
-}
-
-func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("Expected next token to be %s, but got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
-}
-
-func (p *Parser) noPrefixParserFnFound(t token.TokenType) {
-	msg := fmt.Sprintf("No prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
-}
-
 func (p *Parser) registerPrefixParserFn(tokenType token.TokenType, fn prefixParseFn) {
 	p.prefixParseFnMap[tokenType] = fn
 }
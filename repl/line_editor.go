@@ -0,0 +1,299 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const maxHistory = 1000
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// editorKey is a key decoded from an escape sequence
+type editorKey int
+
+const (
+	keyUnknown editorKey = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+)
+
+// lineEditor is a minimal line editor for terminals in raw mode, it
+// supports cursor movement, deletion and a history that is appended to
+// historyFile as lines are entered
+type lineEditor struct {
+	fd  int
+	in  *bufio.Reader
+	out io.Writer
+
+	history     []string
+	historyFile string
+}
+
+func newLineEditor(f *os.File, out io.Writer, historyFile string) *lineEditor {
+	e := &lineEditor{
+		fd:          int(f.Fd()),
+		in:          bufio.NewReader(f),
+		out:         out,
+		historyFile: historyFile,
+	}
+	e.loadHistory()
+
+	return e
+}
+
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	line, err := e.edit(prompt)
+	if err == nil {
+		e.addHistory(line)
+	}
+
+	return line, err
+}
+
+func (e *lineEditor) edit(prompt string) (string, error) {
+	var line []rune
+	cursor := 0
+
+	historyIndex := len(e.history)
+	pending := ""
+
+	showHistory := func(index int) {
+		if historyIndex == len(e.history) {
+			pending = string(line)
+		}
+		historyIndex = index
+
+		if historyIndex == len(e.history) {
+			line = []rune(pending)
+		} else {
+			line = []rune(e.history[historyIndex])
+		}
+		cursor = len(line)
+	}
+
+	insert := func(r ...rune) {
+		line = append(line[:cursor], append(r, line[cursor:]...)...)
+		cursor += len(r)
+	}
+
+	e.refresh(prompt, line, cursor)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		key := keyUnknown
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			key = keyDelete
+		case keyBackspace, keyCtrlH:
+			if cursor > 0 {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+			}
+		case keyCtrlA:
+			key = keyHome
+		case keyCtrlE:
+			key = keyEnd
+		case keyCtrlB:
+			key = keyLeft
+		case keyCtrlF:
+			key = keyRight
+		case keyCtrlP:
+			key = keyUp
+		case keyCtrlN:
+			key = keyDown
+		case keyCtrlU:
+			line = line[cursor:]
+			cursor = 0
+		case keyCtrlK:
+			line = line[:cursor]
+		case keyTab:
+			insert(' ', ' ')
+		case keyEscape:
+			key = e.readEscape()
+		default:
+			if unicode.IsPrint(r) {
+				insert(r)
+			}
+		}
+
+		switch key {
+		case keyUp:
+			if historyIndex > 0 {
+				showHistory(historyIndex - 1)
+			}
+		case keyDown:
+			if historyIndex < len(e.history) {
+				showHistory(historyIndex + 1)
+			}
+		case keyLeft:
+			if cursor > 0 {
+				cursor--
+			}
+		case keyRight:
+			if cursor < len(line) {
+				cursor++
+			}
+		case keyHome:
+			cursor = 0
+		case keyEnd:
+			cursor = len(line)
+		case keyDelete:
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		}
+
+		e.refresh(prompt, line, cursor)
+	}
+}
+
+// readEscape decodes the rest of an escape sequence, like "\x1b[A" for
+// the up arrow or "\x1b[3~" for delete
+func (e *lineEditor) readEscape() editorKey {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return keyUnknown
+	}
+
+	var params strings.Builder
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return keyUnknown
+		}
+		if (r < '0' || r > '9') && r != ';' {
+			break
+		}
+		params.WriteRune(r)
+	}
+
+	switch r {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		return keyRight
+	case 'D':
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch params.String() {
+		case "1", "7":
+			return keyHome
+		case "4", "8":
+			return keyEnd
+		case "3":
+			return keyDelete
+		}
+	}
+
+	return keyUnknown
+}
+
+// refresh redraws the prompt and the line, and places the cursor
+func (e *lineEditor) refresh(prompt string, line []rune, cursor int) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+	if back := len(line) - cursor; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (e *lineEditor) loadHistory() {
+	if e.historyFile == "" {
+		return
+	}
+
+	content, err := os.ReadFile(e.historyFile)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) != "" {
+			e.history = append(e.history, line)
+		}
+	}
+
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+		os.WriteFile(e.historyFile, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+	}
+}
+
+// addHistory remembers a line, skipping blank lines and repetitions of
+// the previous line, and appends it to the history file
+func (e *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[1:]
+	}
+
+	if e.historyFile == "" {
+		return
+	}
+
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, line)
+}
@@ -0,0 +1,97 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestEditor(input string, history ...string) *lineEditor {
+	return &lineEditor{
+		in:      bufio.NewReader(strings.NewReader(input)),
+		out:     &bytes.Buffer{},
+		history: history,
+	}
+}
+
+func TestLineEditorEditing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1\r", "let x = 1"},
+		{"abc\x7f\x7fd\r", "ad"},
+		{"ac\x1b[Db\r", "abc"},
+		{"bc\x01a\x05d\r", "abcd"},
+		{"abc\x1b[D\x1b[D\x1b[3~\r", "ac"},
+		{"abc\x1b[H\x1b[Cx\x1b[Fy\r", "axbcy"},
+		{"abc\x1b[D\x0b\r", "ab"},
+		{"abc\x1b[D\x15\r", "c"},
+		{"héllo\x1b[D\x1b[D\x1b[D\x1b[D\x7f\r", "éllo"},
+	}
+
+	for _, tt := range tests {
+		line, err := newTestEditor(tt.input).edit(PROMPT)
+		if err != nil {
+			t.Fatalf("input %q - unexpected error %s", tt.input, err)
+		}
+		if line != tt.expected {
+			t.Errorf("input %q - expected=%q, got=%q", tt.input, tt.expected, line)
+		}
+	}
+}
+
+func TestLineEditorHistory(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"\x1b[A\r", "second"},
+		{"\x1b[A\x1b[A\r", "first"},
+		{"\x1b[A\x1b[A\x1b[A\r", "first"},
+		{"new\x1b[A\x1b[B\r", "new"},
+		{"\x1b[A\x1b[A\x1b[B!\r", "second!"},
+	}
+
+	for _, tt := range tests {
+		line, err := newTestEditor(tt.input, "first", "second").edit(PROMPT)
+		if err != nil {
+			t.Fatalf("input %q - unexpected error %s", tt.input, err)
+		}
+		if line != tt.expected {
+			t.Errorf("input %q - expected=%q, got=%q", tt.input, tt.expected, line)
+		}
+	}
+}
+
+func TestLineEditorControlKeys(t *testing.T) {
+	if _, err := newTestEditor("abc\x03").edit(PROMPT); err != errInterrupted {
+		t.Errorf("ctrl-c - expected errInterrupted, got %v", err)
+	}
+	if _, err := newTestEditor("\x04").edit(PROMPT); err != io.EOF {
+		t.Errorf("ctrl-d - expected io.EOF, got %v", err)
+	}
+	if line, _ := newTestEditor("ab\x01\x04\r").edit(PROMPT); line != "b" {
+		t.Errorf("ctrl-d on a line - expected=%q, got=%q", "b", line)
+	}
+}
+
+func TestLineEditorHistoryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), HISTORY_FILE)
+
+	e := &lineEditor{historyFile: file}
+	for _, line := range []string{"first", "", "second", "second", "third"} {
+		e.addHistory(line)
+	}
+
+	loaded := &lineEditor{historyFile: file}
+	loaded.loadHistory()
+
+	expected := []string{"first", "second", "third"}
+	if strings.Join(loaded.history, ",") != strings.Join(expected, ",") {
+		t.Errorf("wrong history. expected=%q, got=%q", expected, loaded.history)
+	}
+}
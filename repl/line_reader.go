@@ -0,0 +1,57 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const HISTORY_FILE = ".monkey_history"
+
+// errInterrupted is returned when the user cancels the current input
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads one line of input after showing a prompt
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// newLineReader returns a line editor with a persistent history when the
+// input is a terminal, and a plain line scanner otherwise
+func newLineReader(in io.Reader, out io.Writer) lineReader {
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		return newLineEditor(f, out, historyPath())
+	}
+
+	return &plainReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return r.scanner.Text(), nil
+}
@@ -1,83 +1,284 @@
 package repl
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"monkey/ast"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
 	"monkey/vm"
+	"strings"
 )
 
 const PROMPT = ">> "
+const CONTINUATION_PROMPT = "... "
+
+// Engine selects what executes the REPL input
+type Engine string
+
+const (
+	EngineEval Engine = "eval"
+	EngineVM   Engine = "vm"
+	EngineBoth Engine = "both"
+)
+
+// ParseEngine maps a user supplied engine name to an Engine
+func ParseEngine(name string) (Engine, bool) {
+	switch name {
+	case "eval", "evaluator":
+		return EngineEval, true
+	case "vm":
+		return EngineVM, true
+	case "both":
+		return EngineBoth, true
+	}
+	return "", false
+}
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	// env := object.NewEnvironment()
+	StartWithEngine(in, out, EngineVM)
+}
+
+func StartWithEngine(in io.Reader, out io.Writer, engine Engine) {
+	reader := newLineReader(in, out)
+	s := newSession(out, engine)
+
+	var lines []string
+
+	for {
+		prompt := PROMPT
+		if len(lines) > 0 {
+			prompt = CONTINUATION_PROMPT
+		}
+
+		line, err := reader.ReadLine(prompt)
+		if err == errInterrupted {
+			lines = nil
+			continue
+		}
+		if err != nil {
+			// run what was typed before the input ended
+			if len(lines) > 0 {
+				s.record(lines)
+				s.execute("", strings.Join(lines, "\n"))
+			}
+			return
+		}
+
+		if len(lines) == 0 && isMetaCommand(line) {
+			s.record([]string{line})
+			if !s.runMetaCommand(line) {
+				return
+			}
+			continue
+		}
+
+		lines = append(lines, line)
+		source := strings.Join(lines, "\n")
+		if isIncomplete(source) {
+			continue
+		}
+
+		s.record(lines)
+		lines = nil
+
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+
+		s.execute("", source)
+	}
+}
+
+// session holds the state of both engines, so that every engine sees
+// the definitions made while it was selected
+type session struct {
+	out      io.Writer
+	engine   Engine
+	overflow object.OverflowPolicy
+
+	// transcript holds the inputs and outputs of the session for :save
+	transcript bytes.Buffer
+
+	showTokens   bool
+	showAST      bool
+	showBytecode bool
+
+	env *object.Environment
 
-	constants := []object.Object{}
-	globals := make([]object.Object, vm.GlobalsSize)
+	constants   []object.Object
+	globals     []object.Object
+	symbolTable *compiler.SymbolTable
+}
 
+func newSession(out io.Writer, engine Engine) *session {
 	symbolTable := compiler.NewSymbolTable()
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
 
-	for {
-		fmt.Fprint(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+	s := &session{
+		engine:      engine,
+		overflow:    object.OverflowWrap,
+		env:         object.NewEnvironment(),
+		constants:   []object.Object{},
+		globals:     make([]object.Object, vm.GlobalsSize),
+		symbolTable: symbolTable,
+	}
+	s.out = io.MultiWriter(out, &s.transcript)
+
+	return s
+}
+
+// record adds an input to the transcript, as it was shown on the screen
+func (s *session) record(lines []string) {
+	for i, line := range lines {
+		prompt := PROMPT
+		if i > 0 {
+			prompt = CONTINUATION_PROMPT
+		}
+		fmt.Fprintf(&s.transcript, "%s%s\n", prompt, line)
+	}
+}
+
+// execute runs the source with the selected engine, filename is only used
+// in error messages
+func (s *session) execute(filename, source string) {
+	stdout := object.Stdout
+	object.Stdout = s.out
+	defer func() { object.Stdout = stdout }()
+
+	if s.showTokens {
+		l := lexer.NewWithFilename(filename, source)
+		for tk := l.NextToken(); tk.Type != token.EOF; tk = l.NextToken() {
+			fmt.Fprintf(s.out, "%-8s %-10s %q\n", tk.Start, tk.Type, tk.Literal)
+		}
+	}
+
+	p := parser.New(lexer.NewWithFilename(filename, source))
+	programNode := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return
+	}
+
+	if s.showAST {
+		io.WriteString(s.out, programNode.String())
+		io.WriteString(s.out, "\n")
+	}
+
+	switch s.engine {
+	case EngineEval:
+		result := s.runEvaluator(programNode)
+		if result != nil && result.Type() != object.NULL_OBJ {
+			io.WriteString(s.out, result.Inspect())
+			io.WriteString(s.out, "\n")
+		}
+
+	case EngineVM:
+		if result, ok := s.runVM(programNode); ok && result != nil {
+			io.WriteString(s.out, result.Inspect())
+			io.WriteString(s.out, "\n")
+		}
+
+	case EngineBoth:
+		evalResult := s.runEvaluator(programNode)
+		vmResult, ok := s.runVM(programNode)
+		if ok && vmResult != nil {
+			io.WriteString(s.out, vmResult.Inspect())
+			io.WriteString(s.out, "\n")
+		}
+
+		// statements without a value leave a stale element in the vm, so
+		// there is nothing to compare
+		if evalResult == nil {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		evalFailed := isError(evalResult)
+		vmFailed := !ok || isError(vmResult)
 
-		programNode := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
+		if evalFailed != vmFailed || (!evalFailed && !objectsEqual(evalResult, vmResult)) {
+			vmInspect := "<error>"
+			if ok && vmResult != nil {
+				vmInspect = vmResult.Inspect()
+			}
+			fmt.Fprintf(s.out, "warning: engines disagree: evaluator=%s, vm=%s\n",
+				evalResult.Inspect(), vmInspect)
 		}
+	}
+}
 
-		compiler := compiler.NewWithState(constants, symbolTable)
-		err := compiler.Compile(programNode)
-		if err != nil {
-			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
-			continue
+func (s *session) runEvaluator(programNode *ast.ProgramNode) object.Object {
+	return evaluator.New(evaluator.Config{Overflow: s.overflow}).Eval(programNode, s.env)
+}
+
+// runVM compiles and runs the program, reporting any errors itself.
+// It returns false if the program failed to compile or run. The result is
+// nil if the program never popped a value.
+func (s *session) runVM(programNode *ast.ProgramNode) (object.Object, bool) {
+	firstConstant := len(s.constants)
+
+	compiler := compiler.NewWithState(s.constants, s.symbolTable)
+	err := compiler.Compile(programNode)
+	for _, w := range compiler.Warnings() {
+		fmt.Fprintf(s.out, "warning: %s\n", w)
+	}
+	if err != nil {
+		fmt.Fprintf(s.out, "Woops! Compilation failed:\n")
+		for _, e := range compiler.Errors() {
+			fmt.Fprintf(s.out, "\t%s\n", e)
 		}
+		return nil, false
+	}
 
-		bytecode := compiler.Bytecode()
-		constants = bytecode.Constants
+	bytecode := compiler.Bytecode()
+	s.constants = bytecode.Constants
 
-		machine := vm.NewWithGlobalsStore(bytecode, globals)
-		err = machine.Run()
-		if err != nil {
-			fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
-			continue
+	if s.showBytecode {
+		printBytecode(s.out, bytecode, firstConstant)
+	}
+
+	machine := vm.NewWithConfig(bytecode, vm.Config{Globals: s.globals, Overflow: s.overflow})
+	err = machine.Run()
+	if err != nil {
+		if runtimeErr, ok := err.(*vm.RuntimeError); ok {
+			fmt.Fprintf(s.out, "Woops! Executing bytecode failed:\n %s\n", runtimeErr.Trace())
+		} else {
+			fmt.Fprintf(s.out, "Woops! Executing bytecode failed:\n %s\n", err)
 		}
+		return nil, false
+	}
 
-		stackTop := machine.LastPoppedStackElem()
-		io.WriteString(out, stackTop.Inspect())
-		io.WriteString(out, "\n")
+	return machine.LastPoppedStackElem(), true
+}
 
-		// Tree walking evaluator
-		// resultObject := evaluator.Eval(programNode, env)
-		// if resultObject != nil && resultObject.Type() != object.NULL_OBJ {
-		// 	io.WriteString(out, resultObject.Inspect())
-		// 	io.WriteString(out, "\n")
-		// }
+// printBytecode prints the main instructions and the functions compiled
+// from the current input
+func printBytecode(out io.Writer, bytecode *compiler.Bytecode, firstConstant int) {
+	io.WriteString(out, bytecode.Instructions.String())
 
-		// Parser output
-		// io.WriteString(out, program.String())
-		// io.WriteString(out, "\n")
+	for i := firstConstant; i < len(bytecode.Constants); i++ {
+		fn, ok := bytecode.Constants[i].(*object.CompiledFnObject)
+		if !ok {
+			continue
+		}
 
-		// Lexer output
-		// for tk := l.NextToken(); tk.Type != token.EOF; tk = l.NextToken() {
-		// 	fmt.Fprintf(out, "%+v\n", tk)
-		// }
+		name := fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		fmt.Fprintf(out, "fn %s (constant %d):\n", name, i)
+		for _, line := range strings.SplitAfter(fn.Instructions.String(), "\n") {
+			if line != "" {
+				io.WriteString(out, "  "+line)
+			}
+		}
 	}
 }
 
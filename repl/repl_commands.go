@@ -0,0 +1,132 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey/object"
+	"os"
+	"strings"
+)
+
+const metaCommandPrefix = ":"
+
+const metaCommandsHelp = `Commands:
+	:engine [eval|vm|both]  show or select the engine, both compares the results
+	:tokens [on|off]        toggle printing the tokens of each input
+	:ast [on|off]           toggle printing the syntax tree of each input
+	:bytecode [on|off]      toggle printing the bytecode of each input
+	:overflow [wrap|error|promote]
+	                        show or select what integer overflow does
+	:load <file>            run a file in the current session
+	:save <file>            save the session transcript to a file
+	:help                   show this help
+	:quit                   leave the REPL
+`
+
+func isMetaCommand(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), metaCommandPrefix)
+}
+
+// runMetaCommand executes a meta command, it returns false when the REPL
+// should stop
+func (s *session) runMetaCommand(line string) bool {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), metaCommandPrefix))
+	if len(fields) == 0 {
+		io.WriteString(s.out, metaCommandsHelp)
+		return true
+	}
+
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "engine":
+		if len(args) == 0 {
+			fmt.Fprintf(s.out, "engine: %s\n", s.engine)
+			return true
+		}
+
+		engine, ok := ParseEngine(args[0])
+		if !ok {
+			fmt.Fprintf(s.out, "unknown engine %q, want eval, vm or both\n", args[0])
+			return true
+		}
+		s.engine = engine
+		fmt.Fprintf(s.out, "engine: %s\n", s.engine)
+
+	case "overflow":
+		if len(args) == 0 {
+			fmt.Fprintf(s.out, "overflow: %s\n", s.overflow)
+			return true
+		}
+
+		policy, ok := object.ParseOverflowPolicy(args[0])
+		if !ok {
+			fmt.Fprintf(s.out, "unknown overflow policy %q, want wrap, error or promote\n", args[0])
+			return true
+		}
+		s.overflow = policy
+		fmt.Fprintf(s.out, "overflow: %s\n", s.overflow)
+
+	case "tokens":
+		s.toggle(name, &s.showTokens, args)
+	case "ast":
+		s.toggle(name, &s.showAST, args)
+	case "bytecode":
+		s.toggle(name, &s.showBytecode, args)
+
+	case "load":
+		if len(args) != 1 {
+			fmt.Fprintf(s.out, "usage: :load <file>\n")
+			return true
+		}
+
+		content, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintf(s.out, "%s\n", err)
+			return true
+		}
+		s.execute(args[0], string(content))
+
+	case "save":
+		if len(args) != 1 {
+			fmt.Fprintf(s.out, "usage: :save <file>\n")
+			return true
+		}
+
+		if err := os.WriteFile(args[0], s.transcript.Bytes(), 0644); err != nil {
+			fmt.Fprintf(s.out, "%s\n", err)
+			return true
+		}
+		fmt.Fprintf(s.out, "transcript saved to %s\n", args[0])
+
+	case "help":
+		io.WriteString(s.out, metaCommandsHelp)
+	case "quit", "exit", "q":
+		return false
+
+	default:
+		fmt.Fprintf(s.out, "unknown command :%s, type :help for a list of commands\n", name)
+	}
+
+	return true
+}
+
+func (s *session) toggle(name string, flag *bool, args []string) {
+	switch {
+	case len(args) == 0:
+		*flag = !*flag
+	case args[0] == "on":
+		*flag = true
+	case args[0] == "off":
+		*flag = false
+	default:
+		fmt.Fprintf(s.out, "usage: :%s [on|off]\n", name)
+		return
+	}
+
+	state := "off"
+	if *flag {
+		state = "on"
+	}
+	fmt.Fprintf(s.out, "%s: %s\n", name, state)
+}
@@ -0,0 +1,78 @@
+package repl
+
+import "monkey/object"
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+func isFunction(obj object.Object) bool {
+	switch obj.Type() {
+	case object.FN_OBJ, object.COMPILED_FN_OBJ, object.CLOSURE_OBJ:
+		return true
+	}
+	return false
+}
+
+// objectsEqual compares results of the evaluator and the vm by value.
+// Functions have a different representation in each engine and are
+// considered equal, hashes are compared regardless of their pair order.
+func objectsEqual(a, b object.Object) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if isFunction(a) && isFunction(b) {
+		return true
+	}
+
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *object.IntObject:
+		return a.Value == b.(*object.IntObject).Value
+	case *object.BigIntObject:
+		return a.Value.Cmp(b.(*object.BigIntObject).Value) == 0
+	case *object.FloatObject:
+		return a.Value == b.(*object.FloatObject).Value
+	case *object.BoolObject:
+		return a.Value == b.(*object.BoolObject).Value
+	case *object.StringObject:
+		return a.Value == b.(*object.StringObject).Value
+	case *object.NullObject:
+		return true
+	case *object.ErrorObject:
+		return true
+	case *object.BuiltinObject:
+		return true
+
+	case *object.ArrayObject:
+		other := b.(*object.ArrayObject)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !objectsEqual(a.Elements[i], other.Elements[i]) {
+				return false
+			}
+		}
+		return true
+
+	case *object.HashObject:
+		other := b.(*object.HashObject)
+		if len(a.Pairs) != len(other.Pairs) {
+			return false
+		}
+		for key, pair := range a.Pairs {
+			otherPair, ok := other.Pairs[key]
+			if !ok || !objectsEqual(pair.Value, otherPair.Value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a.Inspect() == b.Inspect()
+}
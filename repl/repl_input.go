@@ -0,0 +1,67 @@
+package repl
+
+import (
+	"monkey/lexer"
+	"monkey/token"
+)
+
+// trailingOperators are the tokens that cannot end an expression, so the
+// input continues on the next line
+var trailingOperators = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.PERCENT:  true,
+	token.BANG:     true,
+	token.LT:       true,
+	token.GT:       true,
+	token.LT_EQ:    true,
+	token.GT_EQ:    true,
+	token.EQ:       true,
+	token.NOT_EQ:   true,
+	token.AND:      true,
+	token.OR:       true,
+
+	token.AMPERSAND:   true,
+	token.PIPE:        true,
+	token.CARET:       true,
+	token.TILDE:       true,
+	token.SHIFT_LEFT:  true,
+	token.SHIFT_RIGHT: true,
+	token.COMMA:       true,
+	token.COLON:       true,
+}
+
+// isIncomplete reports whether the source needs more lines before it can
+// be parsed, because it has unclosed braces, brackets, parentheses,
+// strings, interpolations or comments, or ends with an operator
+func isIncomplete(source string) bool {
+	depth := 0
+	last := token.Token{Type: token.EOF}
+
+	l := lexer.New(source)
+	for tk := l.NextToken(); tk.Type != token.EOF; tk = l.NextToken() {
+		switch tk.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE, token.TEMPLATE_HEAD:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE, token.TEMPLATE_TAIL:
+			depth--
+		case token.ERROR:
+			switch tk.Literal {
+			case lexer.UnterminatedComment, lexer.UnterminatedString, lexer.UnterminatedRawString:
+				return true
+			}
+		}
+		last = tk
+	}
+
+	// too many closing delimiters will not be fixed by more input, leave
+	// the error to the parser
+	if depth > 0 {
+		return true
+	}
+
+	return depth == 0 && trailingOperators[last.Type]
+}
@@ -0,0 +1,231 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEngineParity(t *testing.T) {
+	input := `:engine both
+let add = fn(a, b) { a + b };
+add(1, 2)
+[1, true, {"a": 1, "b": [2]}]
+len(1)
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	if strings.Contains(out.String(), "warning") {
+		t.Fatalf("engines disagree:\n%s", out.String())
+	}
+
+	for _, expected := range []string{"engine: both", "3", "[1, true, {"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output does not contain %q:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestFailedPatternsKeepVariables(t *testing.T) {
+	input := `:engine both
+let a = 1
+let [a, 2] = [5, 3]
+match ([1, "no"]) { [len, 2] => 0, [a] => a, _ => 0 }
+a * 100 + len("ab")
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	if strings.Contains(out.String(), "warning") {
+		t.Fatalf("engines disagree:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), ">> 102\n") {
+		t.Errorf("a failed pattern changed a variable:\n%s", out.String())
+	}
+}
+
+func TestInputsWithoutValue(t *testing.T) {
+	input := `:engine both
+// a comment pops nothing
+let q = 1 / 0;
+q
+let y = zz;
+y
+1 + 1
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	if strings.Contains(out.String(), "warning") {
+		t.Fatalf("engines disagree:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), ">> 2\n") {
+		t.Errorf("the session did not survive inputs without a value:\n%s", out.String())
+	}
+}
+
+func TestMetaCommands(t *testing.T) {
+	input := `:tokens
+:ast on
+:bytecode off
+:engine foo
+:overflow promote
+9223372036854775807 + 1
+:overflow error
+9223372036854775807 + 1
+:overflow sometimes
+:nope
+:quit
+1 + 1
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := []string{
+		"tokens: on",
+		"ast: on",
+		"bytecode: off",
+		`unknown engine "foo"`,
+		"overflow: promote",
+		"9223372036854775808",
+		"integer overflow: 9223372036854775807 + 1",
+		`unknown overflow policy "sometimes"`,
+		"unknown command :nope",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out.String(), e) {
+			t.Errorf("output does not contain %q:\n%s", e, out.String())
+		}
+	}
+
+	if strings.Contains(out.String(), "(1 + 1)") {
+		t.Errorf("input after :quit was executed:\n%s", out.String())
+	}
+}
+
+func TestObjectsEqual(t *testing.T) {
+	one := &object.IntObject{Value: 1}
+	two := &object.IntObject{Value: 2}
+	str := &object.StringObject{Value: "a"}
+
+	hash := func(pairs ...object.Object) *object.HashObject {
+		h := &object.HashObject{Pairs: map[object.HashKey]object.HashPair{}}
+		for i := 0; i < len(pairs); i += 2 {
+			key := pairs[i].(object.Hashable)
+			h.Pairs[key.HashKey()] = object.HashPair{Key: pairs[i], Value: pairs[i+1]}
+		}
+		return h
+	}
+
+	tests := []struct {
+		a, b     object.Object
+		expected bool
+	}{
+		{one, &object.IntObject{Value: 1}, true},
+		{one, two, false},
+		{one, str, false},
+		{str, &object.StringObject{Value: "a"}, true},
+		{&object.ArrayObject{Elements: []object.Object{one, str}}, &object.ArrayObject{Elements: []object.Object{one, str}}, true},
+		{&object.ArrayObject{Elements: []object.Object{one}}, &object.ArrayObject{Elements: []object.Object{two}}, false},
+		{hash(one, str, str, two), hash(str, two, one, str), true},
+		{hash(one, str), hash(one, two), false},
+		{&object.FunctionObject{}, &object.ClosureObject{}, true},
+		{nil, one, false},
+	}
+
+	for i, tt := range tests {
+		if got := objectsEqual(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] - expected=%t, got=%t", i, tt.expected, got)
+		}
+	}
+}
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n x\n}", false},
+		{"[1, 2", true},
+		{"add(1,", true},
+		{"let x =", true},
+		{"1 +", true},
+		{`"unterminated`, true},
+		{"`raw\nstring", true},
+		{`"bad \q escape"`, false},
+		{`"done"`, false},
+		{`"a ${b`, true},
+		{`"a ${b} c`, true},
+		{`"a ${ {1: 2}[1] } c"`, false},
+		{"1 /* comment", true},
+		{"1 /* comment */", false},
+		{"1 + // comment", true},
+		{"}", false},
+		{"1 + 2)", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) - expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := "let add = fn(a,\nb) {\na +\nb\n};\nadd(20,\n22)\n"
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := PROMPT + strings.Repeat(CONTINUATION_PROMPT, 4)
+	if !strings.HasPrefix(out.String(), expected) {
+		t.Errorf("wrong prompts. expected prefix %q, got %q", expected, out.String())
+	}
+	if !strings.Contains(out.String(), "42\n") {
+		t.Errorf("output does not contain the result:\n%s", out.String())
+	}
+}
+
+func TestLoadAndSave(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.monkey")
+	transcript := filepath.Join(dir, "transcript.txt")
+
+	if err := os.WriteFile(script, []byte("let double = fn(x) {\n  x * 2\n};\ndouble(1)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := ":load " + script + "\ndouble(\n21)\nputs(\"hello\")\n:save " + transcript + "\n"
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	content, err := os.ReadFile(transcript)
+	if err != nil {
+		t.Fatalf("transcript not saved: %s\n%s", err, out.String())
+	}
+
+	expected := ">> :load " + script + "\n" +
+		"2\n" +
+		">> double(\n" +
+		"... 21)\n" +
+		"42\n" +
+		">> puts(\"hello\")\n" +
+		"hello\n" +
+		"null\n" +
+		">> :save " + transcript + "\n"
+	if string(content) != expected {
+		t.Errorf("wrong transcript. expected=%q, got=%q", expected, string(content))
+	}
+}
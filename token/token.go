@@ -1,16 +1,28 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 const (
 	ILLEGAL = "ILLEGAL"
+	ERROR   = "ERROR" // malformed input, the literal holds the message
 	EOF     = "EOF"
 
 	// Identifiers + literals
 	IDENT  = "IDEN"   // add, foobar, x, y, ...
 	INT    = "INT"    // 12345
+	BIGINT = "BIGINT" // 12345n
+	FLOAT  = "FLOAT"  // 1.5, 2e10, 1.5e-3
 	STRING = "STRING" // "foobar"
 
+	// Parts of an interpolated string, "a${x}b${y}c" is lexed as
+	// TEMPLATE_HEAD x TEMPLATE_MIDDLE y TEMPLATE_TAIL. The literals hold
+	// the text of the parts, without the quotes and the ${ and }.
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"   // "a${
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE" // }b${
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"   // }c"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -18,17 +30,45 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 
-	LT = "<"
-	GT = ">"
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
 
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND = "&&"
+	OR  = "||"
+
+	// Bitwise operators, on integers
+	AMPERSAND   = "&"
+	PIPE        = "|"
+	CARET       = "^"
+	TILDE       = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	// Compound assignments, x += 1 is x = x + 1
+	PLUS_ASSIGN        = "+="
+	MINUS_ASSIGN       = "-="
+	ASTERISK_ASSIGN    = "*="
+	SLASH_ASSIGN       = "/="
+	PERCENT_ASSIGN     = "%="
+	AMPERSAND_ASSIGN   = "&="
+	PIPE_ASSIGN        = "|="
+	CARET_ASSIGN       = "^="
+	SHIFT_LEFT_ASSIGN  = "<<="
+	SHIFT_RIGHT_ASSIGN = ">>="
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ARROW     = "=>"
+	ELLIPSIS  = "..."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -45,21 +85,96 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	MATCH    = "MATCH"
 )
 
+// Position describes a location in the source text
+type Position struct {
+	Filename string // file name, if any
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number in characters, starting at 1
+}
+
+// IsValid reports whether the position points into a source text
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span describes a range of source text, End is exclusive
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) String() string {
+	return s.Start.String()
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Start   Position // position of the first character of the token
+	End     Position // position immediately after the last character of the token
+
+	// LeadingTrivia holds the comments between the previous token and this one
+	LeadingTrivia []Trivia
+}
+
+func (t Token) Span() Span {
+	return Span{Start: t.Start, End: t.End}
+}
+
+type TriviaKind string
+
+const (
+	LINE_COMMENT  TriviaKind = "LINE_COMMENT"  // from // to the end of the line
+	BLOCK_COMMENT TriviaKind = "BLOCK_COMMENT" // between /* and */, can be nested
+)
+
+// Trivia is source text the parser skips, kept for formatters and
+// documentation tools
+type Trivia struct {
+	Kind  TriviaKind
+	Text  string // the whole comment, including its delimiters
+	Start Position
+	End   Position
+}
+
+func (t Trivia) Span() Span {
+	return Span{Start: t.Start, End: t.End}
 }
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"match":    MATCH,
 }
 
 func CheckIsKeyword(ident string) TokenType {
@@ -1,33 +1,64 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"monkey/code"
 	"monkey/compiler"
 	"monkey/object"
+	"strings"
 )
 
 const StackSize = 2048
 const GlobalsSize = 65536
 const MaxFrames = 1024
 
+// MainFunctionName is the name of the top level program in stack traces
+const MainFunctionName = "<main>"
+
 var True = &object.BoolObject{Value: true}
 var False = &object.BoolObject{Value: false}
 var Null = &object.NullObject{}
 
+// Config holds the settings of a VM
+type Config struct {
+	// Globals is the globals store, it is kept between runs by the REPL.
+	// A new store is made if it is nil.
+	Globals []object.Object
+
+	// Overflow is what happens when an integer operation overflows, the
+	// default is to wrap around
+	Overflow object.OverflowPolicy
+}
+
 type VM struct {
 	constants []object.Object
 	globals   []object.Object
+	overflow  object.OverflowPolicy
 
 	stack []object.Object
 	sp    int // Always points to the next value. Top of the stack is stack[sp - 1]
 
 	frames      []*Frame
 	framesIndex int
+
+	// upvalues that still refer to a stack slot, ordered by frame, so the
+	// ones of the current frame are last
+	openUpvalues []openUpvalue
+}
+
+type openUpvalue struct {
+	slot    int
+	upvalue *object.UpvalueObject
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFnObject{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFnObject{
+		Instructions: bytecode.Instructions,
+		Name:         MainFunctionName,
+		SourceMap:    bytecode.SourceMap,
+	}
 	mainClosure := &object.ClosureObject{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 
@@ -47,8 +78,15 @@ func New(bytecode *compiler.Bytecode) *VM {
 }
 
 func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	return NewWithConfig(bytecode, Config{Globals: globals})
+}
+
+func NewWithConfig(bytecode *compiler.Bytecode, config Config) *VM {
 	vm := New(bytecode)
-	vm.globals = globals
+	if config.Globals != nil {
+		vm.globals = config.Globals
+	}
+	vm.overflow = config.Overflow
 	return vm
 }
 
@@ -67,6 +105,17 @@ func (vm *VM) push(o object.Object) error {
 	return nil
 }
 
+// pushVariable pushes the value of a variable. The variables of a let
+// statement that did not run, like one in a loop body that was skipped,
+// have no value yet.
+func (vm *VM) pushVariable(o object.Object) error {
+	if o == nil {
+		return fmt.Errorf("undefined variable: it has no value yet")
+	}
+
+	return vm.push(o)
+}
+
 func (vm *VM) pop() object.Object {
 	o := vm.stack[vm.sp-1]
 	vm.sp--
@@ -81,8 +130,10 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	rightType := right.Type()
 
 	switch {
-	case leftType == object.INT_OBJ && rightType == object.INT_OBJ:
+	case object.IsInteger(left) && object.IsInteger(right):
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
 	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
 		return vm.executeBinaryStringOperation(op, left, right)
 	default:
@@ -91,14 +142,76 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	}
 }
 
+// integerOperators maps the opcodes of the integer operations to the
+// operators of object.IntegerOperation
+var integerOperators = map[code.Opcode]string{
+	code.OpAdd:        "+",
+	code.OpSub:        "-",
+	code.OpMul:        "*",
+	code.OpDiv:        "/",
+	code.OpMod:        "%",
+	code.OpBitAnd:     "&",
+	code.OpBitOr:      "|",
+	code.OpBitXor:     "^",
+	code.OpShiftLeft:  "<<",
+	code.OpShiftRight: ">>",
+}
+
+// comparisonOperators maps the opcodes of the comparisons to their
+// operators, for error messages
+var comparisonOperators = map[code.Opcode]string{
+	code.OpEqual:              "==",
+	code.OpNotEqual:           "!=",
+	code.OpLessThan:           "<",
+	code.OpGreaterThan:        ">",
+	code.OpLessThanOrEqual:    "<=",
+	code.OpGreaterThanOrEqual: ">=",
+}
+
+// unknownOperator returns the error for an operation the operand types do
+// not support, with the operator written like in the source
+func unknownOperator(op code.Opcode, left, right object.Object) error {
+	operator, ok := integerOperators[op]
+	if !ok {
+		operator, ok = comparisonOperators[op]
+	}
+	if !ok {
+		operator = fmt.Sprintf("%d", op)
+		if def, err := code.Lookup(byte(op)); err == nil {
+			operator = def.Name
+		}
+	}
+
+	return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
 func (vm *VM) executeBinaryIntegerOperation(
 	op code.Opcode,
 	left, right object.Object,
 ) error {
-	leftValue := left.(*object.IntObject).Value
-	rightValue := right.(*object.IntObject).Value
+	operator, ok := integerOperators[op]
+	if !ok {
+		return unknownOperator(op, left, right)
+	}
 
-	var result int64
+	result, err := object.IntegerOperation(operator, left, right, vm.overflow)
+	if err != nil {
+		return err
+	}
+
+	return vm.push(result)
+}
+
+// executeBinaryFloatOperation runs arithmetic on two floats, or on a float
+// and an int, which is converted to a float
+func (vm *VM) executeBinaryFloatOperation(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue, _ := object.AsFloat(left)
+	rightValue, _ := object.AsFloat(right)
+
+	var result float64
 
 	switch op {
 	case code.OpAdd:
@@ -109,29 +222,38 @@ func (vm *VM) executeBinaryIntegerOperation(
 		result = leftValue * rightValue
 	case code.OpDiv:
 		result = leftValue / rightValue
+	case code.OpMod:
+		result = math.Mod(leftValue, rightValue)
 	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+		return unknownOperator(op, left, right)
 	}
 
-	return vm.push(&object.IntObject{Value: result})
+	return vm.push(&object.FloatObject{Value: result})
 }
 
 func (vm *VM) executeComparison(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
-	if left.Type() == object.INT_OBJ && right.Type() == object.INT_OBJ {
+	if object.IsInteger(left) && object.IsInteger(right) {
 		return vm.executeIntegerComparison(op, left, right)
 	}
 
+	if isNumber(left) && isNumber(right) {
+		return vm.executeFloatComparison(op, left, right)
+	}
+
+	if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
+		return vm.executeStringComparison(op, left, right)
+	}
+
 	switch op {
 	case code.OpEqual:
 		return vm.push(nativeBoolToBooleanObject(right == left))
 	case code.OpNotEqual:
 		return vm.push(nativeBoolToBooleanObject(right != left))
 	default:
-		return fmt.Errorf("unknown operator: %d (%s %s)",
-			op, left.Type(), right.Type())
+		return unknownOperator(op, left, right)
 	}
 }
 
@@ -139,18 +261,23 @@ func (vm *VM) executeIntegerComparison(
 	op code.Opcode,
 	left, right object.Object,
 ) error {
-	leftValue := left.(*object.IntObject).Value
-	rightValue := right.(*object.IntObject).Value
+	cmp := object.CompareIntegers(left, right)
 
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+		return vm.push(nativeBoolToBooleanObject(cmp == 0))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+		return vm.push(nativeBoolToBooleanObject(cmp != 0))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(cmp < 0))
 	case code.OpGreaterThan:
-		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+		return vm.push(nativeBoolToBooleanObject(cmp > 0))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp <= 0))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(cmp >= 0))
 	default:
-		return fmt.Errorf("unknown operator: %d", op)
+		return unknownOperator(op, left, right)
 	}
 }
 
@@ -172,12 +299,28 @@ func (vm *VM) executeBangOperator() error {
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
 
-	if operand.Type() != object.INT_OBJ {
+	switch operand := operand.(type) {
+	case *object.IntObject, *object.BigIntObject:
+		result, err := object.NegateInteger(operand, vm.overflow)
+		if err != nil {
+			return err
+		}
+		return vm.push(result)
+	case *object.FloatObject:
+		return vm.push(&object.FloatObject{Value: -operand.Value})
+	default:
 		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
 	}
+}
+
+func (vm *VM) executeBitNotOperator() error {
+	operand := vm.pop()
+
+	if !object.IsInteger(operand) {
+		return fmt.Errorf("unsupported type for bitwise complement: %s", operand.Type())
+	}
 
-	value := operand.(*object.IntObject).Value
-	return vm.push(&object.IntObject{Value: -value})
+	return vm.push(object.ComplementInteger(operand))
 }
 
 func (vm *VM) executeBinaryStringOperation(
@@ -185,7 +328,7 @@ func (vm *VM) executeBinaryStringOperation(
 	left, right object.Object,
 ) error {
 	if op != code.OpAdd {
-		return fmt.Errorf("unknown string operator: %d", op)
+		return unknownOperator(op, left, right)
 	}
 
 	leftValue := left.(*object.StringObject).Value
@@ -194,6 +337,48 @@ func (vm *VM) executeBinaryStringOperation(
 	return vm.push(&object.StringObject{Value: leftValue + rightValue})
 }
 
+func (vm *VM) executeFloatComparison(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue, _ := object.AsFloat(left)
+	rightValue, _ := object.AsFloat(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	default:
+		return unknownOperator(op, left, right)
+	}
+}
+
+func (vm *VM) executeStringComparison(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue := left.(*object.StringObject).Value
+	rightValue := right.(*object.StringObject).Value
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	default:
+		return unknownOperator(op, left, right)
+	}
+}
+
 func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 	elements := make([]object.Object, endIndex-startIndex)
 
@@ -204,6 +389,18 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 	return &object.ArrayObject{Elements: elements}
 }
 
+// buildString concatenates the Inspect forms of the objects on the stack,
+// for an interpolated string
+func (vm *VM) buildString(startIndex, endIndex int) object.Object {
+	var out strings.Builder
+
+	for i := startIndex; i < endIndex; i++ {
+		out.WriteString(vm.stack[i].Inspect())
+	}
+
+	return &object.StringObject{Value: out.String()}
+}
+
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
 	hashPairs := make(map[object.HashKey]object.HashPair)
 
@@ -228,6 +425,8 @@ func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INT_OBJ:
 		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INT_OBJ:
+		return vm.executeStringIndex(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
 	default:
@@ -247,6 +446,17 @@ func (vm *VM) executeArrayIndex(array, index object.Object) error {
 	return vm.push(arrayObject.Elements[i])
 }
 
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	i := index.(*object.IntObject).Value
+
+	char, ok := str.(*object.StringObject).RuneAt(i)
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(&object.StringObject{Value: char})
+}
+
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	hashObject := hash.(*object.HashObject)
 
@@ -277,38 +487,99 @@ func (vm *VM) popFrame() *Frame {
 	return vm.frames[vm.framesIndex]
 }
 
-func (vm *VM) executeCall(numArgs int) error {
+// executeCall calls the function below the arguments on the stack. The
+// last len(names) arguments are passed by the given names.
+func (vm *VM) executeCall(numArgs int, names []string) error {
 	callee := vm.stack[vm.sp-1-numArgs]
 	switch callee := callee.(type) {
 	case *object.ClosureObject:
-		return vm.callClosure(callee, numArgs)
+		return vm.callClosure(callee, numArgs, names)
 	case *object.BuiltinObject:
+		if len(names) > 0 {
+			// builtins have no parameter names, any name is unknown
+			_, err := object.BindArguments("", object.Arity{}, nil, vm.stack[vm.sp-numArgs:vm.sp], names)
+			return err
+		}
 		return vm.callBuiltin(callee, numArgs)
 	default:
 		return fmt.Errorf("calling non-function and non-built-in")
 	}
 }
 
-func (vm *VM) callClosure(cl *object.ClosureObject, numArgs int) error {
-	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
-			cl.Fn.NumParameters, numArgs)
+func (vm *VM) callClosure(cl *object.ClosureObject, numArgs int, names []string) error {
+	fn := cl.Fn
+	if len(names) > 0 {
+		bound, err := object.BindArguments(fn.Name, fn.Arity(), fn.ParamNames, vm.stack[vm.sp-numArgs:vm.sp], names)
+		if err != nil {
+			return err
+		}
+
+		// the arguments are put in the order of the parameters, with nil
+		// for the ones that get their default value
+		start := vm.sp - numArgs
+		if start+len(bound) >= StackSize {
+			return fmt.Errorf("stack overflow")
+		}
+		copy(vm.stack[start:], bound)
+		vm.sp = start + len(bound)
+		numArgs = len(bound)
+	} else if arity := fn.Arity(); !arity.Accepts(numArgs) {
+		return errors.New(object.ArityError(fn.Name, arity, numArgs))
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("stack overflow: more than %d nested calls", MaxFrames)
 	}
 
-	frame := NewFrame(cl, vm.sp-numArgs)
+	basePointer := vm.sp - numArgs
+	if basePointer+fn.NumLocals >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	// the further arguments are collected in the local of the rest
+	// parameter, missing ones are nil until the function sets their
+	// default value
+	for i := numArgs; i < fn.NumParameters; i++ {
+		vm.stack[basePointer+i] = nil
+	}
+	if fn.Variadic {
+		rest := []object.Object{}
+		if numArgs > fn.NumParameters {
+			rest = append(rest, vm.stack[basePointer+fn.NumParameters:vm.sp]...)
+		}
+		vm.stack[basePointer+fn.NumParameters] = &object.ArrayObject{Elements: rest}
+	}
+
+	frame := NewFrame(cl, basePointer)
 	vm.pushFrame(frame)
 
-	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	vm.sp = frame.basePointer + fn.NumLocals
 
 	return nil
 }
 
+// argumentNames returns the names of arguments passed by name, which are
+// consecutive string constants
+func (vm *VM) argumentNames(numNamed, first int) []string {
+	names := make([]string, numNamed)
+	for i := range names {
+		names[i] = vm.constants[first+i].(*object.StringObject).Value
+	}
+	return names
+}
+
 func (vm *VM) callBuiltin(builtin *object.BuiltinObject, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
 
 	result := builtin.Fn(args...)
 	vm.sp = vm.sp - numArgs - 1
 
+	// builtins report errors as values, they stop the program like any
+	// other runtime error
+	if errObj, ok := result.(*object.ErrorObject); ok {
+		return errors.New(errObj.Message)
+	}
+
 	var err error = nil
 	if result != nil {
 		err = vm.push(result)
@@ -330,9 +601,16 @@ func (vm *VM) pushClosure(constIndex, numFree int) error {
 		return fmt.Errorf("not a function: %+v", constant)
 	}
 
-	free := make([]object.Object, numFree)
+	// the captured variables are upvalues, other values, like the
+	// closure itself, are captured by value
+	free := make([]*object.UpvalueObject, numFree)
 	for i := 0; i < numFree; i++ {
-		free[i] = vm.stack[vm.sp-numFree+i]
+		switch captured := vm.stack[vm.sp-numFree+i].(type) {
+		case *object.UpvalueObject:
+			free[i] = captured
+		default:
+			free[i] = object.NewClosedUpvalue(captured)
+		}
 	}
 	vm.sp = vm.sp - numFree
 
@@ -340,6 +618,61 @@ func (vm *VM) pushClosure(constIndex, numFree int) error {
 	return vm.push(closure)
 }
 
+// captureLocal returns the upvalue of a stack slot of the current frame,
+// closures that capture the same variable share its upvalue
+func (vm *VM) captureLocal(slot int) *object.UpvalueObject {
+	for i := len(vm.openUpvalues) - 1; i >= 0 && vm.openUpvalues[i].slot >= vm.currentFrame().basePointer; i-- {
+		if vm.openUpvalues[i].slot == slot {
+			return vm.openUpvalues[i].upvalue
+		}
+	}
+
+	upvalue := object.NewOpenUpvalue(&vm.stack[slot])
+	vm.openUpvalues = append(vm.openUpvalues, openUpvalue{slot, upvalue})
+
+	return upvalue
+}
+
+// closeUpvalues closes the upvalues of the stack slots from basePointer
+// up, before a returning frame gives them up
+func (vm *VM) closeUpvalues(basePointer int) {
+	i := len(vm.openUpvalues)
+	for i > 0 && vm.openUpvalues[i-1].slot >= basePointer {
+		i--
+		vm.openUpvalues[i].upvalue.Close()
+	}
+
+	vm.openUpvalues = vm.openUpvalues[:i]
+}
+
+// jumpTableEntry returns the entry of a jump table for a value, numbers
+// select the entry of the integer they are equal to
+func jumpTableEntry(value object.Object, min int64, size int) (int, bool) {
+	var n int64
+	switch value := value.(type) {
+	case *object.IntObject:
+		n = value.Value
+	case *object.BigIntObject:
+		if !value.Value.IsInt64() {
+			return 0, false
+		}
+		n = value.Value.Int64()
+	case *object.FloatObject:
+		if value.Value != math.Trunc(value.Value) || math.Abs(value.Value) > 1<<53 {
+			return 0, false
+		}
+		n = int64(value.Value)
+	default:
+		return 0, false
+	}
+
+	if n < min || n > min+int64(size)-1 {
+		return 0, false
+	}
+
+	return int(n - min), true
+}
+
 func nativeBoolToBooleanObject(input bool) *object.BoolObject {
 	if input {
 		return True
@@ -347,6 +680,11 @@ func nativeBoolToBooleanObject(input bool) *object.BoolObject {
 	return False
 }
 
+func isNumber(obj object.Object) bool {
+	_, ok := object.AsFloat(obj)
+	return ok
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj := obj.(type) {
 
@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/token"
+	"strings"
+)
+
+// maxTraceFrames is the number of frames Trace prints before eliding
+const maxTraceFrames = 20
+
+// StackFrame One entry of a stack trace
+type StackFrame struct {
+	Function string         // name of the function, "<anonymous>" if it has none
+	Offset   int            // offset of the executing instruction within the function
+	Pos      token.Position // source position of the instruction, if known
+}
+
+func (sf StackFrame) String() string {
+	if sf.Pos.IsValid() {
+		return fmt.Sprintf("%s (%s, offset %04d)", sf.Function, sf.Pos, sf.Offset)
+	}
+
+	return fmt.Sprintf("%s (offset %04d)", sf.Function, sf.Offset)
+}
+
+// RuntimeError An error raised while executing bytecode
+type RuntimeError struct {
+	Message    string
+	StackTrace []StackFrame // innermost frame first
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Message
+}
+
+// Trace formats the message followed by the stack trace
+func (e *RuntimeError) Trace() string {
+	var out strings.Builder
+
+	out.WriteString(e.Message)
+
+	frames := e.StackTrace
+	for i, frame := range frames {
+		if len(frames) > maxTraceFrames && i == maxTraceFrames/2 {
+			fmt.Fprintf(&out, "\n\t... %d more frames ...", len(frames)-maxTraceFrames)
+		}
+		if len(frames) > maxTraceFrames && i >= maxTraceFrames/2 && i < len(frames)-maxTraceFrames/2 {
+			continue
+		}
+
+		fmt.Fprintf(&out, "\n\tat %s", frame)
+	}
+
+	return out.String()
+}
+
+func (vm *VM) newRuntimeError(err error) *RuntimeError {
+	trace := make([]StackFrame, 0, vm.framesIndex)
+
+	for i := vm.framesIndex - 1; i >= 0; i-- {
+		frame := vm.frames[i]
+
+		name := frame.cl.Fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+
+		offset := instructionStart(frame.Instructions(), frame.ip)
+		span, _ := frame.cl.Fn.SourceMap.Lookup(offset)
+
+		trace = append(trace, StackFrame{
+			Function: name,
+			Offset:   offset,
+			Pos:      span.Start,
+		})
+	}
+
+	return &RuntimeError{Message: err.Error(), StackTrace: trace}
+}
+
+// instructionStart finds the start of the instruction the offset points
+// into. Frames stop on an operand byte once its operands have been read.
+func instructionStart(ins code.Instructions, offset int) int {
+	start := 0
+
+	for i := 0; i < len(ins) && i <= offset; {
+		start = i
+
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return offset
+		}
+
+		_, read := code.ReadOperands(def, ins[i+1:])
+		i += 1 + read
+	}
+
+	return start
+}
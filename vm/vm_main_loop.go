@@ -1,11 +1,23 @@
 package vm
 
 import (
+	"fmt"
 	"monkey/code"
 	"monkey/object"
 )
 
+// Run executes the bytecode. Errors are returned as *RuntimeError, carrying
+// the stack trace at the point of failure.
 func (vm *VM) Run() error {
+	err := vm.run()
+	if err != nil {
+		return vm.newRuntimeError(err)
+	}
+
+	return nil
+}
+
+func (vm *VM) run() error {
 	var ip int
 	var inst code.Instructions
 	var op code.Opcode
@@ -30,7 +42,8 @@ func (vm *VM) Run() error {
 		case code.OpPop:
 			vm.pop()
 
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
@@ -48,7 +61,8 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan,
+			code.OpLessThanOrEqual, code.OpGreaterThanOrEqual:
 			err := vm.executeComparison(op)
 			if err != nil {
 				return err
@@ -66,6 +80,12 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpBitNot:
+			err := vm.executeBitNotOperator()
+			if err != nil {
+				return err
+			}
+
 		case code.OpJump:
 			pos := int(code.ReadUint16(inst[ip+1:]))
 			vm.currentFrame().ip = pos - 1
@@ -79,6 +99,17 @@ func (vm *VM) Run() error {
 				vm.currentFrame().ip = pos - 1
 			}
 
+		case code.OpJumpNotTruthyOrPop, code.OpJumpTruthyOrPop:
+			pos := int(code.ReadUint16(inst[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.stack[vm.sp-1]
+			if isTruthy(condition) == (op == code.OpJumpTruthyOrPop) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
 		case code.OpNull:
 			err := vm.push(Null)
 			if err != nil {
@@ -95,7 +126,7 @@ func (vm *VM) Run() error {
 			globalIndex := code.ReadUint16(inst[ip+1:])
 			vm.currentFrame().ip += 2
 
-			err := vm.push(vm.globals[globalIndex])
+			err := vm.pushVariable(vm.globals[globalIndex])
 			if err != nil {
 				return err
 			}
@@ -112,6 +143,18 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpConcat:
+			numParts := int(code.ReadUint16(inst[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := vm.buildString(vm.sp-numParts, vm.sp)
+			vm.sp = vm.sp - numParts
+
+			err := vm.push(str)
+			if err != nil {
+				return err
+			}
+
 		case code.OpHash:
 			numElements := int(code.ReadUint16(inst[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -136,19 +179,174 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err := object.SetIndex(left, index, value)
+			if err != nil {
+				return err
+			}
+
+			err = vm.push(value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpDupPair:
+			left := vm.stack[vm.sp-2]
+			index := vm.stack[vm.sp-1]
+
+			err := vm.push(left)
+			if err != nil {
+				return err
+			}
+
+			err = vm.push(index)
+			if err != nil {
+				return err
+			}
+
+		case code.OpDup:
+			err := vm.push(vm.stack[vm.sp-1])
+			if err != nil {
+				return err
+			}
+
+		case code.OpJumpTable:
+			minIndex := code.ReadUint16(inst[ip+1:])
+			size := int(code.ReadUint16(inst[ip+3:]))
+			vm.currentFrame().ip += 4
+
+			min := vm.constants[minIndex].(*object.IntObject).Value
+			entry, ok := jumpTableEntry(vm.pop(), min, size)
+			if !ok {
+				entry = size
+			}
+
+			// the next instruction is the OpJump of the entry
+			vm.currentFrame().ip += entry * 3
+
+		case code.OpMatchType:
+			nameIndex := code.ReadUint16(inst[ip+1:])
+			vm.currentFrame().ip += 2
+
+			typeName := vm.constants[nameIndex].(*object.StringObject).Value
+			err := vm.push(nativeBoolToBooleanObject(object.HasType(vm.pop(), typeName)))
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchArray:
+			length := int(code.ReadUint16(inst[ip+1:]))
+			hasRest := code.ReadUint8(inst[ip+3:]) == 1
+			vm.currentFrame().ip += 3
+
+			array, ok := vm.pop().(*object.ArrayObject)
+			matched := ok && (len(array.Elements) == length || (hasRest && len(array.Elements) > length))
+			err := vm.push(nativeBoolToBooleanObject(matched))
+			if err != nil {
+				return err
+			}
+
+		case code.OpHasKey:
+			key := vm.pop().(object.Hashable)
+			hash := vm.pop().(*object.HashObject)
+
+			_, ok := hash.Pairs[key.HashKey()]
+			err := vm.push(nativeBoolToBooleanObject(ok))
+			if err != nil {
+				return err
+			}
+
+		case code.OpArrayRest:
+			index := int(code.ReadUint16(inst[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			err := vm.push(object.ArrayRest(vm.pop().(*object.ArrayObject), index))
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchFailed:
+			return fmt.Errorf("pattern does not match %s", vm.pop().Inspect())
+
 		case code.OpCall:
 			numArgs := code.ReadUint8(inst[ip+1:])
 			vm.currentFrame().ip += 1
 
-			err := vm.executeCall(int(numArgs))
+			err := vm.executeCall(int(numArgs), nil)
 			if err != nil {
 				return err
 			}
 
+		case code.OpCallNamed:
+			numArgs := int(code.ReadUint8(inst[ip+1:]))
+			numNamed := int(code.ReadUint8(inst[ip+2:]))
+			first := int(code.ReadUint16(inst[ip+3:]))
+			vm.currentFrame().ip += 4
+
+			err := vm.executeCall(numArgs, vm.argumentNames(numNamed, first))
+			if err != nil {
+				return err
+			}
+
+		case code.OpCallSpread:
+			numNamed := int(code.ReadUint8(inst[ip+1:]))
+			first := int(code.ReadUint16(inst[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			// the arguments passed by name are above the array of the others
+			named := make([]object.Object, numNamed)
+			copy(named, vm.stack[vm.sp-numNamed:vm.sp])
+			vm.sp -= numNamed
+
+			args := vm.pop().(*object.ArrayObject)
+			for _, arg := range append(args.Elements[:len(args.Elements):len(args.Elements)], named...) {
+				err := vm.push(arg)
+				if err != nil {
+					return err
+				}
+			}
+
+			err := vm.executeCall(len(args.Elements)+numNamed, vm.argumentNames(numNamed, first))
+			if err != nil {
+				return err
+			}
+
+		case code.OpSpread:
+			value := vm.pop()
+			args := vm.pop().(*object.ArrayObject)
+
+			array, ok := value.(*object.ArrayObject)
+			if !ok {
+				return fmt.Errorf("spread argument must be an array, got %s", value.Type())
+			}
+
+			elements := make([]object.Object, 0, len(args.Elements)+len(array.Elements))
+			elements = append(elements, args.Elements...)
+			elements = append(elements, array.Elements...)
+
+			err := vm.push(&object.ArrayObject{Elements: elements})
+			if err != nil {
+				return err
+			}
+
+		case code.OpJumpIfPassed:
+			index := int(code.ReadUint8(inst[ip+1:]))
+			pos := int(code.ReadUint16(inst[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			if vm.stack[vm.currentFrame().basePointer+index] != nil {
+				vm.currentFrame().ip = pos - 1
+			}
+
 		case code.OpReturnValue:
 			returnValue := vm.pop()
 
 			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
 			vm.sp = frame.basePointer - 1
 
 			err := vm.push(returnValue)
@@ -158,6 +356,7 @@ func (vm *VM) Run() error {
 
 		case code.OpReturn:
 			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
 			vm.sp = frame.basePointer - 1
 
 			err := vm.push(Null)
@@ -179,7 +378,7 @@ func (vm *VM) Run() error {
 
 			frame := vm.currentFrame()
 
-			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+			err := vm.pushVariable(vm.stack[frame.basePointer+int(localIndex)])
 			if err != nil {
 				return err
 			}
@@ -209,6 +408,34 @@ func (vm *VM) Run() error {
 			freeIndex := code.ReadUint8(inst[ip+1:])
 			vm.currentFrame().ip += 1
 
+			currentClosure := vm.currentFrame().cl
+			err := vm.pushVariable(currentClosure.Free[freeIndex].Get())
+			if err != nil {
+				return err
+			}
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(inst[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			currentClosure.Free[freeIndex].Set(vm.pop())
+
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(inst[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+
+			err := vm.push(vm.captureLocal(frame.basePointer + int(localIndex)))
+			if err != nil {
+				return err
+			}
+
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(inst[ip+1:])
+			vm.currentFrame().ip += 1
+
 			currentClosure := vm.currentFrame().cl
 			err := vm.push(currentClosure.Free[freeIndex])
 			if err != nil {
@@ -1,12 +1,15 @@
 package vm
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 	"monkey/ast"
 	"monkey/compiler"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
 	"testing"
 )
 
@@ -29,6 +32,160 @@ func TestIntegerArithmetic(t *testing.T) {
 		{"-10", -10},
 		{"-50 + 100 + -50", 0},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"1 + 7 % 3 * 2", 3},
+		{"0xFF + 0b1", 256},
+		{"1_000 * 0o10", 8000},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"~0", -1},
+		{"~5", -6},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"-16 >> 2", -4},
+		{"1 << 63 >> 63", -1},
+		{"1 << 64", 0},
+		{"1 | 2 << 2 & 12", 9},
+		{"let none = 0; let flags = none | 1 << 3; flags & 8 != 0", true},
+		{"1 << -1", &object.ErrorObject{Message: "negative shift count: -1"}},
+		{"8 >> -2", &object.ErrorObject{Message: "negative shift count: -2"}},
+		{"~true", &object.ErrorObject{Message: "unsupported type for bitwise complement: BOOL"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestUnknownOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5 & 2.0", &object.ErrorObject{Message: "unknown operator: FLOAT & FLOAT"}},
+		{"1.5 << 1", &object.ErrorObject{Message: "unknown operator: FLOAT << INT"}},
+		{`"a" - "b"`, &object.ErrorObject{Message: "unknown operator: STRING - STRING"}},
+		{`"a" < "b"`, &object.ErrorObject{Message: "unknown operator: STRING < STRING"}},
+		{"true >= false", &object.ErrorObject{Message: "unknown operator: BOOL >= BOOL"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	runVmTests(t, []vmTestCase{
+		{"1 / 0", &object.ErrorObject{Message: "division by zero"}},
+		{"1 % 0", &object.ErrorObject{Message: "division by zero"}},
+		{"9223372036854775807 + 1", math.MinInt64},
+	})
+
+	tests := []struct {
+		input    string
+		policy   object.OverflowPolicy
+		expected string // Inspect form of the result, or the error message
+	}{
+		{"9223372036854775807 + 1", object.OverflowError, "integer overflow: 9223372036854775807 + 1"},
+		{"9223372036854775807 + 1", object.OverflowPromote, "9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", object.OverflowError, "integer overflow: -(-9223372036854775808)"},
+		{"-(-9223372036854775807 - 1)", object.OverflowPromote, "9223372036854775808"},
+		{"9223372036854775807 * 4 / 4", object.OverflowPromote, "9223372036854775807"},
+		{"9223372036854775807 + 1 < 9223372036854775807", object.OverflowPromote, "false"},
+		{"9223372036854775807 + 1 != 9223372036854775807 + 1", object.OverflowPromote, "false"},
+		{"let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(25)", object.OverflowPromote, "15511210043330985984000000"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := NewWithConfig(comp.Bytecode(), Config{Overflow: tt.policy})
+		got := ""
+		if err := vm.Run(); err != nil {
+			got = err.Error()
+		} else {
+			got = vm.LastPoppedStackElem().Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("%s (%s) - expected=%s, got=%s", tt.input, tt.policy, tt.expected, got)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // Inspect form of the result, or the error message
+	}{
+		{"123456789012345678901234567890n", "123456789012345678901234567890"},
+		{"-123456789012345678901234567890n", "-123456789012345678901234567890"},
+		{"9223372036854775807n + 1", "9223372036854775808"},
+		{"1 + 9223372036854775807n", "9223372036854775808"},
+		{"2n * 3", "6"},
+		{"100000000000000000000n / 3", "33333333333333333333"},
+		{"-7n % 2", "-1"},
+		{"1n << 100", "1267650600228229401496703205376"},
+		{"(1n << 100) >> 99", "2"},
+		{"~0n", "-1"},
+		{"0xFFn & 0x0F", "15"},
+		{"1n / 0", "division by zero"},
+		{"5n == 5", "true"},
+		{"5 != 5n", "false"},
+		{"100000000000000000000n > 9223372036854775807", "true"},
+		{"-100000000000000000000n < -9223372036854775807", "true"},
+		{"3n <= 3", "true"},
+		{"1n + 0.5", "1.5"},
+		{"2n > 1.5", "true"},
+		{`{5: "five"}[5n]`, "five"},
+		{"{100000000000000000000n: 1}[100000000000000000000n]", "1"},
+		{"let fact = fn(n) { if (n == 0) { 1n } else { n * fact(n - 1) } }; fact(30)", "265252859812191058636308480000000"},
+		{`"${2n * 21}"`, "42"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		got := ""
+		if err := vm.Run(); err != nil {
+			got = err.Error()
+		} else {
+			got = vm.LastPoppedStackElem().Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("%s - expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 / 4.0", 2.5},
+		{"2.5 * 2", 5.0},
+		{"1e3 - 1", 999.0},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"0.5 != 0.5", false},
+		{"{5: 5}[5.0]", 5},
+		{"7.5 % 2", 1.5},
+		{"1.5 <= 1.5", true},
+		{"2 >= 2.5", false},
 	}
 
 	runVmTests(t, tests)
@@ -62,6 +219,38 @@ func TestBooleanExpressions(t *testing.T) {
 		{"!!false", false},
 		{"!!5", true},
 		{"!(if (false) { 5; })", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"3 >= 2", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []vmTestCase{
+		// the result is the operand that decided it
+		{"1 && 2", 2},
+		{"false && 2", false},
+		{"0 || 2", 0},
+		{`if (false) { 1 } || "default"`, "default"},
+		// the right operand is not evaluated when the left decides
+		{"false && len(1)", false},
+		{"true || len(1)", true},
+		{"let x = 1; false && push([], x); x", 1},
+		{"let f = fn(n) { n > 0 && f(n - 1) || n == 0 }; f(10)", true},
+		{"let a = [1]; len(a) > 1 && a[1] > 5", false},
 	}
 
 	runVmTests(t, tests)
@@ -79,6 +268,103 @@ func TestConditionals(t *testing.T) {
 		{"if (1 > 2) { 10 }", Null},
 		{"if (false) { 10 }", Null},
 		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"match (2) { 1 => 10, 2 => 20, _ => 30 }", 20},
+		{"match (5) { 1 => 10, 2 => 20, _ => 30 }", 30},
+		{"match (5) { 1 => 10, 2 => 20 }", Null},
+		{"match (3) { 1, 2 => 10, 3, 4 => 20 }", 20},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{"match (1 < 2) { false => 1, true => 2 }", 2},
+		{"match (-1) { 1 => 1, -1 => 2 }", 2},
+		{"match (2.0) { 1 => 1, 2 => 2 }", 2},
+		{"match (2) { 1.5 => 1, 2.0 => 2 }", 2},
+		{"match (2n) { 1 => 1, 2 => 2 }", 2},
+		{"match ([1]) { 1 => 1, _ => 2 }", 2},
+		{"match (1) { 1, _ => 1, 1 => 2 }", 1},
+		{"match (1) { 1 => { let x = 5; x * 2 } _ => 0 }", 10},
+		{"match (1) { 1 => { let x = 5 } _ => 0 }", Null},
+		{"match (1) { 1 => {}, _ => 0 }", Null},
+		{"let f = fn(n) { match (n) { 0 => 10, 1 => 11, 2, 3 => 12, 5 => 15, _ => 0 } }; f(0) + f(3) * 100", 1210},
+		{"let f = fn(n) { match (n) { 0 => 10, 1 => 11, 2, 3 => 12, 5 => 15, _ => 0 } }; f(4) + f(5) * 100", 1500},
+		{"let f = fn(n) { match (n) { -2 => 1, -1 => 2, 0 => 3, 1 => 4 } }; f(-2) * 10 + f(1)", 14},
+		{"let f = fn(n) { match (n) { 0 => 1, 1 => 2, 2 => 3, 3 => 4 } }; f(2.0) * 10 + f(3n)", 34},
+		{"let f = fn(n) { match (n) { 0 => 1, 1 => 2, 2 => 3, 3 => 4 } }; f(9)", Null},
+		{"let f = fn(n) { match (n) { 0 => 1, 1 => 2, 2 => 3, 3 => 4 } }; f(1.5)", Null},
+		{"let f = fn(n) { match (n) { 0 => 1, 1 => 2, 2 => 3, 3 => 4 } }; f(\"1\")", Null},
+		{"let f = fn(n) { match (n) { 0 => 1, 0 => 2, 1 => 3, 2 => 4, 3 => 5 } }; f(0)", 1},
+		{"let s = 0; for (let i = 0; i < 10; i += 1) { match (i % 3) { 0 => { continue } 1 => { s += i } _ => { if (i > 7) { break } } } }; s", 12},
+		{"let f = fn(n) { match (n) { 0 => { return 7 } _ => 1 }; 2 }; f(0) * 10 + f(1)", 72},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestPatterns(t *testing.T) {
+	tests := []vmTestCase{
+		{"match ([1, 2]) { [a, b] => a + b }", 3},
+		{"match ([1, 2, 3]) { [a, b] => 0, [a, ...rest] => len(rest) }", 2},
+		{"match ([]) { [a, ..._] => 1, [] => 2 }", 2},
+		{"match ([1, [2, 3]]) { [1, [a, b]] => a * b, _ => 0 }", 6},
+		{"match ([1, [2]]) { [1, [a, b]] => a * b, _ => 0 }", 0},
+		{`match ({"name": "x", "age": 3}) { {"age": a} => a }`, 3},
+		{`match ({"age": "3"}) { {"age": int(a)} => a, {"age": string(s)} => len(s) }`, 1},
+		{`match ({}) { {"age": a} => a, _ => -1 }`, -1},
+		{`match ({}["k"]) { null(_) => 1 }`, 1},
+		{"match (5n) { int(n) => 1, _ => 2 }", 1},
+		{"match (1.5) { int(n) => 1, float(f) => 2 }", 2},
+		{"match (true) { bool(b) => 1 }", 1},
+		{"match (len) { function(f) => f([1, 2]) }", 2},
+		{"match (fn(x) { x }) { function(f) => f(7) }", 7},
+		{"match ([3, 1]) { [a, b] if a < b => 1, [a, b] if a > b => 2, _ => 3 }", 2},
+		{"match ([1]) { [x] if x > 5 => 1 }", Null},
+		{"let a = 10; let b = 20; match ([1, 2]) { [a, b] if a > 1 => 0, _ => a + b }", 30},
+		{"let x = 10; match ([1, \"no\"]) { [x, 2] => 0, _ => x }", 10},
+		{"let x = 10; match ([1, \"no\"]) { [x, 2] => 0, _ => 0 }; x", 10},
+		{"let f = fn() { let x = 10; match ([1, 2]) { [x, 3] => 0, [_, x] if x > 5 => 0, _ => 0 }; x }; f()", 10},
+		{"match ([1]) { [len] => len, _ => 0 }; len([1, 2])", 2},
+		{"match ([1, 2]) { [x, 1], [1, x] => x, _ => 0 }", 2},
+		{"let f = fn(v) { match (v) { [h, ...t] => h + f(t), [] => 0 } }; f([1, 2, 3, 4])", 10},
+		{"let f = fn(v) { match (v) { [a] => fn() { a } } }; f([9])()", 9},
+		{"let [a, b, ...c] = [1, 2, 3, 4]; a + b + len(c)", 5},
+		{`let {"x": x, "y": [y]} = {"x": 1, "y": [2]}; x * 10 + y`, 12},
+		{"let f = fn(p) { let [a, b] = p; a - b }; f([5, 3])", 2},
+		{"let [a, b] = [1];", &object.ErrorObject{Message: "pattern does not match [1]"}},
+		{`let {"x": x} = 5;`, &object.ErrorObject{Message: "pattern does not match 5"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 5) { let i = i + 1 }; i", 5},
+		{"let i = 0; while (false) { let i = i + 1 }; i", 0},
+		{"let s = 0; for (let i = 1; i <= 100; let i = i + 1) { let s = s + i }; s", 5050},
+		{"let s = 0; for (let i = 0; i < 10; let i = i + 1) { if (i % 2 == 0) { continue } let s = s + i }; s", 25},
+		{"let i = 0; for (;;) { let i = i + 1; if (i == 7) { break } }; i", 7},
+		{"let n = 0; for (let i = 0; i < 4; let i = i + 1) { for (let j = 0; j < 4; let j = j + 1) { if (j > i) { break } let n = n + 1 } }; n", 10},
+		{"let f = fn(n) { let i = 0; while (true) { if (i * i >= n) { return i } let i = i + 1 } }; f(50)", 8},
+		{"let f = fn() { let i = 0; while (i < 3) { let i = i + 1 } }; f()", Null},
+		{"let f = fn(xs) { let s = 0; let i = 0; while (i < len(xs)) { let s = s + xs[i]; let i = i + 1 }; s }; f([1, 2, 3])", 6},
+		{"let i = 0; while (i < 100000) { let i = i + 1 }; i", 100000},
+		{"if (true) { let x = 1 }", Null},
+		{"let x = 1; let x = x + 1; x", 2},
+		{"let i = 0; while (true) { i += 1; if (i < 3) { continue } else if (i == 5) { break } }; i", 5},
+		{"let s = 0; for (let i = 0; i < 6; i += 1) { match (i % 3) { 0 => { continue }, 2 => if (i > 3) { break }, _ => 0 }; s += i }; s", 7},
+		{"let i = 0; let n = 1 + fn() { while (true) { i += 1; if (i > 3) { break } }; i }(); n", 5},
+		{"let i = 0; while (i < 3) { let i = i + 1; if (i == 2) { i + true } }; i", &object.ErrorObject{Message: "unsupported types for binary operation: INT BOOL"}},
+		{"let f = fn() { for (let i = 0; i < 0; i += 1) { let k = i; } k }; f()", &object.ErrorObject{Message: "undefined variable: it has no value yet"}},
+		{"while (false) { let z = 1; }; z", &object.ErrorObject{Message: "undefined variable: it has no value yet"}},
+		{"fn() { if (false) { let k = 1 }; fn() { k } }()()", &object.ErrorObject{Message: "undefined variable: it has no value yet"}},
 	}
 
 	runVmTests(t, tests)
@@ -94,11 +380,44 @@ func TestGlobalLetStatements(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestAssignments(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; x = 5; x", 5},
+		{"let x = 1; x += 2; x", 3},
+		{"let x = 7; x -= 2; x *= 3; x /= 5; x %= 2; x", 1},
+		{"let x = 6; x &= 3; x |= 8; x ^= 1; x <<= 2; x >>= 1; x", 22},
+		{"let x = 1; let y = 2; x = y = 3; x + y", 6},
+		{"let x = 1; (x += 4) * 2", 10},
+		{"let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n", 2},
+		{"let s = 0; for (let i = 0; i < 5; i += 1) { s += i }; s", 10},
+		{"let a = [1, 2, 3]; a[1] = 20; a[1] + a[2]", 23},
+		{"let a = [1, 2, 3]; let b = a; b[0] *= 10; a[0]", 10},
+		{"let h = {}; h[\"k\"] = 1; h[\"k\"] += 2; h[\"k\"]", 3},
+		{"let h = {1: 1}; h[1n] = 5; h[1]", 5},
+		{"let f = fn() { let x = 1; x += 1; x }; f()", 2},
+		{"let a = [1]; a[1] = 2", &object.ErrorObject{Message: "index out of range: 1"}},
+		{"let s = \"ab\"; s[0] = \"c\"", &object.ErrorObject{Message: "index assignment not supported: STRING"}},
+		{"let h = {}; h[[]] = 1", &object.ErrorObject{Message: "unusable as hash key: ARRAY"}},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestStringExpressions(t *testing.T) {
 	tests := []vmTestCase{
 		{`"monkey"`, "monkey"},
 		{`"mon" + "key"`, "monkey"},
 		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{`"monkey" == "mon" + "key"`, true},
+		{`"monkey" == "banana"`, false},
+		{`"monkey" != "banana"`, true},
+		{`"monkey" != "monkey"`, false},
+		{`"tab\there\n\"quoted\" \u{e9}"`, "tab\there\n\"quoted\" é"},
+		{"`raw\\n`", "raw\\n"},
+		{`let name = "Ann"; "hello ${name}!"`, "hello Ann!"},
+		{`"${1 + 2} ${1.5} ${true} ${[1, "a"]} ${{"k": 2}}"`, "3 1.5 true [1, a] {k : 2}"},
+		{`let f = fn(x) { "x=${x}" }; f(fn(y) { "${y}" }(4))`, "x=4"},
+		{`"${"nested ${"deep"}"}"`, "nested deep"},
 	}
 
 	runVmTests(t, tests)
@@ -150,6 +469,11 @@ func TestIndexExpressions(t *testing.T) {
 		{"{1: 1, 2: 2}[2]", 2},
 		{"{1: 1}[0]", Null},
 		{"{}[0]", Null},
+		{`"abc"[0]`, "a"},
+		{`"héllo"[1]`, "é"},
+		{`"世界"[1]`, "界"},
+		{`"abc"[3]`, Null},
+		{`"abc"[-1]`, Null},
 	}
 
 	runVmTests(t, tests)
@@ -377,6 +701,39 @@ func TestCallingFunctionsWithArgumentsAndBindings(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestFunctionParameters(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn(a, b = 2) { a + b }; f(1)", 3},
+		{"let f = fn(a, b = 2) { a + b }; f(1, 5)", 6},
+		{"let f = fn(a, b = a * 2, c = a + b) { c }; f(1)", 3},
+		{"let f = fn(a, b = a * 2, c = a + b) { c }; f(1, 5)", 6},
+		{"let n = 10; let f = fn(a = n) { a }; f()", 10},
+		{"let make = fn(x) { fn(y = x) { y } }; make(4)()", 4},
+		{"let f = fn(a, b = fn() { a }) { b() }; f(8)", 8},
+		{"let f = fn(a = 1) { a = a + 1; a }; f() + f(5)", 8},
+		{"let b = 10; let f = fn(a = b, b = 1) { a + b }; f()", 11},
+		{"let b = 10; let f = fn(a = b, b = 1) { a + b }; f(b: 5)", 15},
+		{"let r = [1, 2]; let f = fn(a = r, ...r) { len(a) * 10 + len(r) }; f()", 20},
+		{"let count = fn(...rest) { len(rest) }; count()", 0},
+		{"let count = fn(...rest) { len(rest) }; count(1, 2, 3)", 3},
+		{"let f = fn(a, b = 1, ...rest) { a + b + len(rest) }; f(1)", 2},
+		{"let f = fn(a, b = 1, ...rest) { a + b + len(rest) }; f(1, 2, 3, 4)", 5},
+		{"let add = fn(a, b) { a + b }; add(...[1, 2])", 3},
+		{"let add = fn(a, b, c) { a * 100 + b * 10 + c }; add(1, ...[2], 3)", 123},
+		{"let add = fn(a, b, c) { a * 100 + b * 10 + c }; add(...[], 1, ...[2, 3])", 123},
+		{"let f = fn(...r) { r }; f(...[1, 2], 3)[2]", 3},
+		{"len(...[[1, 2]])", 2},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(1, c: 5)", 125},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(c: 4, a: 7)", 724},
+		{"let f = fn(a, b = a + 1) { a * 10 + b }; f(b: 9, a: 1)", 19},
+		{"let f = fn(a, ...rest) { a + len(rest) }; f(...[], a: 5)", 5},
+		{"let f = fn(a, b, ...rest) { a * 10 + b + len(rest) }; f(...[1], b: 2)", 12},
+		{"let calls = []; let f = fn(a, b) { a - b }; f(b: fn() { calls = push(calls, 1); 1 }(), a: fn() { calls = push(calls, 2); 5 }()) * 10 + calls[0]", 41},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -391,6 +748,50 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 			input:    `fn(a, b) { a + b; }(1);`,
 			expected: `wrong number of arguments: want=2, got=1`,
 		},
+		{
+			input:    `let f = fn(a, b = 1) { a }; f();`,
+			expected: "wrong number of arguments to `f`: want=1 to 2, got=0",
+		},
+		{
+			input:    `let f = fn(a, b = 1) { a }; f(1, 2, 3);`,
+			expected: "wrong number of arguments to `f`: want=1 to 2, got=3",
+		},
+		{
+			input:    `let f = fn(a, ...r) { a }; f();`,
+			expected: "wrong number of arguments to `f`: want=at least 1, got=0",
+		},
+		{
+			input:    `let f = fn(a) { a }; f(...[1, 2]);`,
+			expected: "wrong number of arguments to `f`: want=1, got=2",
+		},
+		{
+			input:    `let f = fn(a) { a }; f(...1);`,
+			expected: "spread argument must be an array, got INT",
+		},
+		{
+			input:    `let f = fn(a) { a }; f(b: 1);`,
+			expected: "unknown parameter `b` of `f`",
+		},
+		{
+			input:    `let f = fn(a, b = 1) { a }; f(1, a: 2);`,
+			expected: "duplicate argument for parameter `a` of `f`",
+		},
+		{
+			input:    `let f = fn(a, b = 1) { a }; f(b: 2);`,
+			expected: "missing argument for parameter `a` of `f`",
+		},
+		{
+			input:    `fn(a) { a }(1, 2, a: 3);`,
+			expected: "duplicate argument for parameter `a`",
+		},
+		{
+			input:    `len(x: 1);`,
+			expected: "unknown parameter `x`",
+		},
+		{
+			input:    `let f = fn(a, ...r) { a }; f(r: []);`,
+			expected: "unknown parameter `r` of `f`",
+		},
 	}
 
 	for _, tt := range tests {
@@ -414,11 +815,89 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	}
 }
 
+func TestRuntimeErrorStackTrace(t *testing.T) {
+	input := `
+	let inner = fn(a, b) { a + b };
+	let outer = fn(x) { inner(x, true) };
+	outer(1);
+	`
+
+	expected := []StackFrame{
+		{Function: "inner", Offset: 4, Pos: token.Position{Line: 2, Column: 25, Offset: 25}},
+		{Function: "outer", Offset: 6, Pos: token.Position{Line: 3, Column: 22, Offset: 55}},
+		{Function: MainFunctionName, Offset: 20, Pos: token.Position{Line: 4, Column: 2, Offset: 74}},
+	}
+
+	program := parse(input)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err == nil {
+		t.Fatalf("expected VM error but resulted in none.")
+	}
+
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("error is not *RuntimeError. got=%T (%+v)", err, err)
+	}
+
+	if runtimeErr.Message != "unsupported types for binary operation: INT BOOL" {
+		t.Errorf("wrong error message. got=%q", runtimeErr.Message)
+	}
+
+	if len(runtimeErr.StackTrace) != len(expected) {
+		t.Fatalf("wrong stack trace length. want=%d, got=%d (%v)",
+			len(expected), len(runtimeErr.StackTrace), runtimeErr.StackTrace)
+	}
+
+	for i, frame := range expected {
+		if runtimeErr.StackTrace[i] != frame {
+			t.Errorf("stack trace[%d] wrong. want=%+v, got=%+v",
+				i, frame, runtimeErr.StackTrace[i])
+		}
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	input := `let f = fn(x) { f(x) + 1 }; f(1);`
+
+	program := parse(input)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err == nil {
+		t.Fatalf("expected VM error but resulted in none.")
+	}
+
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("error is not *RuntimeError. got=%T (%+v)", err, err)
+	}
+
+	if len(runtimeErr.StackTrace) != MaxFrames {
+		t.Errorf("wrong stack trace length. want=%d, got=%d",
+			MaxFrames, len(runtimeErr.StackTrace))
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []vmTestCase{
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo, 世界")`, 9},
 		{
 			`len(1)`,
 			&object.ErrorObject{
@@ -427,7 +906,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		},
 		{`len("one", "two")`,
 			&object.ErrorObject{
-				Message: "wrong number of arguments. got=2, want=1",
+				Message: "wrong number of arguments to `len`: want=1, got=2",
 			},
 		},
 		{`len([1, 2, 3])`, 3},
@@ -539,6 +1018,125 @@ func TestClosures(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestMutableClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+		let newCounter = fn() {
+			let n = 0;
+			fn() { n += 1; n };
+		};
+		let first = newCounter();
+		let second = newCounter();
+		first(); first();
+		first() * 10 + second();
+		`,
+			expected: 31,
+		},
+		{
+			input: `
+		let newCell = fn() {
+			let v = 0;
+			[fn() { v }, fn(x) { v = x }];
+		};
+		let cell = newCell();
+		cell[1](42);
+		cell[0]();
+		`,
+			expected: 42,
+		},
+		{
+			input: `
+		let outer = fn() {
+			let x = 1;
+			let add = fn(n) { x += n };
+			add(10);
+			add(100);
+			x;
+		};
+		outer();
+		`,
+			expected: 111,
+		},
+		{
+			input: `
+		let newTripler = fn() {
+			let a = 1;
+			fn() { fn() { a *= 3; a } };
+		};
+		let tripler = newTripler()();
+		tripler();
+		tripler();
+		`,
+			expected: 9,
+		},
+		{
+			input: `
+		let fib = fn() {
+			let calls = 0;
+			let f = fn(n) { calls += 1; if (n < 2) { return n } f(n - 1) + f(n - 2) };
+			f(10) * 1000 + calls;
+		};
+		fib();
+		`,
+			expected: 55177,
+		},
+		{
+			input: `
+		let f = fn() { f = 5; 1 };
+		f() + f;
+		`,
+			expected: 6,
+		},
+		{
+			input: `
+		let last = fn() {
+			let fns = [];
+			for (let i = 0; i < 3; i += 1) { fns = push(fns, fn() { i }) }
+			fns[0]();
+		};
+		last();
+		`,
+			expected: 3,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+// TestMutableClosuresFromFile runs closures that share a variable after a
+// round trip through the bytecode format
+func TestMutableClosuresFromFile(t *testing.T) {
+	input := `
+	let newCounter = fn() {
+		let n = 0;
+		[fn() { n += 1; n }, fn() { n }];
+	};
+	let counter = newCounter();
+	counter[0](); counter[0]();
+	counter[1]() * 10 + counter[0]();
+	`
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := comp.Bytecode().Encode(&buf, true); err != nil {
+		t.Fatalf("encode error: %s", err)
+	}
+	bytecode, err := compiler.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+
+	vm := New(bytecode)
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 23, vm.LastPoppedStackElem())
+}
 func TestRecursiveFunctions(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -634,6 +1232,14 @@ func runVmTests(t *testing.T, tests []vmTestCase) {
 
 		vm := New(comp.Bytecode())
 		err = vm.Run()
+		if expectedErr, ok := tt.expected.(*object.ErrorObject); ok {
+			if err == nil {
+				t.Errorf("expected vm error %q, got none", expectedErr.Message)
+			} else if err.Error() != expectedErr.Message {
+				t.Errorf("wrong vm error. expected=%q, got=%q", expectedErr.Message, err)
+			}
+			continue
+		}
 		if err != nil {
 			t.Fatalf("vm error: %s", err)
 		}
@@ -664,6 +1270,12 @@ func testExpectedObject(
 			t.Errorf("testIntegerObject failed: %s", err)
 		}
 
+	case float64:
+		err := testFloatObject(expected, actual)
+		if err != nil {
+			t.Errorf("testFloatObject failed: %s", err)
+		}
+
 	case bool:
 		err := testBooleanObject(bool(expected), actual)
 		if err != nil {
@@ -754,6 +1366,21 @@ func testIntegerObject(expected int64, actual object.Object) error {
 	return nil
 }
 
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.FloatObject)
+	if !ok {
+		return fmt.Errorf("object is not Float. got=%T (%+v)",
+			actual, actual)
+	}
+
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+	}
+
+	return nil
+}
+
 func testBooleanObject(expected bool, actual object.Object) error {
 	result, ok := actual.(*object.BoolObject)
 	if !ok {